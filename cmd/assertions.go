@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Loads the assertions of the flags and the front matter (or schema file)
+// of the Dockerfile template.
+func (t *templater) initAssertions() {
+	t.assertions = nil
+
+	for _, raw := range t.Assertions {
+		assertion, err := utils.ParseAssertion(raw)
+		if err != nil {
+			utils.Error("%s", err)
+		}
+		t.assertions = append(t.assertions, assertion)
+	}
+
+	if schema := loadTemplateSchema(t.DockerfileTpl); schema != nil {
+		for idx, assertion := range schema.Assertions {
+			if assertion == nil {
+				utils.Error("Assertion %d of '%s' is empty", idx+1, t.DockerfileTpl)
+			}
+			if err := assertion.Compile(); err != nil {
+				utils.Error("Assertion %d of '%s': %s", idx+1, t.DockerfileTpl, err)
+			}
+			t.assertions = append(t.assertions, assertion)
+		}
+	}
+
+	utils.Debug("Loaded %d assertion(s) on the rendered Dockerfiles", len(t.assertions))
+}
+
+// Checks the assertions on the rendered Dockerfile of a variant and fails
+// listing all which are not satisfied.
+func (t *templater) checkAssertions(v *variant, rendered []byte) {
+	var failures []string
+	for _, assertion := range t.assertions {
+		if !assertion.Check(rendered) {
+			failures = append(failures, assertion.String())
+		}
+	}
+
+	if len(failures) > 0 {
+		utils.Error(
+			"Dockerfile of variant '%s' does not satisfy the assertions:\n - %s",
+			*v.Name, strings.Join(failures, "\n - "),
+		)
+	}
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Records the base images of a rendered Dockerfile for verification. Images
+// of variants of the run are skipped, they do not exist before the variants
+// are built.
+func (t *templater) collectBaseImages(v *variant, rendered []byte) {
+	if !t.VerifyBaseImages {
+		return
+	}
+
+	if t.baseImages == nil {
+		t.baseImages = make(map[string][]string)
+	}
+
+	for _, image := range utils.BaseImages(rendered, v.BuildArgs()) {
+		if name, ok := t.variantRefs[imageKey(image)]; ok {
+			utils.Debug(
+				"Not verifying the base image '%s' of variant '%s' which is the image of variant '%s'",
+				image, *v.Name, name,
+			)
+			continue
+		}
+
+		if _, ok := t.baseImages[image]; !ok {
+			t.baseImageOrder = append(t.baseImageOrder, image)
+		}
+		t.baseImages[image] = append(t.baseImages[image], *v.Name)
+	}
+}
+
+// Verifies that the collected base images exist in their registries and
+// fails listing the ones which do not.
+func (t *templater) verifyBaseImages() {
+	if !t.VerifyBaseImages {
+		return
+	}
+
+	defer utils.Measure("verify base images")()
+
+	progress := utils.NewProgress("Verifying", len(t.baseImageOrder))
+	defer progress.Done()
+
+	failures := 0
+	for _, image := range t.baseImageOrder {
+		progress.Step(image)
+
+		if err := utils.VerifyImageExists(image); err != nil {
+			failures++
+			utils.Warn(
+				"Base image of variant(s) '%s': %s",
+				strings.Join(t.baseImages[image], "', '"), err,
+			)
+			continue
+		}
+
+		utils.Debug("Base image '%s' exists", image)
+	}
+
+	if failures > 0 {
+		utils.Error("%d base image(s) could not be verified", failures)
+	}
+
+	utils.Info("Verified %d base image(s)", len(t.baseImageOrder))
+}
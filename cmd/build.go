@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Supported backends to build the images with.
+const (
+	builderDocker  = "docker"
+	builderBuildx  = "buildx"
+	builderPodman  = "podman"
+	builderBuildah = "buildah"
+)
+
+var (
+	buildBuilder  string
+	buildContext  string
+	buildPush     bool
+	buildRegistry string
+
+	buildCMD = &cobra.Command{
+		Use:   "build",
+		Short: "Render the Dockerfiles and build the images of all variants",
+		Long: "Render the Dockerfiles and build the image of each variant tagged with its image name and tag, " +
+			"optionally pushing the images afterwards",
+		Args: cobra.NoArgs,
+		Run:  runBuild,
+	}
+)
+
+func init() {
+	buildCMD.Flags().StringVar(
+		&buildBuilder, "builder", builderDocker,
+		"Backend to build the images with, one of 'docker', 'buildx', 'podman' or 'buildah'",
+	)
+	buildCMD.Flags().StringVar(
+		&buildContext, "context", ".",
+		"Build context passed to the builder",
+	)
+	buildCMD.Flags().BoolVar(
+		&buildPush, "push", false,
+		"Push the images after they were built",
+	)
+	buildCMD.Flags().StringVar(
+		&buildRegistry, "registry", "",
+		"Registry to tag and push the images to, it replaces the registry of the image names",
+	)
+
+	TemplaterCMD.AddCommand(buildCMD)
+}
+
+// Verifies that the builder is supported and fails if not.
+func verifyBuilder(builder string) {
+	switch builder {
+	case builderDocker, builderBuildx, builderPodman, builderBuildah:
+	default:
+		utils.Error(
+			"Invalid builder '%s', must be one of '%s', '%s', '%s' or '%s'",
+			builder, builderDocker, builderBuildx, builderPodman, builderBuildah,
+		)
+	}
+}
+
+// Returns the commands which build (and push) an image with the builder.
+func builderCommands(
+	builder string,
+	dockerfile string,
+	refs []string,
+	buildArgs []string,
+	context string,
+	push bool,
+) [][]string {
+	args := []string{"--file", dockerfile}
+	for _, ref := range refs {
+		args = append(args, "--tag", ref)
+	}
+	for _, arg := range buildArgs {
+		args = append(args, "--build-arg", arg)
+	}
+
+	// Returns the commands pushing all references with the command.
+	pushCommands := func(command string) [][]string {
+		var cmds [][]string
+		for _, ref := range refs {
+			cmds = append(cmds, []string{command, "push", ref})
+		}
+		return cmds
+	}
+
+	switch builder {
+	case builderBuildx:
+		// buildx pushes directly, otherwise the image is loaded into docker
+		if push {
+			args = append(args, "--push")
+		} else {
+			args = append(args, "--load")
+		}
+		return [][]string{
+			append(append([]string{"docker", "buildx", "build"}, args...), context),
+		}
+	case builderPodman, builderBuildah:
+		cmds := [][]string{
+			append(append([]string{builder, "build"}, args...), context),
+		}
+		if push {
+			cmds = append(cmds, pushCommands(builder)...)
+		}
+		return cmds
+	default:
+		cmds := [][]string{
+			append(append([]string{"docker", "build"}, args...), context),
+		}
+		if push {
+			cmds = append(cmds, pushCommands("docker")...)
+		}
+		return cmds
+	}
+}
+
+func runBuild(_ *cobra.Command, _ []string) {
+	verifyBuilder(buildBuilder)
+
+	templater, variants := render()
+
+	progress := utils.NewProgress("Building", len(variants.Variants))
+	defer progress.Done()
+
+	// Dependencies are built (and pushed) before the variants depending on
+	// them
+	for _, idx := range buildOrder(variants.Variants, templater.fromImages) {
+		v := variants.Variants[idx]
+		progress.Step(*v.Name)
+
+		refs := v.ImageRefs(buildRegistry)
+
+		utils.Info(
+			"Building image '%s' of variant '%s' with %s",
+			strings.Join(refs, "', '"), *v.Name, buildBuilder,
+		)
+
+		for _, cmd := range builderCommands(
+			buildBuilder, templater.outputs[idx], refs, v.BuildArgPairs(),
+			buildContext, buildPush,
+		) {
+			utils.RunCommand(cmd[0], cmd[1:]...)
+		}
+	}
+}
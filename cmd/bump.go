@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// The source of a rule refreshing the digest of an image reference.
+const bumpDigestSource = "digest"
+
+var (
+	bumpRules  []string
+	bumpDryRun bool
+
+	bumpCMD = &cobra.Command{
+		Use:   "bump",
+		Short: "Update version keys of the variants definition",
+		Long: "Update the values of the variants definition selected by the rules to the latest tag of " +
+			"an image satisfying a version constraint or refresh the digests of pinned image references, " +
+			"the file is edited in place keeping its comments and formatting",
+		Args: cobra.NoArgs,
+		Run:  runBump,
+	}
+)
+
+func init() {
+	bumpCMD.Flags().StringArrayVar(
+		&bumpRules, "rule", make([]string, 0),
+		"Rule selecting a key path of the variants and how to bump it, either "+
+			"<key>=<image>[:<constraint>] to update it to the latest tag of the image satisfying the "+
+			"constraint (default: patch versions of the current value) or <key>="+bumpDigestSource+
+			" to refresh the digest of the image reference it contains",
+	)
+	bumpCMD.Flags().BoolVar(
+		&bumpDryRun, "dry-run", false,
+		"Only report the updates without writing the variants definition",
+	)
+	addFormatFlag(bumpCMD)
+
+	TemplaterCMD.AddCommand(bumpCMD)
+}
+
+// A rule of the bump command.
+type bumpRule struct {
+	Key   string
+	Image string
+	// The version constraint, empty to select the patch versions of the
+	// current value.
+	Constraint string
+}
+
+// Returns whether the rule refreshes a digest.
+func (r bumpRule) isDigest() bool {
+	return r.Image == ""
+}
+
+// Parses a rule given as <key>=<image>[:<constraint>] or <key>=digest.
+func parseBumpRule(raw string) bumpRule {
+	key, source, ok := strings.Cut(raw, "=")
+	if !ok || key == "" || source == "" {
+		utils.Error(
+			"Invalid rule '%s', must be <key>=<image>[:<constraint>] or <key>=%s",
+			raw, bumpDigestSource,
+		)
+	}
+
+	rule := bumpRule{Key: key}
+	if source == bumpDigestSource {
+		return rule
+	}
+
+	rule.Image = source
+	// The port of a registry is followed by the repository, not a constraint
+	if idx := strings.LastIndex(source, ":"); idx >= 0 && !strings.Contains(source[idx+1:], "/") {
+		rule.Image, rule.Constraint = source[:idx], source[idx+1:]
+	}
+
+	return rule
+}
+
+// An update of the bump command.
+type bumpEntry struct {
+	Key     string `json:"key" yaml:"key"`
+	Variant string `json:"variant" yaml:"variant"`
+	Current string `json:"current" yaml:"current"`
+	Bumped  string `json:"bumped" yaml:"bumped"`
+
+	node *yaml.Node
+}
+
+// A value of the variants definition a rule applies to.
+type bumpTarget struct {
+	// The name of the variant or defaults.
+	owner string
+	node  *yaml.Node
+}
+
+// Returns the scalar values at the key path in the defaults and variants
+// of the documents of the variants definition.
+func bumpTargets(docs []*yaml.Node, variantsKey string, keyPath []string) []bumpTarget {
+	var targets []bumpTarget
+
+	add := func(owner string, node *yaml.Node) {
+		if node == nil {
+			return
+		}
+		if node.Kind != yaml.ScalarNode {
+			utils.Warn("The value of '%s' of '%s' is no scalar", strings.Join(keyPath, "."), owner)
+			return
+		}
+		targets = append(targets, bumpTarget{owner, node})
+	}
+
+	for _, doc := range docs {
+		list := utils.GetYMLNodeByPath(doc, nil)
+		if variantsKey != "" && variantsKey != "." {
+			add("defaults", utils.GetYMLNodeByPath(doc, append([]string{"defaults"}, keyPath...)))
+			list = utils.GetYMLNodeByPath(doc, strings.Split(variantsKey, "."))
+		}
+
+		if list == nil || list.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		for _, item := range list.Content {
+			owner := "unnamed variant"
+			if name := utils.GetYMLNodeByPath(item, []string{"name"}); name != nil {
+				owner = name.Value
+			}
+			add(owner, utils.GetYMLNodeByPath(item, keyPath))
+		}
+	}
+
+	return targets
+}
+
+// Bumps the values of the variants definition.
+type bumper struct {
+	tags    map[string][]string
+	digests map[string]string
+}
+
+// Returns the bumped value or an empty string if the value is up to date.
+func (b *bumper) bump(rule bumpRule, value string) (string, error) {
+	if rule.isDigest() {
+		return b.bumpDigest(value)
+	}
+
+	tags, ok := b.tags[rule.Image]
+	if !ok {
+		var err error
+		tags, err = utils.ListImageTags(utils.ParseImageReference(rule.Image))
+		if err != nil {
+			return "", err
+		}
+		b.tags[rule.Image] = tags
+	}
+
+	return utils.BumpVersion(value, rule.Constraint, tags)
+}
+
+// Returns the image reference with the current digest of its tag or an
+// empty string if the digest is up to date.
+func (b *bumper) bumpDigest(value string) (string, error) {
+	image := utils.ParseImageReference(value)
+	if image.Tag == "" || image.Digest == "" {
+		return "", fmt.Errorf("'%s' is no image reference with a tag and digest", value)
+	}
+
+	digest, ok := b.digests[value]
+	if !ok {
+		var err error
+		digest, err = utils.ImageDigest(image)
+		if err != nil {
+			return "", err
+		}
+		b.digests[value] = digest
+	}
+
+	if digest == image.Digest {
+		return "", nil
+	}
+
+	return strings.Replace(value, image.Digest, digest, 1), nil
+}
+
+func runBump(_ *cobra.Command, _ []string) {
+	if len(bumpRules) == 0 {
+		utils.Error("No rules given, add them with --rule")
+	}
+
+	variants := newVariants()
+	verifyFormat(outputFormat)
+
+	content := variants.readDefinition()
+
+	docs, err := utils.DecodeYMLNodes(content)
+	if err != nil {
+		utils.Error("Could not parse '%s': %s", variants.VariantsTplFile, err)
+	}
+
+	b := &bumper{
+		tags:    make(map[string][]string),
+		digests: make(map[string]string),
+	}
+
+	entries := make([]bumpEntry, 0)
+
+	for _, raw := range bumpRules {
+		rule := parseBumpRule(raw)
+
+		targets := bumpTargets(docs, variants.VariantsKey, strings.Split(rule.Key, "."))
+		if len(targets) == 0 {
+			utils.Warn("No variant defines the key '%s'", rule.Key)
+		}
+
+		for _, target := range targets {
+			bumped, err := b.bump(rule, target.node.Value)
+			if err != nil {
+				utils.Warn("Could not bump '%s' of '%s': %s", rule.Key, target.owner, err)
+				continue
+			}
+			if bumped == "" {
+				utils.Debug("'%s' of '%s' is up to date", rule.Key, target.owner)
+				continue
+			}
+
+			entries = append(entries, bumpEntry{
+				Key:     rule.Key,
+				Variant: target.owner,
+				Current: target.node.Value,
+				Bumped:  bumped,
+				node:    target.node,
+			})
+		}
+	}
+
+	printFormatted(entries, func(out io.Writer) {
+		if len(entries) == 0 {
+			utils.Info("All values are up to date")
+			return
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tVARIANT\tCURRENT\tBUMPED")
+
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Key, e.Variant, e.Current, e.Bumped)
+		}
+
+		if err := w.Flush(); err != nil {
+			utils.Error("%s", err)
+		}
+	})
+
+	if bumpDryRun || len(entries) == 0 {
+		return
+	}
+
+	values := make(map[*yaml.Node]string, len(entries))
+	for _, e := range entries {
+		values[e.node] = e.Bumped
+	}
+
+	updated, count := utils.ReplaceYMLNodes(content, values)
+	variants.writeDefinition(content, updated, count, len(values))
+}
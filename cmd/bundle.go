@@ -0,0 +1,377 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+const (
+	// The name of the manifest in a bundle.
+	bundleManifestFile = "bundle.yml"
+	// The directory of the input files in a bundle.
+	bundleFilesDir = "files"
+)
+
+var (
+	bundleCMD = &cobra.Command{
+		Use:   "bundle",
+		Short: "Export and render self-contained bundles of all inputs",
+		Long: "Export all inputs of a run (templates, variants, values, files read by the templates and " +
+			"responses of the network functions) to a single archive and render it on another host, " +
+			"e.g. an air-gapped build host",
+	}
+
+	bundleExportCMD = &cobra.Command{
+		Use:   "export <archive>",
+		Short: "Export the inputs of the run to an archive",
+		Long: "Render the Dockerfiles in memory to find all inputs and write them together with the " +
+			"configuration of the run to a gzip compressed tar archive",
+		Args: cobra.ExactArgs(1),
+		Run:  runBundleExport,
+	}
+
+	bundleRenderCMD = &cobra.Command{
+		Use:   "render <archive>",
+		Short: "Render the Dockerfiles from an exported archive",
+		Long: "Render the Dockerfiles with the inputs and configuration of an exported archive, the network " +
+			"functions are answered with the recorded responses. Flags given on the commandline take " +
+			"precedence over the configuration of the archive",
+		Args: cobra.ExactArgs(1),
+		Run:  runBundleRender,
+	}
+)
+
+func init() {
+	bundleCMD.AddCommand(bundleExportCMD)
+	bundleCMD.AddCommand(bundleRenderCMD)
+
+	TemplaterCMD.AddCommand(bundleCMD)
+}
+
+// The flags holding paths of inputs, they are stored relative to the
+// working directory in a bundle.
+var bundlePathFlags = []string{
+	dockerfileTplFlag,
+	dockerfileTplDirFlag,
+	dockerfileBaseTplFlag,
+	tplRootsFlag,
+	variantsDefFlag,
+	variantsCfgFlag,
+	variantsValuesFlag,
+	dockerignoreTplFlag,
+	policyFilesFlag,
+}
+
+// The flags configuring the host or the location of the outputs, they are
+// not stored in a bundle but taken from the render command.
+var bundleHostFlags = []string{
+	outDirFlag,
+	scanReportFlag,
+	provenanceFileFlag,
+	provenanceKeyFlag,
+	caFileFlag,
+	networkTimeoutFlag,
+	networkRetriesFlag,
+	networkBackoffFlag,
+	logFileFlag,
+	logSizeFlag,
+	logKeepFlag,
+}
+
+// The output paths of the host, they are resolved before rendering inside
+// the extracted bundle.
+var bundleOutputFlags = []string{
+	outDirFlag,
+	scanReportFlag,
+	provenanceFileFlag,
+}
+
+// A file of a bundle.
+type bundleFile struct {
+	// The path relative to the working directory with forward slashes.
+	Path   string `yaml:"path"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// The manifest of a bundle.
+type bundleManifest struct {
+	// The version of the templater which exported the bundle.
+	Version   string                   `yaml:"version"`
+	Settings  map[string]interface{}   `yaml:"settings"`
+	Files     []bundleFile             `yaml:"files"`
+	Responses []utils.RecordedResponse `yaml:"responses,omitempty"`
+}
+
+// Returns whether the flag is one of the flags.
+func isBundleFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the path relative to the working directory with forward slashes,
+// fails if it is outside of the working directory.
+func bundlePath(cwd string, file string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		utils.Error("%s", err)
+	}
+
+	rel, err := filepath.Rel(cwd, abs)
+	if err != nil || !filepath.IsLocal(rel) {
+		utils.Error(
+			"'%s' is outside of the working directory and cannot be bundled", file,
+		)
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// Returns the settings of the run stored in a bundle, paths are made
+// relative to the working directory.
+func bundleSettings(cwd string) map[string]interface{} {
+	settings := make(map[string]interface{})
+
+	for _, key := range viper.AllKeys() {
+		if isBundleFlag(bundleHostFlags, key) {
+			continue
+		}
+
+		if !isBundleFlag(bundlePathFlags, key) {
+			settings[key] = viper.Get(key)
+			continue
+		}
+
+		if p, ok := viper.Get(key).(string); ok {
+			if p != "" {
+				p = bundlePath(cwd, p)
+			}
+			settings[key] = p
+			continue
+		}
+
+		paths := viper.GetStringSlice(key)
+		for idx, p := range paths {
+			paths[idx] = bundlePath(cwd, p)
+		}
+		settings[key] = paths
+	}
+
+	return settings
+}
+
+// Returns the files of the policies, directories are walked.
+func policyInputFiles(policies []string) []string {
+	var files []string
+
+	for _, policy := range policies {
+		err := filepath.WalkDir(policy, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			utils.Error("Could not list the policies in '%s': %s", policy, err)
+		}
+	}
+
+	return files
+}
+
+func runBundleExport(_ *cobra.Command, args []string) {
+	archive := args[0]
+
+	templater := newTemplater()
+	variants := newVariants()
+
+	verifyDataLayout(templater.DataLayout)
+	initTemplateFuncs(templater)
+	utils.RecordAccess()
+
+	templater.loadVariants(variants)
+	templater.initTemplate()
+
+	for _, v := range variants.Variants {
+		templater.renderDockerfile(v)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		utils.Error("%s", err)
+	}
+
+	inputs := templater.inputFiles(variants)
+	if _, err := os.Stat(templater.DockerfileTpl + schemaFileSuffix); err == nil {
+		inputs = append(inputs, templater.DockerfileTpl+schemaFileSuffix)
+	}
+	inputs = append(inputs, policyInputFiles(templater.PolicyFiles)...)
+	inputs = append(inputs, utils.RecordedFiles()...)
+
+	m := bundleManifest{
+		Version:   version,
+		Settings:  bundleSettings(cwd),
+		Responses: utils.RecordedResponses(),
+	}
+
+	contents := make(map[string][]byte)
+	for _, input := range inputs {
+		// The settings of the configuration file are part of the bundle
+		if input == config {
+			continue
+		}
+
+		rel := bundlePath(cwd, input)
+		if _, ok := contents[rel]; ok {
+			continue
+		}
+
+		info, err := os.Stat(input)
+		if err != nil {
+			utils.Error("%s", err)
+		}
+		if !info.Mode().IsRegular() {
+			utils.Debug("Not bundling '%s' which is no regular file", input)
+			continue
+		}
+
+		content, err := os.ReadFile(input)
+		if err != nil {
+			utils.Error("Could not read '%s': %s", input, err)
+		}
+
+		sum := sha256.Sum256(content)
+		contents[rel] = content
+		m.Files = append(m.Files, bundleFile{Path: rel, SHA256: hex.EncodeToString(sum[:])})
+	}
+
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Path < m.Files[j].Path
+	})
+
+	manifestContent, err := yaml.Marshal(m)
+	if err != nil {
+		utils.Error("Could not encode the bundle manifest: %s", err)
+	}
+
+	files := []utils.ArchiveFile{{Name: bundleManifestFile, Content: manifestContent, Mode: 0o644}}
+	for _, f := range m.Files {
+		files = append(files, utils.ArchiveFile{
+			Name:    path.Join(bundleFilesDir, f.Path),
+			Content: contents[f.Path],
+			Mode:    0o644,
+		})
+	}
+
+	if err := utils.WriteArchive(archive, files); err != nil {
+		utils.Error("Could not write the bundle '%s': %s", archive, err)
+	}
+
+	utils.Info(
+		"Exported %d file(s) and %d response(s) to '%s'", len(m.Files), len(m.Responses), archive,
+	)
+}
+
+// Extracts the bundle to the directory and returns its manifest after
+// verifying the digests of its files.
+func extractBundle(archive string, dir string) *bundleManifest {
+	if err := utils.ExtractArchive(archive, dir); err != nil {
+		utils.Error("Could not extract the bundle '%s': %s", archive, err)
+	}
+
+	m := &bundleManifest{}
+	if _, err := os.Stat(filepath.Join(dir, bundleManifestFile)); errors.Is(err, fs.ErrNotExist) {
+		utils.Error("'%s' is no bundle, it contains no %s", archive, bundleManifestFile)
+	}
+	utils.LoadYMLFromFile(filepath.Join(dir, bundleManifestFile), m)
+
+	for _, f := range m.Files {
+		content, err := os.ReadFile(filepath.Join(dir, bundleFilesDir, filepath.FromSlash(f.Path)))
+		if err != nil {
+			utils.Error("The bundle '%s' is incomplete: %s", archive, err)
+		}
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != f.SHA256 {
+			utils.Error("The digest of '%s' in the bundle '%s' does not match", f.Path, archive)
+		}
+	}
+
+	if m.Version != version {
+		utils.Warn(
+			"The bundle was exported with version '%s' of the templater, this is version '%s'",
+			m.Version, version,
+		)
+	}
+
+	return m
+}
+
+func runBundleRender(cmd *cobra.Command, args []string) {
+	archive := args[0]
+
+	// The outputs are written relative to the working directory of the host
+	for _, flag := range bundleOutputFlags {
+		if val := viper.GetString(flag); val != "" {
+			abs, err := filepath.Abs(val)
+			if err != nil {
+				utils.Error("%s", err)
+			}
+			viper.Set(flag, abs)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "dtpl-bundle-")
+	if err != nil {
+		utils.Error("%s", err)
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(dir); err != nil {
+			utils.Warn("Could not remove the extracted bundle '%s': %s", dir, err)
+		}
+	}
+	// Errors exit without running deferred functions
+	utils.AtExit(cleanup)
+	defer cleanup()
+
+	m := extractBundle(archive, dir)
+	utils.Info("Rendering %d file(s) of the bundle '%s'", len(m.Files), archive)
+
+	for key, val := range m.Settings {
+		if cmd.Flags().Changed(key) || isBundleFlag(bundleHostFlags, key) {
+			continue
+		}
+		viper.Set(key, val)
+	}
+
+	files := filepath.Join(dir, bundleFilesDir)
+	if err := os.MkdirAll(files, 0o755); err != nil {
+		utils.Error("%s", err)
+	}
+	if err := os.Chdir(files); err != nil {
+		utils.Error("%s", err)
+	}
+
+	// The configuration file is applied by the settings of the bundle
+	config = ""
+	utils.ReplayResponses(m.Responses)
+
+	run(cmd, nil)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// The key of the scheduling metadata of a variant, it is passed to the
+// generated build jobs.
+const ciKey = "ci"
+
+// The scheduling metadata of a variant.
+type ciMetadata struct {
+	// The tags of the runners the variant must be built on, either a
+	// label (gpu) or a label with a value (accelerator=nvidia).
+	Tags []string `yaml:"tags"`
+	// The maximum duration of the build, e.g. 2h.
+	Timeout string `yaml:"timeout"`
+	// The priority of the build, e.g. high.
+	Priority string `yaml:"priority"`
+
+	timeout time.Duration
+}
+
+// Returns the scheduling metadata of a variant, empty if it has none.
+func (v *variant) CI() ciMetadata {
+	var ci ciMetadata
+
+	raw, ok := v.Data[ciKey]
+	if !ok || raw == nil {
+		return ci
+	}
+
+	if _, ok := raw.(map[string]interface{}); !ok {
+		utils.Error(
+			"Invalid value '%v' for '%s' of variant '%s', must be a map",
+			raw, ciKey, *v.Name,
+		)
+	}
+
+	content, err := yaml.Marshal(raw)
+	if err != nil {
+		utils.Error("%s", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&ci); err != nil {
+		utils.Error("Invalid '%s' of variant '%s': %s", ciKey, *v.Name, err)
+	}
+
+	if ci.Timeout != "" {
+		ci.timeout, err = time.ParseDuration(ci.Timeout)
+		if err != nil || ci.timeout <= 0 {
+			utils.Error(
+				"Invalid timeout '%s' in '%s' of variant '%s', must be a positive duration (e.g. 90m)",
+				ci.Timeout, ciKey, *v.Name,
+			)
+		}
+	}
+
+	return ci
+}
+
+// Returns the runner tags as labels, tags without value are set to true.
+func (ci ciMetadata) TagLabels() map[string]string {
+	if len(ci.Tags) == 0 {
+		return nil
+	}
+
+	labels := make(map[string]string, len(ci.Tags))
+	for _, tag := range ci.Tags {
+		key, val, ok := strings.Cut(tag, "=")
+		if !ok {
+			val = "true"
+		}
+		labels[key] = val
+	}
+
+	return labels
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+var (
+	cleanCMD = &cobra.Command{
+		Use:   "clean",
+		Short: "Remove the files generated by previous runs",
+		Long: "Remove the files listed in the manifest of the output directory, " +
+			"other files in the output directory are not touched",
+		Args: cobra.NoArgs,
+		Run:  runClean,
+	}
+)
+
+func init() {
+	TemplaterCMD.AddCommand(cleanCMD)
+}
+
+// Removes the file if it exists.
+func removeFile(path string) {
+	err := os.Remove(path)
+	if err == nil {
+		utils.Info("Removed '%s'", path)
+		return
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		utils.Error(
+			"Could not remove '%s': %s", path, err,
+		)
+	}
+}
+
+// Removes the empty parent directories of a file up to the root.
+func removeEmptyParents(path string, root string) {
+	for dir := filepath.Dir(path); dir != root && len(dir) > len(root); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		utils.Debug("Removed empty directory '%s'", dir)
+	}
+}
+
+func runClean(_ *cobra.Command, _ []string) {
+	dir, err := filepath.Abs(viper.GetString(outDirFlag))
+	if err != nil {
+		utils.Error("%s", err)
+	}
+
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		utils.Info(
+			"Nothing to clean, the output directory '%s' does not exist", dir,
+		)
+		return
+	}
+
+	lock := lockOutputDir(dir, viper.GetDuration(outWaitFlag))
+	defer lock.Release()
+
+	m := readManifest(dir)
+	if m == nil {
+		utils.Info(
+			"Nothing to clean, there is no manifest in '%s'", dir,
+		)
+		return
+	}
+
+	for _, file := range m.Files {
+		if err := utils.ValidateFileName(file.Path); err != nil {
+			utils.Error(
+				"Refusing to remove file of manifest: %s", err,
+			)
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(file.Path))
+		removeFile(path)
+		removeEmptyParents(path, dir)
+	}
+
+	removeFile(filepath.Join(dir, manifestFile))
+}
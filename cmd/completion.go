@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Registers the dynamic completions of the flags, the flags must be defined.
+func registerCompletions() {
+	for _, flag := range []string{
+		tplAdditionalVarsFlag, tplStringVarsFlag, tplJSONVarsFlag,
+	} {
+		_ = TemplaterCMD.RegisterFlagCompletionFunc(flag, completeVariableKeys)
+	}
+}
+
+// Loads the variants for completions with the flags given so far.
+// Failures exit without completions.
+func completionVariants() (*templater, *variants) {
+	loadConfig()
+
+	templater := newTemplater()
+	variants := newVariants()
+
+	initTemplateFuncs(templater)
+	templater.loadVariants(variants)
+
+	return templater, variants
+}
+
+// Completes the name of a variant as the first argument.
+func completeVariantNames(
+	_ *cobra.Command,
+	args []string,
+	_ string,
+) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	_, variants := completionVariants()
+
+	names := make([]string, 0, len(variants.Variants))
+	for _, v := range variants.Variants {
+		// Only the first line of a description is shown as hint
+		description, _, _ := strings.Cut(v.Description(), "\n")
+		names = append(names, *v.Name+"\t"+description)
+	}
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// Completes the key paths of the variant data for the variable flags,
+// optionally prefixed with the name of the variant (<variant>:<key>).
+func completeVariableKeys(
+	_ *cobra.Command,
+	_ []string,
+	toComplete string,
+) ([]string, cobra.ShellCompDirective) {
+	if strings.Contains(toComplete, "=") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	_, variants := completionVariants()
+
+	name, _, scoped := strings.Cut(toComplete, ":")
+
+	var completions []string
+	seen := make(map[string]bool)
+
+	for _, v := range variants.Variants {
+		if scoped && *v.Name != name {
+			continue
+		}
+		if !scoped {
+			completions = append(completions, *v.Name+":")
+		}
+
+		for _, path := range keyPaths(v.TemplateData(), "") {
+			if scoped {
+				path = name + ":" + path
+			}
+			if !seen[path] {
+				seen[path] = true
+				completions = append(completions, path+"=")
+			}
+		}
+	}
+
+	sort.Strings(completions)
+
+	return completions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
+
+// Returns the dotted key paths of all values in the data, including the
+// paths of nested maps.
+func keyPaths(data map[string]interface{}, prefix string) []string {
+	var paths []string
+
+	for key, val := range data {
+		path := prefix + key
+		paths = append(paths, path)
+
+		if nested, ok := val.(map[string]interface{}); ok {
+			paths = append(paths, keyPaths(nested, path+".")...)
+		}
+	}
+
+	return paths
+}
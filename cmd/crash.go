@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	rtdebug "runtime/debug"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Recovers from a panic while rendering a variant, writes a diagnostic
+// bundle and fails with its path. Must be deferred.
+func (t *templater) recoverPanic(variant *variant) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	stack := rtdebug.Stack()
+
+	dir, err := writeCrashBundle(t, variant, r, stack)
+	if err != nil {
+		utils.Error(
+			"Unexpected error rendering variant '%s': %v (could not write the diagnostic bundle: %s)\n\n%s",
+			*variant.Name, r, err, stack,
+		)
+	}
+
+	utils.Error(
+		"Unexpected error rendering variant '%s': %v. A diagnostic bundle was written to '%s', "+
+			"please attach it when reporting the issue (check it for secrets first)",
+		*variant.Name, r, dir,
+	)
+}
+
+// Writes the panic, the stack trace, the resolved data of the variant, the
+// names of the parsed templates and the version to a temporary directory
+// and returns its path.
+func writeCrashBundle(
+	t *templater,
+	variant *variant,
+	cause interface{},
+	stack []byte,
+) (string, error) {
+	dir, err := os.MkdirTemp("", "dtpl-crash-")
+	if err != nil {
+		return "", err
+	}
+
+	data, err := yaml.Marshal(variant.TemplateData())
+	if err != nil {
+		data = []byte(fmt.Sprintf("could not encode the variant data: %s\n", err))
+	}
+
+	version, err := json.MarshalIndent(newVersionInfo(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	files := map[string][]byte{
+		"panic.txt":     []byte(fmt.Sprintf("%v\n\n%s", cause, stack)),
+		"variant.yml":   data,
+		"templates.txt": []byte(strings.Join(t.templateNames(), "\n") + "\n"),
+		"version.json":  version,
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+			return "", err
+		}
+	}
+
+	return dir, nil
+}
+
+// Returns the sorted names of all parsed templates, prefixed with the base
+// template they belong to if variants select their own.
+func (t *templater) templateNames() []string {
+	var names []string
+
+	for base, tpl := range t.templates {
+		for _, def := range tpl.Templates() {
+			name := def.Name()
+			if base != "" {
+				name = base + ": " + name
+			}
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// The extension of variants definitions evaluated with CUE.
+const cueExtension = ".cue"
+
+// Returns whether the variants definition is a CUE file.
+func (t *variants) isCUE() bool {
+	return filepath.Ext(t.VariantsTplFile) == cueExtension
+}
+
+// Exports the CUE variants definition, validating it against the
+// constraints it contains, and returns the resulting yml.
+func (t *variants) exportCUE() []byte {
+	utils.Debug(
+		"Exporting variants from '%s' with cue", t.VariantsTplFile,
+	)
+
+	res, err := utils.ExportCUE(t.VariantsTplFile)
+	if err != nil {
+		utils.Error(
+			"Could not export the variants definition '%s': %s", t.VariantsTplFile, err,
+		)
+	}
+
+	return res
+}
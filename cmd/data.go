@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Supported layouts of the data passed to the Dockerfile template.
+const (
+	// All variant data is passed flat to the template (e.g. .image.name).
+	dataLayoutFlat = "v1"
+	// The data is namespaced (e.g. .Variant.image.name or .Values.key).
+	dataLayoutNamespaced = "v2"
+)
+
+// Verifies that the data layout is supported and fails if not.
+func verifyDataLayout(layout string) {
+	if layout != dataLayoutFlat && layout != dataLayoutNamespaced {
+		utils.Error(
+			"Invalid data layout '%s', must be one of '%s' or '%s'",
+			layout, dataLayoutFlat, dataLayoutNamespaced,
+		)
+	}
+}
+
+// Returns the environment as map, it is empty in hermetic mode.
+func environment() map[string]interface{} {
+	env := make(map[string]interface{})
+	if utils.IsHermetic() {
+		return env
+	}
+
+	for _, entry := range os.Environ() {
+		if key, val, ok := strings.Cut(entry, "="); ok {
+			env[key] = val
+		}
+	}
+
+	return env
+}
+
+// The environment variable which fixes the build time for reproducible
+// builds, see https://reproducible-builds.org/specs/source-date-epoch/
+const sourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// The time of the templater run, it is shared by all variants.
+var buildDate time.Time
+
+// Returns the time of the templater run. The time can be fixed with
+// --time.now or SOURCE_DATE_EPOCH, the flag takes precedence.
+func buildTime() time.Time {
+	if !buildDate.IsZero() {
+		return buildDate
+	}
+
+	buildDate = time.Now().UTC()
+
+	if now := viper.GetString(timeNowFlag); now != "" {
+		buildDate = parseTime(now, "--"+timeNowFlag)
+		utils.Debug("Using %s as build time", buildDate.Format(time.RFC3339))
+	} else if epoch, ok := os.LookupEnv(sourceDateEpochEnv); ok && epoch != "" {
+		buildDate = parseTime(epoch, sourceDateEpochEnv)
+		utils.Debug(
+			"Using %s %s as build time", sourceDateEpochEnv, epoch,
+		)
+	}
+
+	return buildDate
+}
+
+// Parses a unix timestamp or an RFC 3339 time, fails if the value is
+// neither.
+func parseTime(value string, source string) time.Time {
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil && sec >= 0 {
+		return time.Unix(sec, 0).UTC()
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		utils.Error(
+			"Invalid value '%s' for %s, must be a unix timestamp or an RFC 3339 time",
+			value, source,
+		)
+	}
+
+	return t.UTC()
+}
+
+// Returns the time zone the template dates are formatted in.
+func templateZone() *time.Location {
+	zone := viper.GetString(timeZoneFlag)
+
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		utils.Error("Invalid time zone '%s': %s", zone, err)
+	}
+
+	return loc
+}
+
+// Returns the build metadata available to the templates.
+func buildMetadata() map[string]interface{} {
+	return map[string]interface{}{
+		"Version": version,
+		"Date":    buildTime().Format(time.RFC3339),
+	}
+}
+
+// Returns the namespaced template data of a variant:
+//
+//   - .Variant: The data of the variant
+//   - .Values: The values the variants template was rendered with
+//   - .Env: The environment variables
+//   - .Build: Metadata about the templater run
+func namespacedData(
+	v *variant,
+	values map[string]interface{},
+) map[string]interface{} {
+	return map[string]interface{}{
+		"Variant": v.Data,
+		"Values":  utils.CopyMap(values),
+		"Env":     environment(),
+		"Build":   buildMetadata(),
+	}
+}
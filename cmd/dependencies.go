@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// The key variants use to declare the variants whose images they build on.
+const dependsOnKey = "depends_on"
+
+// Returns the names of the variants the variant depends on. The key may
+// either be a single name or a list of names.
+func (v *variant) DependsOn() []string {
+	switch deps := v.Data[dependsOnKey].(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{deps}
+	case []interface{}:
+		names := make([]string, 0, len(deps))
+		for _, name := range deps {
+			s, ok := name.(string)
+			if !ok {
+				utils.Error(
+					"Invalid value '%v' in '%s' of variant '%s', variant names must be strings",
+					name, dependsOnKey, *v.Name,
+				)
+			}
+			names = append(names, s)
+		}
+		return names
+	default:
+		utils.Error(
+			"Invalid value '%v' for '%s' of variant '%s', must be a variant name or a list of names",
+			deps, dependsOnKey, *v.Name,
+		)
+	}
+
+	return nil
+}
+
+// Records the base images of a rendered Dockerfile to detect variants
+// building on the images of other variants. Not kept when streaming since
+// the dependencies are only used by commands loading all variants.
+func (t *templater) collectFromImages(v *variant, rendered []byte) {
+	if t.streaming {
+		return
+	}
+	if t.fromImages == nil {
+		t.fromImages = make(map[string][]string)
+	}
+	t.fromImages[*v.Name] = utils.BaseImages(rendered, v.BuildArgs())
+}
+
+// Returns the normalized name and tag of an image reference, empty for
+// references without tag.
+func imageKey(ref string) string {
+	image := utils.ParseImageReference(ref)
+	if image.Tag == "" {
+		return ""
+	}
+	return image.Domain + "/" + image.Repository + ":" + image.Tag
+}
+
+// Returns the indices of the variants each variant depends on, declared
+// with the depends_on key or detected from the base images of the rendered
+// Dockerfiles (by variant name) referencing the images of other variants.
+func variantDependencies(variants []*variant, fromImages map[string][]string) [][]int {
+	indices := make(map[string]int, len(variants))
+	images := make(map[string]int)
+	for idx, v := range variants {
+		indices[*v.Name] = idx
+		for _, ref := range v.ImageRefs("") {
+			if key := imageKey(ref); key != "" {
+				images[key] = idx
+			}
+		}
+	}
+
+	deps := make([][]int, len(variants))
+	add := func(idx int, dep int) {
+		// Dependencies set in the defaults also apply to the variant itself
+		if dep == idx {
+			return
+		}
+		for _, d := range deps[idx] {
+			if d == dep {
+				return
+			}
+		}
+		deps[idx] = append(deps[idx], dep)
+	}
+
+	for idx, v := range variants {
+		for _, name := range v.DependsOn() {
+			dep, ok := indices[name]
+			if !ok {
+				utils.Error(
+					"Variant '%s' depends on the unknown variant '%s'", *v.Name, name,
+				)
+			}
+			add(idx, dep)
+		}
+
+		for _, ref := range fromImages[*v.Name] {
+			dep, ok := images[imageKey(ref)]
+			if !ok || dep == idx {
+				continue
+			}
+			utils.Debug(
+				"Variant '%s' builds on the image '%s' of variant '%s'",
+				*v.Name, ref, *variants[dep].Name,
+			)
+			add(idx, dep)
+		}
+	}
+
+	return deps
+}
+
+// Returns the names of the variants each variant depends on by name.
+func dependencyNames(variants []*variant, fromImages map[string][]string) map[string][]string {
+	names := make(map[string][]string, len(variants))
+	for idx, deps := range variantDependencies(variants, fromImages) {
+		for _, dep := range deps {
+			names[*variants[idx].Name] = append(names[*variants[idx].Name], *variants[dep].Name)
+		}
+	}
+	return names
+}
+
+// Returns the dependency level of each variant, variants without
+// dependencies are on level 0 and all others one level above their
+// highest dependency. Fails if the dependencies are cyclic.
+func dependencyLevels(variants []*variant, fromImages map[string][]string) []int {
+	deps := variantDependencies(variants, fromImages)
+	levels := make([]int, len(variants))
+	// 0: unvisited, 1: visiting, 2: done
+	state := make([]int, len(variants))
+
+	var visit func(idx int, chain []string) int
+	visit = func(idx int, chain []string) int {
+		name := *variants[idx].Name
+		switch state[idx] {
+		case 1:
+			for pos, n := range chain {
+				if n == name {
+					chain = chain[pos:]
+					break
+				}
+			}
+			utils.Error(
+				"Cyclic variant dependencies detected: %s",
+				strings.Join(append(chain, name), " -> "),
+			)
+		case 2:
+			return levels[idx]
+		}
+
+		state[idx] = 1
+		for _, dep := range deps[idx] {
+			if level := visit(dep, append(chain, name)) + 1; level > levels[idx] {
+				levels[idx] = level
+			}
+		}
+		state[idx] = 2
+
+		return levels[idx]
+	}
+
+	for idx := range variants {
+		visit(idx, nil)
+	}
+
+	return levels
+}
+
+// Returns the indices of the variants in build order, dependencies are
+// built before the variants depending on them. The order of the variants
+// is kept otherwise.
+func buildOrder(variants []*variant, fromImages map[string][]string) []int {
+	levels := dependencyLevels(variants, fromImages)
+
+	order := make([]int, len(variants))
+	for idx := range order {
+		order[idx] = idx
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return levels[order[i]] < levels[order[j]]
+	})
+
+	return order
+}
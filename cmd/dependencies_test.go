@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Returns the variants decoded from the yml definitions.
+func testVariants(t *testing.T, defs ...string) []*variant {
+	t.Helper()
+
+	variants := make([]*variant, 0, len(defs))
+	for _, def := range defs {
+		variants = append(variants, testVariant(t, def))
+	}
+
+	return variants
+}
+
+// Returns the names of the variants in the order of the indices.
+func variantNames(variants []*variant, order []int) []string {
+	names := make([]string, 0, len(order))
+	for _, idx := range order {
+		names = append(names, *variants[idx].Name)
+	}
+	return names
+}
+
+func TestBuildOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		defs       []string
+		fromImages map[string][]string
+		want       []string
+		levels     []int
+	}{
+		{
+			name: "no dependencies",
+			defs: []string{
+				"{name: a, image: {name: acme/a, tag: '1'}}",
+				"{name: b, image: {name: acme/b, tag: '1'}}",
+			},
+			want:   []string{"a", "b"},
+			levels: []int{0, 0},
+		},
+		{
+			name: "declared",
+			defs: []string{
+				"{name: app, image: {name: acme/app, tag: '1'}, depends_on: base}",
+				"{name: tool, image: {name: acme/tool, tag: '1'}}",
+				"{name: base, image: {name: acme/base, tag: '1'}}",
+			},
+			want:   []string{"tool", "base", "app"},
+			levels: []int{1, 0, 0},
+		},
+		{
+			name: "declared list and chain",
+			defs: []string{
+				"{name: c, image: {name: acme/c, tag: '1'}, depends_on: [a, b]}",
+				"{name: b, image: {name: acme/b, tag: '1'}, depends_on: [a]}",
+				"{name: a, image: {name: acme/a, tag: '1'}}",
+			},
+			want:   []string{"a", "b", "c"},
+			levels: []int{2, 1, 0},
+		},
+		{
+			name: "detected from FROM",
+			defs: []string{
+				"{name: app, image: {name: acme/app, tag: '1'}}",
+				"{name: base, image: {name: acme/base, tag: '1', tags: [latest]}}",
+			},
+			fromImages: map[string][]string{
+				"app":  {"docker.io/acme/base:latest"},
+				"base": {"debian:12"},
+			},
+			want:   []string{"base", "app"},
+			levels: []int{1, 0},
+		},
+		{
+			name: "own image and other tags are ignored",
+			defs: []string{
+				"{name: app, image: {name: acme/app, tag: '2'}}",
+				"{name: base, image: {name: acme/base, tag: '1'}}",
+			},
+			fromImages: map[string][]string{
+				"app": {"acme/app:2", "acme/base:0.9"},
+			},
+			want:   []string{"app", "base"},
+			levels: []int{0, 0},
+		},
+		{
+			name: "defaults depending on a variant",
+			defs: []string{
+				"{name: base, image: {name: acme/base, tag: '1'}, depends_on: base}",
+				"{name: app, image: {name: acme/app, tag: '1'}, depends_on: base}",
+			},
+			want:   []string{"base", "app"},
+			levels: []int{0, 1},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			variants := testVariants(t, tc.defs...)
+
+			if got := dependencyLevels(variants, tc.fromImages); !reflect.DeepEqual(got, tc.levels) {
+				t.Errorf("dependencyLevels() = %v, want %v", got, tc.levels)
+			}
+			if got := variantNames(variants, buildOrder(variants, tc.fromImages)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("buildOrder() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCollectFromImages(t *testing.T) {
+	tpl := &templater{}
+	variants := testVariants(t,
+		"{name: base, image: {name: acme/base, tag: '1'}}",
+		"{name: app, image: {name: acme/app, tag: '1'}, build_args: {BASE: acme/base:1}}",
+	)
+	tpl.Resolve(variants)
+
+	rendered := []byte("ARG BASE\nFROM ${BASE} AS build\nFROM build\n")
+	tpl.collectFromImages(variants[1], rendered)
+
+	want := map[string][]string{"app": {"base"}}
+	if got := dependencyNames(variants, tpl.fromImages); !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencyNames() = %v, want %v", got, want)
+	}
+
+	// Nothing is kept per variant when streaming
+	streamed := &templater{streaming: true}
+	streamed.collectFromImages(variants[1], rendered)
+	if streamed.fromImages != nil {
+		t.Errorf("fromImages = %v when streaming, want nil", streamed.fromImages)
+	}
+}
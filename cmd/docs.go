@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+var (
+	docsMan      bool
+	docsMarkdown bool
+	docsDir      string
+
+	genCMD = &cobra.Command{
+		Use:   "gen",
+		Short: "Generate files describing the templater",
+		Args:  cobra.NoArgs,
+	}
+
+	docsCMD = &cobra.Command{
+		Use:   "docs",
+		Short: "Generate the reference documentation of the commands and flags",
+		Long: "Generate man pages or markdown files describing all commands and their flags, " +
+			"one file per command",
+		Args: cobra.NoArgs,
+		Run:  runDocs,
+	}
+)
+
+func init() {
+	docsCMD.Flags().BoolVar(
+		&docsMan, "man", false, "Generate man pages",
+	)
+	docsCMD.Flags().BoolVar(
+		&docsMarkdown, "markdown", false, "Generate markdown files",
+	)
+	docsCMD.MarkFlagsOneRequired("man", "markdown")
+	docsCMD.MarkFlagsMutuallyExclusive("man", "markdown")
+
+	docsCMD.Flags().StringVar(
+		&docsDir, "dir", "docs",
+		"Directory to write the documentation to, it is created if it does not exist",
+	)
+
+	genCMD.AddCommand(docsCMD)
+	TemplaterCMD.AddCommand(genCMD)
+}
+
+func runDocs(_ *cobra.Command, _ []string) {
+	if err := os.MkdirAll(docsDir, os.ModePerm); err != nil {
+		utils.Error(
+			"Could not create directory '%s': %s", docsDir, err,
+		)
+	}
+
+	// The generation date would change the files on every run
+	TemplaterCMD.DisableAutoGenTag = true
+
+	var err error
+	if docsMan {
+		err = doc.GenManTree(TemplaterCMD, &doc.GenManHeader{
+			Title:   "TEMPLATER",
+			Section: "1",
+			Source:  "templater " + version,
+		}, docsDir)
+	} else {
+		err = doc.GenMarkdownTree(TemplaterCMD, docsDir)
+	}
+
+	if err != nil {
+		utils.Error("Could not generate the documentation: %s", err)
+	}
+
+	utils.Info("Wrote the documentation to '%s'", docsDir)
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// The Earthfile syntax version of the generated Earthfile.
+const earthlyVersion = "0.8"
+
+var (
+	earthlyContext  string
+	earthlyRegistry string
+	earthlyOut      string
+
+	earthlyCMD = &cobra.Command{
+		Use:   "earthly",
+		Short: "Render the Dockerfiles and generate an Earthfile",
+		Long: "Render the Dockerfiles and generate an Earthfile with a target per variant which " +
+			"builds the image of the variant from its Dockerfile and a target 'all' building all of them",
+		Args: cobra.NoArgs,
+		Run:  runEarthly,
+	}
+)
+
+func init() {
+	earthlyCMD.Flags().StringVar(
+		&earthlyContext, "context", ".",
+		"Build context of the Dockerfiles relative to the Earthfile",
+	)
+	earthlyCMD.Flags().StringVar(
+		&earthlyRegistry, "registry", "",
+		"Registry to push the images to, it replaces the registry of the image names",
+	)
+	earthlyCMD.Flags().StringVar(
+		&earthlyOut, "out", "Earthfile",
+		"File to write the Earthfile to",
+	)
+
+	TemplaterCMD.AddCommand(earthlyCMD)
+}
+
+// Matches characters which are not allowed in Earthly target names.
+var invalidTargetNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// The target building all variants.
+const earthlyAllTarget = "all"
+
+// Returns a valid Earthly target name for a variant.
+func earthlyTarget(variant string) string {
+	name := strings.Trim(
+		invalidTargetNameChars.ReplaceAllString(strings.ToLower(variant), "-"),
+		"-.",
+	)
+
+	if name == "" || name[0] < 'a' || name[0] > 'z' || name == earthlyAllTarget {
+		name = "v-" + name
+	}
+
+	return name
+}
+
+// Returns the Earthfile building the rendered Dockerfiles of the variants.
+func earthfile(variants []*variant, dockerfiles []string, fromImages map[string][]string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "VERSION %s\n", earthlyVersion)
+
+	targets := make([]string, 0, len(variants))
+	seen := make(map[string]string, len(variants))
+
+	for idx, v := range variants {
+		target := earthlyTarget(*v.Name)
+		if other, ok := seen[target]; ok {
+			utils.Error(
+				"Variants '%s' and '%s' map to the same Earthly target '%s'",
+				other, *v.Name, target,
+			)
+		}
+		seen[target] = *v.Name
+		targets = append(targets, target)
+
+		fmt.Fprintf(&b, "\n%s:\n", target)
+		fmt.Fprintf(&b, "    FROM DOCKERFILE")
+		for _, arg := range v.BuildArgPairs() {
+			fmt.Fprintf(&b, " --build-arg %s", strconv.Quote(arg))
+		}
+		fmt.Fprintf(
+			&b, " -f %s %s\n",
+			contextPath(dockerfiles[idx]), earthlyContext,
+		)
+		fmt.Fprintf(
+			&b, "    SAVE IMAGE --push %s\n",
+			strings.Join(v.ImageRefs(earthlyRegistry), " "),
+		)
+	}
+
+	fmt.Fprintf(&b, "\n%s:\n", earthlyAllTarget)
+
+	// The levels of dependencies are built one after the other, all but
+	// the last level are waited for to push the images they depend on
+	levels := dependencyLevels(variants, fromImages)
+	maxLevel := 0
+	for _, level := range levels {
+		if level > maxLevel {
+			maxLevel = level
+		}
+	}
+
+	for level := 0; level <= maxLevel; level++ {
+		indent := "    "
+		if level < maxLevel {
+			fmt.Fprintf(&b, "    WAIT\n")
+			indent += "    "
+		}
+		for idx, target := range targets {
+			if levels[idx] == level {
+				fmt.Fprintf(&b, "%sBUILD +%s\n", indent, target)
+			}
+		}
+		if level < maxLevel {
+			fmt.Fprintf(&b, "    END\n")
+		}
+	}
+
+	return b.String()
+}
+
+func runEarthly(_ *cobra.Command, _ []string) {
+	templater, variants := render()
+
+	utils.Info(
+		"Writing Earthfile to '%s'", earthlyOut,
+	)
+
+	content := earthfile(variants.Variants, templater.outputs, templater.fromImages)
+	if err := os.WriteFile(earthlyOut, []byte(content), 0o644); err != nil {
+		utils.Error(
+			"Could not write Earthfile to '%s': %s", earthlyOut, err,
+		)
+	}
+}
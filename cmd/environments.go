@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// The key of the environment specific overrides of a variant.
+const environmentsKey = "environments"
+
+// Merges the overrides of the environment over the data of the variant,
+// the environments are removed from the data in any case. Returns whether
+// the variant defines the environment.
+func (v *variant) ApplyEnvironment(env string, strategy string) bool {
+	envs, ok := v.Data[environmentsKey]
+	if !ok {
+		return false
+	}
+	delete(v.Data, environmentsKey)
+
+	if env == "" || envs == nil {
+		return false
+	}
+
+	name := "<unnamed>"
+	if v.Name != nil {
+		name = *v.Name
+	}
+
+	blocks, ok := envs.(map[string]interface{})
+	if !ok {
+		utils.Error(
+			"Invalid value '%v' for '%s' of variant '%s', must be a map of environments",
+			envs, environmentsKey, name,
+		)
+	}
+
+	block, ok := blocks[env]
+	if !ok || block == nil {
+		return false
+	}
+
+	overrides, ok := block.(map[string]interface{})
+	if !ok {
+		utils.Error(
+			"Invalid value '%v' for '%s.%s' of variant '%s', must be a map",
+			block, environmentsKey, env, name,
+		)
+	}
+
+	utils.Debug("Applying environment '%s' to variant '%s'", env, name)
+
+	var data map[string]interface{}
+	utils.ConvertYML(v, &data)
+
+	merged := utils.MergeMaps(data, utils.CopyMap(overrides), strategy)
+
+	*v = variant{}
+	utils.ConvertYML(merged, v)
+
+	return true
+}
+
+// Applies the environment to all variants and warns if no variant
+// defines it, which most likely is a typo.
+func (t *variants) applyEnvironment() {
+	found := false
+	for _, v := range t.Variants {
+		if v.ApplyEnvironment(t.Environment, t.MergeStrategy) {
+			found = true
+		}
+	}
+
+	if t.Environment != "" && !found {
+		utils.Warn("No variant defines the environment '%s'", t.Environment)
+	}
+}
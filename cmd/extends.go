@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+const (
+	// The key variants use to inherit from other variants.
+	extendsKey = "extends"
+	// The maximum length of an inheritance chain.
+	maxExtendsDepth = 32
+)
+
+// Resolves the inheritance of variants.
+type extendsResolver struct {
+	variants map[string]map[string]interface{}
+	resolved map[string]map[string]interface{}
+	strategy string
+}
+
+// Returns the names of the variants the data extends.
+// The extends key may either be a single name or a list of names.
+func extendedNames(data map[string]interface{}) []string {
+	switch ext := data[extendsKey].(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{ext}
+	case []interface{}:
+		names := make([]string, 0, len(ext))
+		for _, name := range ext {
+			if s, ok := name.(string); ok {
+				names = append(names, s)
+			} else {
+				utils.Error(
+					"Invalid value '%v' in '%s', variant names must be strings",
+					name, extendsKey,
+				)
+			}
+		}
+		return names
+	default:
+		utils.Error(
+			"Invalid value '%v' for '%s', must be a variant name or a list of names",
+			ext, extendsKey,
+		)
+	}
+
+	return nil
+}
+
+// Resolves the variant with the given name, chain holds the names of the
+// variants currently being resolved and is used to detect cycles.
+func (r *extendsResolver) resolve(name string, chain []string) map[string]interface{} {
+	if data, ok := r.resolved[name]; ok {
+		return data
+	}
+
+	for idx, n := range chain {
+		if n == name {
+			utils.Error(
+				"Cyclic variant inheritance detected: %s",
+				strings.Join(append(chain[idx:], name), " -> "),
+			)
+		}
+	}
+
+	if len(chain) >= maxExtendsDepth {
+		utils.Error(
+			"Variant inheritance exceeds the maximum depth of %d: %s",
+			maxExtendsDepth, strings.Join(append(chain, name), " -> "),
+		)
+	}
+
+	data, ok := r.variants[name]
+	if !ok {
+		utils.Error(
+			"Variant '%s' extends the unknown variant '%s'",
+			chain[len(chain)-1], name,
+		)
+	}
+
+	chain = append(chain, name)
+	res := make(map[string]interface{})
+
+	for _, parent := range extendedNames(data) {
+		utils.Debug(
+			"Variant '%s' extends variant '%s'", name, parent,
+		)
+		res = utils.MergeMaps(res, r.resolve(parent, chain), r.strategy)
+	}
+
+	res = utils.MergeMaps(res, data, r.strategy)
+	delete(res, extendsKey)
+
+	r.resolved[name] = res
+	return res
+}
+
+// Resolves the inheritance of all variants, variants may extend others with
+// the extends key. The data of the extended variants is deep merged with
+// the variant's own data taking precedence.
+func (t *variants) resolveExtends() {
+	raw := make([]map[string]interface{}, len(t.Variants))
+	inherits := false
+
+	r := &extendsResolver{
+		variants: make(map[string]map[string]interface{}, len(t.Variants)),
+		resolved: make(map[string]map[string]interface{}, len(t.Variants)),
+		strategy: t.MergeStrategy,
+	}
+
+	for idx, v := range t.Variants {
+		utils.ConvertYML(v, &raw[idx])
+
+		if _, ok := raw[idx][extendsKey]; ok {
+			inherits = true
+		}
+
+		if v.Name != nil {
+			r.variants[*v.Name] = raw[idx]
+		}
+	}
+
+	if !inherits {
+		return
+	}
+
+	for idx, v := range t.Variants {
+		if _, ok := raw[idx][extendsKey]; !ok {
+			continue
+		}
+
+		if v.Name == nil {
+			utils.Error(
+				"Variants which extend others require a name",
+			)
+		}
+
+		data := r.resolve(*v.Name, nil)
+
+		*v = variant{}
+		utils.ConvertYML(data, v)
+	}
+}
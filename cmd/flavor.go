@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Supported flavors of the generated files.
+const (
+	flavorDocker        = "docker"
+	flavorContainerfile = "containerfile"
+)
+
+// Verifies that the flavor is supported and fails if not.
+func verifyFlavor(flavor string) {
+	switch flavor {
+	case flavorDocker, flavorContainerfile:
+	default:
+		utils.Error(
+			"Invalid flavor '%s', must be one of '%s' or '%s'",
+			flavor, flavorDocker, flavorContainerfile,
+		)
+	}
+}
+
+// Applies the defaults of the flavor to the flags which are neither given
+// on the command line nor in the configuration file.
+func applyFlavor() {
+	flavor := viper.GetString(flavorFlag)
+	verifyFlavor(flavor)
+
+	if flavor != flavorContainerfile {
+		return
+	}
+
+	viper.SetDefault(dockerfileTplFlag, "Containerfile.tpl")
+	viper.SetDefault(outFmtFlag, "Containerfile.{{ .image.name }}.{{ .image.tag }}")
+
+	if viper.GetString(tplSyntaxFlag) != "" {
+		utils.Warn(
+			"Podman and Buildah ignore the syntax directive added with --%s", tplSyntaxFlag,
+		)
+	}
+}
+
+// Returns the suffix of the ignore files named after the generated files.
+func ignoreFileSuffix() string {
+	if viper.GetString(flavorFlag) == flavorContainerfile {
+		return ".containerignore"
+	}
+	return ".dockerignore"
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Supported output formats of the informational commands.
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+// The output format of the informational commands.
+var outputFormat string
+
+// Adds the format flag to an informational command.
+func addFormatFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(
+		&outputFormat, "format", formatText,
+		"Output format, one of "+formatText+", "+formatJSON+" or "+formatYAML,
+	)
+}
+
+// Verifies that the output format is supported and fails if not.
+func verifyFormat(format string) {
+	switch format {
+	case formatText, formatJSON, formatYAML:
+	default:
+		utils.Error(
+			"Invalid format '%s', must be one of '%s', '%s' or '%s'",
+			format, formatText, formatJSON, formatYAML,
+		)
+	}
+}
+
+// Prints the entries to stdout in the output format, text is used to
+// print the text representation.
+func printFormatted(entries interface{}, text func(w io.Writer)) {
+	switch outputFormat {
+	case formatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entries); err != nil {
+			utils.Error("Could not encode the output: %s", err)
+		}
+	case formatYAML:
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(entries); err != nil {
+			utils.Error("Could not encode the output: %s", err)
+		}
+		if err := encoder.Close(); err != nil {
+			utils.Error("%s", err)
+		}
+	default:
+		text(os.Stdout)
+	}
+}
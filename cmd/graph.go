@@ -0,0 +1,312 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+var (
+	graphSyntax   string
+	graphVariants bool
+
+	graphCMD = &cobra.Command{
+		Use:   "graph",
+		Short: "Output the dependency graph of the templates",
+		Long: "Output the graph of templates referencing each other with the template action " +
+			"or the include function as DOT or mermaid. With --variants the graph maps the variants " +
+			"to the templates executed when rendering them instead",
+		Args: cobra.NoArgs,
+		Run:  runGraph,
+	}
+)
+
+// Supported graph syntaxes.
+const (
+	graphSyntaxDot     = "dot"
+	graphSyntaxMermaid = "mermaid"
+)
+
+func init() {
+	graphCMD.Flags().StringVar(
+		&graphSyntax, "syntax", graphSyntaxDot,
+		"Syntax of the graph, either "+graphSyntaxDot+" or "+graphSyntaxMermaid,
+	)
+	graphCMD.Flags().BoolVar(
+		&graphVariants, "variants", false,
+		"Map the variants to the templates executed when rendering them (without writing any output)",
+	)
+
+	TemplaterCMD.AddCommand(graphCMD)
+}
+
+// A node of the template graph.
+type graphNode struct {
+	name    string
+	source  string
+	defined bool
+	// Whether the node is a variant, its name has the variant node prefix.
+	variant bool
+}
+
+// The prefix of the names of variant nodes, which keeps them apart from
+// templates with the same name.
+const variantNodePrefix = "variant:"
+
+// The function recording the templates executed for a variant.
+const visitFunc = "dtplVisit"
+
+// An edge of the template graph.
+type graphEdge struct {
+	from string
+	to   string
+	// Whether the edge is a dependency between variants.
+	dependency bool
+}
+
+// The dependency graph of the templates.
+type templateGraph struct {
+	nodes []*graphNode
+	edges []graphEdge
+}
+
+// Builds the dependency graph of the active template definitions.
+func (t *templater) graph() *templateGraph {
+	g := &templateGraph{}
+
+	defs := t.definitions()
+	states := templateStates(defs, t.rootTemplate())
+
+	nodes := make(map[string]*graphNode)
+	edges := make(map[graphEdge]bool)
+
+	for idx, def := range defs {
+		if states[idx] == templateOverridden || isEmptyFileTemplate(def) {
+			continue
+		}
+		nodes[def.Name] = &graphNode{name: def.Name, source: def.Source, defined: true}
+	}
+
+	for idx, def := range defs {
+		if states[idx] == templateOverridden {
+			continue
+		}
+		for _, ref := range utils.TemplateReferences(def.Tree) {
+			if _, ok := nodes[ref.Name]; !ok {
+				nodes[ref.Name] = &graphNode{name: ref.Name}
+			}
+			edges[graphEdge{from: def.Name, to: ref.Name}] = true
+		}
+	}
+
+	for _, node := range nodes {
+		g.nodes = append(g.nodes, node)
+	}
+	sort.Slice(g.nodes, func(i, j int) bool {
+		return g.nodes[i].name < g.nodes[j].name
+	})
+
+	for edge := range edges {
+		g.edges = append(g.edges, edge)
+	}
+	sort.Slice(g.edges, func(i, j int) bool {
+		if g.edges[i].from != g.edges[j].from {
+			return g.edges[i].from < g.edges[j].from
+		}
+		return g.edges[i].to < g.edges[j].to
+	})
+
+	return g
+}
+
+// Inserts a call of the visit function at the start of every template of
+// the set, so the executed templates are recorded. Trees shared between
+// sets are only instrumented once.
+func instrumentTemplates(tpl *template.Template, visit func(name string) string) {
+	tpl.Funcs(template.FuncMap{visitFunc: visit})
+
+	for _, t := range tpl.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+
+		root := t.Tree.Root
+		if len(root.Nodes) > 0 && strings.HasPrefix(root.Nodes[0].String(), "{{"+visitFunc+" ") {
+			continue
+		}
+
+		call, err := parse.Parse(
+			"visit", fmt.Sprintf("{{%s %q}}", visitFunc, t.Name()), "{{", "}}",
+			map[string]interface{}{visitFunc: visit},
+		)
+		if err != nil {
+			utils.Error("%s", err)
+		}
+
+		root.Nodes = append(call["visit"].Root.Nodes, root.Nodes...)
+	}
+}
+
+// Renders the variants (without writing any output) and builds the graph
+// of the variants, the templates executed for them and the dependencies
+// between the variants.
+func (t *templater) variantsGraph(variants []*variant) *templateGraph {
+	g := &templateGraph{}
+
+	sources := make(map[string]string)
+	defs := t.definitions()
+	for idx, state := range templateStates(defs, t.rootTemplate()) {
+		if state != templateOverridden {
+			sources[defs[idx].Name] = defs[idx].Source
+		}
+	}
+
+	var visited map[string]bool
+	visit := func(name string) string {
+		visited[name] = true
+		return ""
+	}
+
+	templates := make(map[string]bool)
+	for _, v := range variants {
+		visited = make(map[string]bool)
+
+		instrumentTemplates(t.templateFor(v), visit)
+		t.collectFromImages(v, t.executeTemplate(v))
+
+		node := variantNodePrefix + *v.Name
+		g.nodes = append(g.nodes, &graphNode{name: node, variant: true})
+
+		names := make([]string, 0, len(visited))
+		for name := range visited {
+			names = append(names, name)
+			templates[name] = true
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			g.edges = append(g.edges, graphEdge{from: node, to: name})
+		}
+	}
+
+	deps := dependencyNames(variants, t.fromImages)
+	for _, v := range variants {
+		for _, dep := range deps[*v.Name] {
+			g.edges = append(g.edges, graphEdge{
+				from: variantNodePrefix + *v.Name, to: variantNodePrefix + dep, dependency: true,
+			})
+		}
+	}
+
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		source, ok := sources[name]
+		if !ok {
+			source = name
+		}
+		g.nodes = append(g.nodes, &graphNode{name: name, source: source, defined: true})
+	}
+
+	return g
+}
+
+// Writes the graph in the DOT syntax.
+func (g *templateGraph) writeDot(w io.Writer) {
+	fmt.Fprintln(w, "digraph templates {")
+	fmt.Fprintln(w, "  node [shape=box];")
+
+	for _, node := range g.nodes {
+		if node.variant {
+			fmt.Fprintf(w, "  %q [label=%q, shape=ellipse];\n", node.name, strings.TrimPrefix(node.name, variantNodePrefix))
+		} else if node.defined {
+			fmt.Fprintf(w, "  %q [label=%q];\n", node.name, node.name+"\n"+node.source)
+		} else {
+			fmt.Fprintf(w, "  %q [label=%q, style=dashed, color=red];\n", node.name, node.name+"\n(undefined)")
+		}
+	}
+
+	for _, edge := range g.edges {
+		if edge.dependency {
+			fmt.Fprintf(w, "  %q -> %q [style=bold, label=\"FROM\"];\n", edge.from, edge.to)
+			continue
+		}
+		fmt.Fprintf(w, "  %q -> %q;\n", edge.from, edge.to)
+	}
+
+	fmt.Fprintln(w, "}")
+}
+
+// Writes the graph in the mermaid flowchart syntax.
+func (g *templateGraph) writeMermaid(w io.Writer) {
+	ids := make(map[string]string, len(g.nodes))
+
+	fmt.Fprintln(w, "flowchart LR")
+
+	for idx, node := range g.nodes {
+		ids[node.name] = fmt.Sprintf("t%d", idx)
+
+		if node.variant {
+			label := strings.TrimPrefix(node.name, variantNodePrefix)
+			fmt.Fprintf(w, "  %s([\"%s\"])\n", ids[node.name], strings.ReplaceAll(label, `"`, "#quot;"))
+			continue
+		}
+
+		label := node.name + "<br/>" + node.source
+		if !node.defined {
+			label = node.name + "<br/>(undefined)"
+		}
+		fmt.Fprintf(w, "  %s[\"%s\"]\n", ids[node.name], strings.ReplaceAll(label, `"`, "#quot;"))
+	}
+
+	for _, edge := range g.edges {
+		if edge.dependency {
+			fmt.Fprintf(w, "  %s ==>|FROM| %s\n", ids[edge.from], ids[edge.to])
+			continue
+		}
+		fmt.Fprintf(w, "  %s --> %s\n", ids[edge.from], ids[edge.to])
+	}
+}
+
+func runGraph(_ *cobra.Command, _ []string) {
+	templater := newTemplater()
+
+	var g *templateGraph
+	if graphVariants {
+		variants := newVariants()
+
+		verifyDataLayout(templater.DataLayout)
+		initTemplateFuncs(templater)
+
+		templater.loadVariants(variants)
+		templater.initTemplate()
+
+		g = templater.variantsGraph(variants.Variants)
+	} else {
+		g = templater.graph()
+	}
+
+	switch graphSyntax {
+	case graphSyntaxDot:
+		g.writeDot(os.Stdout)
+	case graphSyntaxMermaid:
+		g.writeMermaid(os.Stdout)
+	default:
+		utils.Error(
+			"Invalid graph syntax '%s', must be one of '%s' or '%s'",
+			graphSyntax, graphSyntaxDot, graphSyntaxMermaid,
+		)
+	}
+}
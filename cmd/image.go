@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/viper"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Returns whether the first component of an image name is a registry host.
+func hasRegistry(name string) bool {
+	host, _, ok := strings.Cut(name, "/")
+	return ok && (strings.ContainsAny(host, ".:") || host == "localhost")
+}
+
+// Returns the image reference with the registry replaced (or added) if one
+// is given.
+func withRegistry(ref string, registry string) string {
+	if registry == "" {
+		return ref
+	}
+
+	if hasRegistry(ref) {
+		_, ref, _ = strings.Cut(ref, "/")
+	}
+
+	return strings.TrimSuffix(registry, "/") + "/" + ref
+}
+
+// Returns the image reference of the variant for a tag, the reference is
+// rendered with the image format and the variant data where image.tag is
+// set to the tag.
+func (v *variant) imageRef(tpl *template.Template, tag string) string {
+	data := utils.CopyMap(v.Data)
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+
+	data["name"] = *v.Name
+	data["image"] = map[string]interface{}{
+		"name": *v.Image.Name,
+		"tag":  tag,
+	}
+
+	var ref bytes.Buffer
+	if err := tpl.Execute(&ref, data); err != nil {
+		utils.Error(
+			"Failed to generate image reference of variant '%s': %s",
+			*v.Name, err,
+		)
+	}
+
+	res := strings.TrimSpace(ref.String())
+	if res == "" || strings.ContainsAny(res, " \t\n") {
+		utils.Error(
+			"Invalid image reference '%s' generated for variant '%s'",
+			res, *v.Name,
+		)
+	}
+
+	return res
+}
+
+// Returns the image references of all tags of a variant.
+func (v *variant) ImageRefs(registry string) []string {
+	format := viper.GetString(imageFmtFlag)
+
+	tpl, err := template.New("ImageRef").Parse(format)
+	if err != nil {
+		utils.Error(
+			"Failed to parse image format '%s': %s",
+			format, err,
+		)
+	}
+
+	refs := make([]string, 0, len(v.Image.Tags)+1)
+	for _, tag := range v.ImageTags() {
+		refs = append(refs, withRegistry(v.imageRef(tpl, tag), registry))
+	}
+
+	return refs
+}
+
+// The key of the build args of a variant.
+const buildArgsKey = "build_args"
+
+// Returns the build args of a variant which are passed to the builders.
+func (v *variant) BuildArgs() map[string]string {
+	raw, ok := v.Data[buildArgsKey]
+	if !ok || raw == nil {
+		return nil
+	}
+
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		utils.Error(
+			"Invalid value '%v' for '%s' of variant '%s', must be a map",
+			raw, buildArgsKey, *v.Name,
+		)
+	}
+
+	args := make(map[string]string, len(m))
+	for key, val := range m {
+		switch val.(type) {
+		case map[string]interface{}, []interface{}:
+			utils.Error(
+				"Invalid value for build arg '%s' of variant '%s', must be a scalar",
+				key, *v.Name,
+			)
+		case nil:
+			args[key] = ""
+		default:
+			args[key] = fmt.Sprint(val)
+		}
+	}
+
+	return args
+}
+
+// Returns the keys of a map sorted.
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns the build args of a variant as sorted key=value pairs.
+func (v *variant) BuildArgPairs() []string {
+	args := v.BuildArgs()
+
+	pairs := make([]string, 0, len(args))
+	for key, val := range args {
+		pairs = append(pairs, key+"="+val)
+	}
+	sort.Strings(pairs)
+
+	return pairs
+}
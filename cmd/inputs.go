@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+var (
+	inputsCMD = &cobra.Command{
+		Use:   "inputs",
+		Short: "List the inputs declared by the Dockerfile template",
+		Long: "List the variables the Dockerfile template expects, as declared in its front matter " +
+			"or its schema file (<template>.schema.yml)",
+		Args: cobra.NoArgs,
+		Run:  runInputs,
+	}
+)
+
+func init() {
+	addFormatFlag(inputsCMD)
+
+	TemplaterCMD.AddCommand(inputsCMD)
+}
+
+// An input as listed by the inputs command.
+type inputEntry struct {
+	Name        string      `json:"name" yaml:"name"`
+	Type        string      `json:"type" yaml:"type"`
+	Required    bool        `json:"required" yaml:"required"`
+	Default     interface{} `json:"default,omitempty" yaml:"default,omitempty"`
+	Description string      `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// The suffix of the sidecar schema file of a template.
+const schemaFileSuffix = ".schema.yml"
+
+// Supported types of template inputs.
+const (
+	inputTypeAny     = "any"
+	inputTypeString  = "string"
+	inputTypeInteger = "integer"
+	inputTypeNumber  = "number"
+	inputTypeBoolean = "boolean"
+	inputTypeList    = "list"
+	inputTypeMap     = "map"
+)
+
+// A variable the template expects in the variant data.
+type templateInput struct {
+	Type        string      `yaml:"type,omitempty"`
+	Description string      `yaml:"description,omitempty"`
+	Default     interface{} `yaml:"default,omitempty"`
+	Required    bool        `yaml:"required,omitempty"`
+}
+
+// The inputs declared by a template, keyed by their key path (separated
+// by dots), and the assertions on its rendered output.
+type templateSchema struct {
+	Inputs     map[string]*templateInput `yaml:"inputs"`
+	Assertions []*utils.Assertion        `yaml:"assertions"`
+}
+
+// Returns the key paths of the inputs sorted.
+func (s *templateSchema) Keys() []string {
+	keys := make([]string, 0, len(s.Inputs))
+	for key := range s.Inputs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Loads the inputs declared in the front matter of the template or its
+// schema file, returns nil if none are declared.
+func loadTemplateSchema(tpl string) *templateSchema {
+	schema := &templateSchema{}
+
+	if frontMatter := utils.FrontMatter(tpl); frontMatter != "" {
+		utils.Debug("Loading inputs from the front matter of '%s'", tpl)
+		utils.LoadYMLFromBytes([]byte(frontMatter), schema)
+	} else if _, err := os.Stat(tpl + schemaFileSuffix); err == nil {
+		utils.LoadYMLFromFile(tpl+schemaFileSuffix, schema)
+	} else if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else {
+		utils.Error("%s", err)
+	}
+
+	for key, input := range schema.Inputs {
+		if input == nil {
+			input = &templateInput{}
+			schema.Inputs[key] = input
+		}
+		if input.Type == "" {
+			input.Type = inputTypeAny
+		}
+		verifyInputType(key, input.Type)
+	}
+
+	return schema
+}
+
+// Verifies that the type of an input is supported and fails if not.
+func verifyInputType(key string, typ string) {
+	switch typ {
+	case inputTypeAny, inputTypeString, inputTypeInteger, inputTypeNumber,
+		inputTypeBoolean, inputTypeList, inputTypeMap:
+	default:
+		utils.Error(
+			"Invalid type '%s' of input '%s', must be one of %s",
+			typ, key, strings.Join([]string{
+				inputTypeAny, inputTypeString, inputTypeInteger, inputTypeNumber,
+				inputTypeBoolean, inputTypeList, inputTypeMap,
+			}, ", "),
+		)
+	}
+}
+
+// Returns whether the value matches the type.
+func matchesInputType(val interface{}, typ string) bool {
+	switch typ {
+	case inputTypeString:
+		_, ok := val.(string)
+		return ok
+	case inputTypeInteger:
+		_, ok := val.(int)
+		return ok
+	case inputTypeNumber:
+		switch val.(type) {
+		case int, float64:
+			return true
+		}
+		return false
+	case inputTypeBoolean:
+		_, ok := val.(bool)
+		return ok
+	case inputTypeList:
+		_, ok := val.([]interface{})
+		return ok
+	case inputTypeMap:
+		_, ok := val.(map[string]interface{})
+		return ok
+	default:
+		return true
+	}
+}
+
+// Returns the value at the key path of the data.
+func lookupPath(data map[string]interface{}, keyPath []string) (interface{}, bool) {
+	var val interface{} = data
+
+	for _, key := range keyPath {
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if val, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+
+	return val, true
+}
+
+// Sets the value at the key path of the data, returns false if an element
+// on the path is not a map.
+func setPath(data map[string]interface{}, keyPath []string, val interface{}) bool {
+	parent := utils.UpdateAndGetMapElementByPath(data, keyPath[:len(keyPath)-1])
+	if parent == nil {
+		return false
+	}
+	parent[keyPath[len(keyPath)-1]] = val
+	return true
+}
+
+// Validates the variants against the inputs of the schema. Defaults are
+// applied to variants missing an input, missing required inputs are
+// prompted for in interactive mode.
+func (s *templateSchema) Validate(variants []*variant) {
+	var issues []string
+
+	for _, v := range variants {
+		if v.Data == nil {
+			v.Data = make(map[string]interface{})
+		}
+
+		for _, key := range s.Keys() {
+			input := s.Inputs[key]
+			keyPath := strings.Split(key, ".")
+
+			val, ok := lookupPath(v.Data, keyPath)
+			if !ok || val == nil {
+				switch {
+				case input.Default != nil:
+					val = utils.CopyValue(input.Default)
+				case input.Required:
+					if !interactive {
+						issues = append(issues, fmt.Sprintf(
+							"variant '%s' is missing the required input '%s'", *v.Name, key,
+						))
+						continue
+					}
+
+					message := key
+					if input.Description != "" {
+						message += " (" + input.Description + ")"
+					}
+
+					answer, err := utils.Prompt(*v.Name, message)
+					if err != nil {
+						utils.Error("%s", err)
+					}
+					val = answer
+				default:
+					continue
+				}
+
+				if !setPath(v.Data, keyPath, val) {
+					issues = append(issues, fmt.Sprintf(
+						"variant '%s' cannot hold the input '%s', an element on its path is no map",
+						*v.Name, key,
+					))
+					continue
+				}
+			}
+
+			if !matchesInputType(val, input.Type) {
+				issues = append(issues, fmt.Sprintf(
+					"input '%s' of variant '%s' must be of type %s but is '%v'",
+					key, *v.Name, input.Type, val,
+				))
+			}
+		}
+	}
+
+	if len(issues) > 0 {
+		utils.Error(
+			"Variants do not match the inputs of the template:\n - %s",
+			strings.Join(issues, "\n - "),
+		)
+	}
+}
+
+func runInputs(_ *cobra.Command, _ []string) {
+	templater := newTemplater()
+	verifyFormat(outputFormat)
+
+	schema := loadTemplateSchema(templater.DockerfileTpl)
+	if schema == nil {
+		schema = &templateSchema{}
+	}
+
+	if len(schema.Inputs) == 0 && outputFormat == formatText {
+		utils.Info(
+			"The template '%s' declares no inputs", templater.DockerfileTpl,
+		)
+		return
+	}
+
+	entries := make([]inputEntry, 0, len(schema.Inputs))
+	for _, key := range schema.Keys() {
+		input := schema.Inputs[key]
+		entries = append(entries, inputEntry{
+			Name:        key,
+			Type:        input.Type,
+			Required:    input.Required,
+			Default:     input.Default,
+			Description: input.Description,
+		})
+	}
+
+	printFormatted(entries, func(out io.Writer) {
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tTYPE\tREQUIRED\tDEFAULT\tDESCRIPTION")
+
+		for _, e := range entries {
+			def := ""
+			if e.Default != nil {
+				def = fmt.Sprintf("%v", e.Default)
+			}
+
+			fmt.Fprintf(
+				w, "%s\t%s\t%t\t%s\t%s\n",
+				e.Name, e.Type, e.Required, def, e.Description,
+			)
+		}
+
+		if err := w.Flush(); err != nil {
+			utils.Error("%s", err)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// The extension of variants definitions evaluated with jsonnet.
+const jsonnetExtension = ".jsonnet"
+
+// Returns whether the variants definition is a jsonnet file.
+func (t *variants) isJsonnet() bool {
+	return filepath.Ext(t.VariantsTplFile) == jsonnetExtension
+}
+
+// Evaluates the jsonnet variants definition and returns the resulting
+// JSON, which is loaded like a variants yml. The additional variables
+// (without a variant name prefix) and the environment variables are
+// available as external variables.
+func (t *variants) evaluateJsonnet() []byte {
+	utils.Debug(
+		"Evaluating variants from '%s' with jsonnet", t.VariantsTplFile,
+	)
+
+	extVars := make(map[string]interface{})
+	for _, variable := range t.AdditionalVariables {
+		// Variables of single variants are applied after the evaluation
+		if strings.Contains(variable.Key, ":") {
+			continue
+		}
+		extVars[variable.Key] = utils.ParseYMLValue(variable.Value)
+	}
+
+	var envVars []string
+	if !utils.IsHermetic() {
+		for _, entry := range os.Environ() {
+			if key, _, ok := strings.Cut(entry, "="); ok && key != "" {
+				envVars = append(envVars, key)
+			}
+		}
+	}
+
+	res, err := utils.EvaluateJsonnet(t.VariantsTplFile, extVars, envVars)
+	if err != nil {
+		utils.Error(
+			"Could not evaluate the variants definition '%s': %s", t.VariantsTplFile, err,
+		)
+	}
+
+	return res
+}
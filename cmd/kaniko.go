@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+var (
+	kanikoContext   string
+	kanikoImage     string
+	kanikoNamespace string
+	kanikoRegistry  string
+	kanikoOut       string
+	kanikoSecret    string
+
+	kanikoCMD = &cobra.Command{
+		Use:   "kaniko",
+		Short: "Render the Dockerfiles and generate Kaniko build jobs",
+		Long: "Render the Dockerfiles and generate a Kubernetes Job manifest per variant which builds " +
+			"and pushes the image of the variant with Kaniko",
+		Args: cobra.NoArgs,
+		Run:  runKaniko,
+	}
+)
+
+func init() {
+	kanikoCMD.Flags().StringVar(
+		&kanikoContext, "context", "dir:///workspace",
+		"Build context passed to Kaniko (e.g. a git or storage url), it must contain the rendered Dockerfiles",
+	)
+	kanikoCMD.Flags().StringVar(
+		&kanikoImage, "image", "gcr.io/kaniko-project/executor:latest",
+		"Kaniko executor image",
+	)
+	kanikoCMD.Flags().StringVar(
+		&kanikoNamespace, "namespace", "",
+		"Namespace of the jobs",
+	)
+	kanikoCMD.Flags().StringVar(
+		&kanikoRegistry, "registry", "",
+		"Registry to push the images to, it replaces the registry of the image names",
+	)
+	kanikoCMD.Flags().StringVar(
+		&kanikoSecret, "docker-config-secret", "",
+		"Name of a secret with the registry credentials (config.json) mounted to /kaniko/.docker",
+	)
+	kanikoCMD.Flags().StringVar(
+		&kanikoOut, "out", "",
+		"File to write the manifests to instead of stdout",
+	)
+
+	TemplaterCMD.AddCommand(kanikoCMD)
+}
+
+// A Kubernetes Job running the Kaniko executor.
+type kanikoJob struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string            `yaml:"name"`
+		Namespace string            `yaml:"namespace,omitempty"`
+		Labels    map[string]string `yaml:"labels"`
+	} `yaml:"metadata"`
+	Spec struct {
+		BackoffLimit          int   `yaml:"backoffLimit"`
+		ActiveDeadlineSeconds int64 `yaml:"activeDeadlineSeconds,omitempty"`
+		Template              struct {
+			Spec struct {
+				RestartPolicy     string            `yaml:"restartPolicy"`
+				PriorityClassName string            `yaml:"priorityClassName,omitempty"`
+				NodeSelector      map[string]string `yaml:"nodeSelector,omitempty"`
+				Containers        []kanikoContainer `yaml:"containers"`
+				Volumes           []kanikoVolume    `yaml:"volumes,omitempty"`
+			} `yaml:"spec"`
+		} `yaml:"template"`
+	} `yaml:"spec"`
+}
+
+type kanikoContainer struct {
+	Name         string              `yaml:"name"`
+	Image        string              `yaml:"image"`
+	Args         []string            `yaml:"args"`
+	VolumeMounts []kanikoVolumeMount `yaml:"volumeMounts,omitempty"`
+}
+
+type kanikoVolume struct {
+	Name   string `yaml:"name"`
+	Secret struct {
+		SecretName string `yaml:"secretName"`
+	} `yaml:"secret"`
+}
+
+type kanikoVolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+}
+
+// Matches characters which are not allowed in Kubernetes resource names.
+var invalidResourceNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Returns a valid Kubernetes resource name (DNS-1123 label).
+func resourceName(name string) string {
+	name = invalidResourceNameChars.ReplaceAllString(
+		strings.ToLower(name), "-",
+	)
+
+	if len(name) > 63 {
+		name = name[:63]
+	}
+
+	return strings.Trim(name, "-")
+}
+
+// Returns the Kaniko job building the variant's Dockerfile.
+func newKanikoJob(v *variant, dockerfile string) *kanikoJob {
+	job := &kanikoJob{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+	}
+
+	job.Metadata.Name = resourceName("kaniko-" + *v.Name)
+	job.Metadata.Namespace = kanikoNamespace
+	job.Metadata.Labels = map[string]string{
+		"app.kubernetes.io/managed-by": "dockerfile-templater",
+		"dockerfile-templater/variant": resourceName(*v.Name),
+	}
+
+	job.Spec.Template.Spec.RestartPolicy = "Never"
+
+	// The runner tags select the nodes, the timeout limits the job
+	ci := v.CI()
+	job.Spec.ActiveDeadlineSeconds = int64(ci.timeout.Seconds())
+	job.Spec.Template.Spec.PriorityClassName = ci.Priority
+	job.Spec.Template.Spec.NodeSelector = ci.TagLabels()
+	job.Spec.Template.Spec.Containers = []kanikoContainer{{
+		Name:  "kaniko",
+		Image: kanikoImage,
+		Args: []string{
+			"--context=" + kanikoContext,
+			"--dockerfile=" + dockerfile,
+		},
+	}}
+
+	container := &job.Spec.Template.Spec.Containers[0]
+	for _, ref := range v.ImageRefs(kanikoRegistry) {
+		container.Args = append(container.Args, "--destination="+ref)
+	}
+	for _, arg := range v.BuildArgPairs() {
+		container.Args = append(container.Args, "--build-arg="+arg)
+	}
+
+	if kanikoSecret != "" {
+		volume := kanikoVolume{Name: "docker-config"}
+		volume.Secret.SecretName = kanikoSecret
+
+		job.Spec.Template.Spec.Volumes = []kanikoVolume{volume}
+		job.Spec.Template.Spec.Containers[0].VolumeMounts = []kanikoVolumeMount{{
+			Name: volume.Name, MountPath: "/kaniko/.docker",
+		}}
+	}
+
+	return job
+}
+
+// Returns the path of a rendered Dockerfile relative to the working
+// directory, which is assumed to be the root of the build context.
+func contextPath(file string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		utils.Error("%s", err)
+	}
+
+	rel, err := filepath.Rel(wd, file)
+	if err != nil {
+		utils.Error(
+			"Could not resolve '%s' relative to the build context: %s", file, err,
+		)
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+func runKaniko(_ *cobra.Command, _ []string) {
+	templater, variants := render()
+
+	var manifests strings.Builder
+	encoder := yaml.NewEncoder(&manifests)
+	encoder.SetIndent(2)
+
+	for idx, v := range variants.Variants {
+		job := newKanikoJob(v, contextPath(templater.outputs[idx]))
+		if err := encoder.Encode(job); err != nil {
+			utils.Error(
+				"Could not encode Kaniko job of variant '%s': %s", *v.Name, err,
+			)
+		}
+	}
+
+	if err := encoder.Close(); err != nil {
+		utils.Error("%s", err)
+	}
+
+	if kanikoOut == "" {
+		os.Stdout.WriteString(manifests.String())
+		return
+	}
+
+	utils.Info(
+		"Writing Kaniko jobs to '%s'", kanikoOut,
+	)
+
+	if err := os.WriteFile(kanikoOut, []byte(manifests.String()), 0o644); err != nil {
+		utils.Error(
+			"Could not write Kaniko jobs to '%s': %s", kanikoOut, err,
+		)
+	}
+}
@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+var (
+	lintVars bool
+
+	lintCMD = &cobra.Command{
+		Use:   "lint",
+		Short: "Check the templates and variants for common mistakes",
+		Long: "Check the templates and variants for common mistakes without rendering them. " +
+			"All checks are run if none is selected explicitly",
+		Args: cobra.NoArgs,
+		Run:  runLint,
+	}
+)
+
+func init() {
+	lintCMD.Flags().BoolVar(
+		&lintVars, "vars", false,
+		"Report variant keys which are never referenced and template fields no variant provides",
+	)
+
+	TemplaterCMD.AddCommand(lintCMD)
+}
+
+// A field referenced by a template.
+type fieldReference struct {
+	path     []string
+	template string
+	source   string
+}
+
+// Returns the fields referenced by the active templates and the output
+// name format.
+func (t *templater) fieldReferences() []fieldReference {
+	var refs []fieldReference
+
+	defs := t.definitions()
+	states := templateStates(defs, t.rootTemplate())
+
+	for idx, def := range defs {
+		if states[idx] == templateOverridden {
+			continue
+		}
+		for _, path := range utils.FieldReferences(def.Tree) {
+			refs = append(refs, fieldReference{
+				path: path, template: def.Name, source: def.Source,
+			})
+		}
+	}
+
+	trees := make(map[string]*parse.Tree)
+	tree := parse.New(outFmtFlag)
+	tree.Mode = parse.SkipFuncCheck
+	if _, err := tree.Parse(viper.GetString(outFmtFlag), "", "", trees); err == nil {
+		for _, path := range utils.FieldReferences(tree) {
+			refs = append(refs, fieldReference{
+				path: path, template: outFmtFlag, source: "--" + outFmtFlag,
+			})
+		}
+	}
+
+	return refs
+}
+
+// Returns whether the key path is provided by the data.
+func providesPath(data map[string]interface{}, path []string) bool {
+	var val interface{} = data
+
+	for _, key := range path {
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if val, ok = m[key]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns whether path starts with prefix.
+func hasPathPrefix(path []string, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for idx := range prefix {
+		if path[idx] != prefix[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// Collects the key paths of the data which are not referenced. A key is
+// referenced if a reference points to it or to one of its ancestors.
+func unreferencedPaths(
+	data map[string]interface{},
+	prefix []string,
+	refs []fieldReference,
+	ignored map[string]bool,
+	unreferenced *[]string,
+) {
+	for key, val := range data {
+		path := append(append([]string{}, prefix...), key)
+		joined := strings.Join(path, ".")
+
+		if ignored[joined] {
+			continue
+		}
+
+		covered, descendant := false, false
+		for _, ref := range refs {
+			if hasPathPrefix(path, ref.path) {
+				covered = true
+				break
+			}
+			if hasPathPrefix(ref.path, path) {
+				descendant = true
+			}
+		}
+
+		if covered {
+			continue
+		}
+
+		nested, ok := val.(map[string]interface{})
+		if descendant && ok {
+			unreferencedPaths(nested, path, refs, ignored, unreferenced)
+			continue
+		}
+
+		*unreferenced = append(*unreferenced, joined)
+	}
+}
+
+// Reports variant keys which are never referenced and fields referenced
+// by the templates which no variant provides. Returns the number of issues.
+func lintVariables(t *templater, vs *variants) int {
+	refs := t.fieldReferences()
+	issues := 0
+
+	// The image tags and build args are used by the build commands and do
+	// not need to be referenced by the templates
+	ignored := map[string]bool{
+		"name": true, "image.tags": true, buildArgsKey: true,
+		baseTemplateKey: true, extendsKey: true, descriptionKey: true,
+		outputKey: true, ciKey: true, dependsOnKey: true,
+	}
+	if t.DataLayout == dataLayoutNamespaced {
+		ignored = map[string]bool{
+			"Env": true, "Build": true,
+			"Variant.name": true, "Variant.image.tags": true,
+			"Variant." + buildArgsKey: true, "Variant." + baseTemplateKey: true,
+			"Variant." + descriptionKey: true, "Variant." + outputKey: true,
+			"Variant." + ciKey: true, "Variant." + dependsOnKey: true,
+		}
+	}
+
+	unused := make(map[string][]string)
+	provided := make(map[string]bool)
+
+	for _, v := range vs.Variants {
+		data := v.TemplateData()
+
+		var unreferenced []string
+		unreferencedPaths(data, nil, refs, ignored, &unreferenced)
+		for _, key := range unreferenced {
+			unused[key] = append(unused[key], *v.Name)
+		}
+
+		for _, ref := range refs {
+			if providesPath(data, ref.path) {
+				provided[strings.Join(ref.path, ".")] = true
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(unused))
+	for key := range unused {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		issues++
+		utils.Warn(
+			"Key '%s' of variant(s) '%s' is never referenced by the templates",
+			key, strings.Join(unused[key], "', '"),
+		)
+	}
+
+	reported := make(map[string]bool)
+	for _, ref := range refs {
+		field := strings.Join(ref.path, ".")
+		if provided[field] || reported[field] {
+			continue
+		}
+
+		reported[field] = true
+		issues++
+		utils.Warn(
+			"Field '.%s' referenced in template '%s' (%s) is not provided by any variant",
+			field, ref.template, ref.source,
+		)
+	}
+
+	return issues
+}
+
+func runLint(_ *cobra.Command, _ []string) {
+	templater := newTemplater()
+	variants := newVariants()
+
+	verifyDataLayout(templater.DataLayout)
+	initTemplateFuncs(templater)
+
+	templater.loadVariants(variants)
+
+	all := !lintVars
+	issues := 0
+
+	if all || lintVars {
+		issues += lintVariables(templater, variants)
+	}
+
+	if issues > 0 {
+		utils.Error("Lint found %d issue(s)", issues)
+	}
+
+	utils.Info("No issues found")
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+var (
+	listDetect bool
+
+	listCMD = &cobra.Command{
+		Use:   "list",
+		Short: "List the variants with their images and descriptions",
+		Long: "List the variants in the order they are rendered with their output file, image " +
+			"references and descriptions without rendering the Dockerfiles",
+		Args: cobra.NoArgs,
+		Run:  runList,
+	}
+)
+
+func init() {
+	listCMD.Flags().BoolVar(
+		&listDetect, "detect", false,
+		"Render the Dockerfiles in memory to detect variants building on the images of other variants",
+	)
+	addFormatFlag(listCMD)
+
+	TemplaterCMD.AddCommand(listCMD)
+}
+
+// A variant as listed by the list command.
+type listEntry struct {
+	Name        string   `json:"name" yaml:"name"`
+	File        string   `json:"file" yaml:"file"`
+	Images      []string `json:"images" yaml:"images"`
+	DependsOn   []string `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+func runList(_ *cobra.Command, _ []string) {
+	templater := newTemplater()
+	variants := newVariants()
+
+	verifyDataLayout(templater.DataLayout)
+	verifyFormat(outputFormat)
+	initTemplateFuncs(templater)
+
+	templater.loadVariants(variants)
+
+	if listDetect {
+		templater.initTemplate()
+		for _, v := range variants.Variants {
+			templater.collectFromImages(v, templater.renderDockerfile(v))
+		}
+	}
+	deps := dependencyNames(variants.Variants, templater.fromImages)
+
+	entries := make([]listEntry, 0, len(variants.Variants))
+	for _, v := range variants.Variants {
+		entries = append(entries, listEntry{
+			Name:        *v.Name,
+			File:        v.OutputFile(),
+			Images:      v.ImageRefs(""),
+			DependsOn:   deps[*v.Name],
+			Description: v.Description(),
+		})
+	}
+
+	printFormatted(entries, func(out io.Writer) {
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tFILE\tIMAGES\tDEPENDS ON\tDESCRIPTION")
+
+		for _, e := range entries {
+			// Only the first line of a description is shown in the table
+			description, _, _ := strings.Cut(e.Description, "\n")
+
+			fmt.Fprintf(
+				w, "%s\t%s\t%s\t%s\t%s\n",
+				e.Name, e.File, strings.Join(e.Images, ", "),
+				strings.Join(e.DependsOn, ", "), description,
+			)
+		}
+
+		if err := w.Flush(); err != nil {
+			utils.Error("%s", err)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// The name of the lock file in the output directory.
+const lockFileName = ".dtpl.lock"
+
+// How often a held lock is checked while waiting for it.
+const lockPollInterval = 200 * time.Millisecond
+
+// A lock on an output directory which prevents concurrent runs from
+// writing to it.
+type outputLock struct {
+	path     string
+	released bool
+}
+
+// Returns the content of the lock file identifying this process.
+func lockOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%d %s\n", os.Getpid(), host)
+}
+
+// Returns whether the lock file was left by a process of this host which
+// is no longer running. Liveness cannot be checked on windows.
+func isStaleLock(content string) bool {
+	fields := strings.Fields(content)
+	if len(fields) != 2 || runtime.GOOS == "windows" {
+		return false
+	}
+
+	host, _ := os.Hostname()
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil || fields[1] != host {
+		return false
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return true
+	}
+
+	return proc.Signal(syscall.Signal(0)) != nil
+}
+
+// Returns the path of the lock which is held next to the output directory
+// while it is replaced by a staging directory (--out.atomic). The lock in
+// the directory cannot protect the moment the directory is renamed.
+func replaceLockPath(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		utils.Error("%s", err)
+	}
+	return filepath.Join(filepath.Dir(abs), "."+filepath.Base(abs)+lockFileName)
+}
+
+// Creates the lock file exclusively, returns false if it exists.
+func createLock(path string) (bool, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	_, err = file.WriteString(lockOwner())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return false, fmt.Errorf("could not write lock '%s': %s", path, err)
+	}
+
+	return true, nil
+}
+
+// Returns the content of the lock if it is held, stale locks are removed.
+func heldLock(path string) (string, bool) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+
+	if isStaleLock(string(content)) {
+		utils.Warn(
+			"Removing stale lock '%s' of a process which is no longer running", path,
+		)
+		_ = os.Remove(path)
+		return "", false
+	}
+
+	return string(content), true
+}
+
+// Locks the output directory. If it is locked by another run, the lock is
+// retried until the timeout elapsed, a zero timeout fails immediately.
+// The lock is released on errors too.
+func lockOutputDir(dir string, timeout time.Duration) *outputLock {
+	lock := &outputLock{path: filepath.Join(dir, lockFileName)}
+	replaceLock := replaceLockPath(dir)
+	deadline := time.Now().Add(timeout)
+	waiting := false
+
+	for {
+		holder, replacing := heldLock(replaceLock)
+
+		if !replacing {
+			acquired, err := createLock(lock.path)
+			if err != nil {
+				// The directory is missing while another run replaces it
+				if holder, replacing = heldLock(replaceLock); !replacing {
+					utils.Error("Could not lock output directory '%s': %s", dir, err)
+				}
+			}
+
+			if acquired {
+				// A run replacing the directory may have started meanwhile
+				// and the directory may be a new one created by this run
+				if holder, replacing = heldLock(replaceLock); !replacing {
+					utils.Debug("Locked output directory '%s'", dir)
+					utils.AtExit(lock.Release)
+					return lock
+				}
+				_ = os.Remove(lock.path)
+			}
+		}
+
+		if !replacing {
+			var held bool
+			if holder, held = heldLock(lock.path); !held {
+				continue
+			}
+		}
+
+		if time.Now().After(deadline) {
+			held := lock.path
+			if replacing {
+				held = replaceLock
+			}
+			utils.Error(
+				"Output directory '%s' is locked by another run (process %s), "+
+					"remove '%s' if no other run is active or wait with --%s",
+				dir, strings.Join(strings.Fields(holder), " on "),
+				held, outWaitFlag,
+			)
+		}
+
+		if !waiting {
+			utils.Info("Waiting for the lock on the output directory '%s'", dir)
+			waiting = true
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// Takes the lock next to the output directory while it is replaced, the
+// caller must hold the lock of the directory. Only a stale lock of a run
+// which crashed while replacing the directory can exist.
+func lockReplace(dir string) *outputLock {
+	lock := &outputLock{path: replaceLockPath(dir)}
+
+	for {
+		acquired, err := createLock(lock.path)
+		if err != nil {
+			utils.Error("Could not lock output directory '%s': %s", dir, err)
+		}
+		if acquired {
+			utils.AtExit(lock.Release)
+			return lock
+		}
+
+		if holder, held := heldLock(lock.path); held {
+			utils.Error(
+				"Output directory '%s' is being replaced by another run (process %s), "+
+					"remove '%s' if no other run is active",
+				dir, strings.Join(strings.Fields(holder), " on "), lock.path,
+			)
+		}
+	}
+}
+
+// Releases the lock.
+func (l *outputLock) Release() {
+	if l.released {
+		return
+	}
+	l.released = true
+
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		utils.Warn("Could not release lock '%s': %s", l.path, err)
+	}
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// The name of the manifest in the output directory.
+const manifestFile = ".dtpl-manifest.yml"
+
+// A file generated for a variant.
+type generatedFile struct {
+	// The path relative to the output directory with forward slashes.
+	Path        string `yaml:"path"`
+	Variant     string `yaml:"variant"`
+	Description string `yaml:"description,omitempty"`
+	SHA256      string `yaml:"sha256,omitempty"`
+}
+
+// The files written by the templater. They are kept in memory unless they
+// are spooled (when streaming), then they are appended to a temporary file
+// so the memory does not grow with the number of variants.
+type generatedFiles struct {
+	files []generatedFile
+	spool *os.File
+	count int
+	// Maps the recorded paths when they are read, e.g. from the staging
+	// directory to the output directory.
+	mapPath func(string) string
+}
+
+// Spools the files added from now on to a temporary file.
+func (g *generatedFiles) Spool() {
+	spool, err := os.CreateTemp("", "dtpl-written-")
+	if err != nil {
+		utils.Error("Could not create the list of written files: %s", err)
+	}
+	g.spool = spool
+
+	// Errors exit without running deferred functions
+	utils.AtExit(g.Close)
+}
+
+// Adds a written file.
+func (g *generatedFiles) Add(file generatedFile) {
+	g.count++
+
+	if g.spool == nil {
+		g.files = append(g.files, file)
+		return
+	}
+
+	if err := json.NewEncoder(g.spool).Encode(file); err != nil {
+		utils.Error("Could not record the written file '%s': %s", file.Path, err)
+	}
+}
+
+// Returns the number of written files.
+func (g *generatedFiles) Len() int {
+	return g.count
+}
+
+// Passes the written files to handle in the order they were added.
+func (g *generatedFiles) Each(handle func(file generatedFile)) {
+	emit := func(file generatedFile) {
+		if g.mapPath != nil {
+			file.Path = g.mapPath(file.Path)
+		}
+		handle(file)
+	}
+
+	if g.spool == nil {
+		for _, file := range g.files {
+			emit(file)
+		}
+		return
+	}
+
+	if _, err := g.spool.Seek(0, io.SeekStart); err != nil {
+		utils.Error("Could not read the list of written files: %s", err)
+	}
+
+	decoder := json.NewDecoder(g.spool)
+	for {
+		var file generatedFile
+		if err := decoder.Decode(&file); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			utils.Error("Could not read the list of written files: %s", err)
+		}
+		emit(file)
+	}
+}
+
+// Removes the spooled files.
+func (g *generatedFiles) Close() {
+	if g.spool == nil {
+		return
+	}
+
+	g.spool.Close()
+	if err := os.Remove(g.spool.Name()); err != nil {
+		utils.Warn("Could not remove '%s': %s", g.spool.Name(), err)
+	}
+	g.spool = nil
+}
+
+// The manifest lists the files generated by the runs writing to an output
+// directory.
+type manifest struct {
+	Version string          `yaml:"version"`
+	Files   []generatedFile `yaml:"files"`
+}
+
+// Writes the manifest of the generated files to the output directory, the
+// files are written one at a time so streamed runs do not hold them all.
+// The files of previous runs which were not written again are kept as long
+// as they exist, so clean removes them too (e.g. after a filtered run).
+func (t *templater) writeManifest() {
+	path := t.outputPath(manifestFile)
+	utils.Debug(
+		"Writing manifest to '%s'", path,
+	)
+
+	previous := make(map[string]bool)
+	var previousFiles []generatedFile
+	if m := readManifest(t.OutputDir); m != nil {
+		for _, file := range m.Files {
+			if !previous[file.Path] {
+				previous[file.Path] = true
+				previousFiles = append(previousFiles, file)
+			}
+		}
+	}
+
+	t.rollback.Track(path)
+	out, err := os.Create(path)
+	if err != nil {
+		utils.Error(
+			"Could not write manifest to '%s': %s", path, err,
+		)
+	}
+	defer out.Close()
+
+	header, err := yaml.Marshal(manifest{Version: version})
+	if err != nil {
+		utils.Error("Could not encode manifest: %s", err)
+	}
+	// The files of the header are empty, they are appended below
+	header = bytes.Replace(header, []byte("files: []\n"), []byte("files:\n"), 1)
+
+	write := func(content []byte) {
+		if _, err := out.Write(content); err != nil {
+			utils.Error(
+				"Could not write manifest to '%s': %s", path, err,
+			)
+		}
+	}
+	write(header)
+
+	writeFile := func(file generatedFile) {
+		entry, err := yaml.Marshal([]generatedFile{file})
+		if err != nil {
+			utils.Error("Could not encode manifest: %s", err)
+		}
+		write(entry)
+	}
+
+	t.written.Each(func(file generatedFile) {
+		desc := utils.FileDescriptor(t.OutputDir, file.Path)
+		delete(previous, desc.Name)
+		writeFile(generatedFile{
+			Path:        desc.Name,
+			Variant:     file.Variant,
+			Description: file.Description,
+			SHA256:      desc.Digest["sha256"],
+		})
+	})
+
+	for _, file := range previousFiles {
+		if !previous[file.Path] {
+			continue
+		}
+
+		if _, err := os.Stat(filepath.Join(t.OutputDir, filepath.FromSlash(file.Path))); err != nil {
+			utils.Debug("Removing '%s' which no longer exists from the manifest", file.Path)
+			continue
+		}
+		writeFile(file)
+	}
+}
+
+// Reads the manifest of the output directory, returns nil if there is none.
+func readManifest(dir string) *manifest {
+	path := filepath.Join(dir, manifestFile)
+
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	m := &manifest{}
+	utils.LoadYMLFromFile(path, m)
+
+	return m
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Supported formats of the run report posted to the webhook.
+const (
+	notifyFormatGeneric = "generic"
+	notifyFormatSlack   = "slack"
+)
+
+// The annotation of the commands whose runs are reported to the webhook.
+const notifyAnnotation = "notify"
+
+// Outcomes of a reported run.
+const (
+	runSucceeded = "success"
+	runFailed    = "failure"
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{TemplaterCMD, buildCMD, bundleRenderCMD} {
+		if cmd.Annotations == nil {
+			cmd.Annotations = make(map[string]string)
+		}
+		cmd.Annotations[notifyAnnotation] = "true"
+	}
+}
+
+// The summary of a run posted to the webhook.
+type runReport struct {
+	Command  string   `json:"command"`
+	Status   string   `json:"status"`
+	Version  string   `json:"version"`
+	Duration string   `json:"duration"`
+	Variants int      `json:"variants"`
+	Files    []string `json:"files"`
+	Error    string   `json:"error,omitempty"`
+
+	started time.Time
+}
+
+// The report of the current run, nil if it is not reported.
+var report *runReport
+
+// Verifies that the format of the run report is supported and fails if not.
+func verifyNotifyFormat(format string) {
+	switch format {
+	case notifyFormatGeneric, notifyFormatSlack:
+	default:
+		utils.Error(
+			"Invalid notification format '%s', must be one of '%s' or '%s'",
+			format, notifyFormatGeneric, notifyFormatSlack,
+		)
+	}
+}
+
+// Starts the report of the run if a webhook is configured and the command
+// renders Dockerfiles. Failures are reported before exiting.
+func startRunReport(cmd *cobra.Command) {
+	if viper.GetString(notifyWebhookFlag) == "" || cmd.Annotations[notifyAnnotation] == "" {
+		return
+	}
+	verifyNotifyFormat(viper.GetString(notifyFormatFlag))
+
+	report = &runReport{
+		Command: cmd.CommandPath(),
+		Version: version,
+		Files:   make([]string, 0),
+		started: time.Now(),
+	}
+
+	utils.AtExit(func() {
+		report.Error = utils.LastError()
+		sendRunReport(runFailed)
+	})
+}
+
+// Adds the files written by the templater to the report of the run.
+func (t *templater) addToRunReport() {
+	if report == nil {
+		return
+	}
+
+	report.Variants += t.rendered
+	t.written.Each(func(file generatedFile) {
+		report.Files = append(report.Files, file.Path)
+	})
+}
+
+// Returns the report as message of a Slack incoming webhook.
+func (r *runReport) slackMessage() map[string]string {
+	if r.Status == runFailed {
+		return map[string]string{
+			"text": fmt.Sprintf(
+				":x: `%s` failed after %s: %s", r.Command, r.Duration, r.Error,
+			),
+		}
+	}
+
+	return map[string]string{
+		"text": fmt.Sprintf(
+			":white_check_mark: `%s` rendered %d variant(s) and wrote %d file(s) in %s",
+			r.Command, r.Variants, len(r.Files), r.Duration,
+		),
+	}
+}
+
+// Posts the report of the run with the status to the webhook, failures to
+// deliver it are only logged.
+func sendRunReport(status string) {
+	if report == nil {
+		return
+	}
+
+	report.Status = status
+	report.Duration = time.Since(report.started).Round(time.Millisecond).String()
+
+	var payload interface{} = report
+	if viper.GetString(notifyFormatFlag) == notifyFormatSlack {
+		payload = report.slackMessage()
+	}
+
+	webhook := viper.GetString(notifyWebhookFlag)
+	if err := utils.PostJSON(webhook, payload); err != nil {
+		utils.Warn("Could not send the run report: %s", err)
+		return
+	}
+
+	utils.Debug("Sent the run report (%s) to the webhook", status)
+}
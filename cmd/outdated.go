@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+var (
+	outdatedWrite bool
+
+	outdatedCMD = &cobra.Command{
+		Use:   "outdated",
+		Short: "Report base images with newer patch versions or digests",
+		Long: "Render the Dockerfiles in memory and check the registries of their base images for " +
+			"newer patch versions of the tags and newer digests of pinned references. With --write " +
+			"the values are updated in the variants definition",
+		Args: cobra.NoArgs,
+		Run:  runOutdated,
+	}
+)
+
+func init() {
+	outdatedCMD.Flags().BoolVar(
+		&outdatedWrite, "write", false,
+		"Update the outdated values in the variants definition in place",
+	)
+	addFormatFlag(outdatedCMD)
+
+	TemplaterCMD.AddCommand(outdatedCMD)
+}
+
+// An outdated base image as reported by the outdated command.
+type outdatedEntry struct {
+	Image    string   `json:"image" yaml:"image"`
+	Latest   string   `json:"latest" yaml:"latest"`
+	Update   string   `json:"update" yaml:"update"`
+	Variants []string `json:"variants" yaml:"variants"`
+
+	// The values of the variant data to replace when writing.
+	replacements map[string]string
+}
+
+// Returns the reference of the image after applying the update.
+func updatedReference(ref string, update *utils.ImageUpdate) string {
+	name, digest, _ := strings.Cut(ref, "@")
+	if update.Tag != "" {
+		name = name[:strings.LastIndex(name, ":")+1] + update.Tag
+	}
+	if update.Digest != "" {
+		digest = update.Digest
+	}
+	if digest != "" {
+		return name + "@" + digest
+	}
+	return name
+}
+
+// Returns the string values of the data which contain the outdated tag or
+// digest of the image, mapped to their updated value.
+func outdatedValues(
+	data map[string]interface{},
+	image utils.ImageReference,
+	update *utils.ImageUpdate,
+	res map[string]string,
+) {
+	for _, val := range data {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			outdatedValues(v, image, update, res)
+		case string:
+			updated := v
+			if update.Tag != "" {
+				if v == image.Tag {
+					updated = update.Tag
+				} else if strings.HasSuffix(v, ":"+image.Tag) || strings.Contains(v, ":"+image.Tag+"@") {
+					updated = strings.Replace(v, ":"+image.Tag, ":"+update.Tag, 1)
+				}
+			}
+			if update.Digest != "" && image.Digest != "" {
+				updated = strings.Replace(updated, image.Digest, update.Digest, 1)
+			}
+			if updated != v {
+				res[v] = updated
+			}
+		}
+	}
+}
+
+// Writes the updated values to the variants definition.
+func writeOutdated(vs *variants, entries []outdatedEntry) {
+	content := vs.readDefinition()
+
+	replacements := make(map[string]string)
+	for _, e := range entries {
+		if len(e.replacements) == 0 {
+			utils.Warn(
+				"'%s' is not defined in the variants, it cannot be updated", e.Image,
+			)
+		}
+		for old, updated := range e.replacements {
+			replacements[old] = updated
+		}
+	}
+
+	updated, count, err := utils.ReplaceYMLScalars(content, replacements)
+	if err != nil {
+		utils.Error("Could not parse '%s': %s", vs.VariantsTplFile, err)
+	}
+
+	vs.writeDefinition(content, updated, count, count)
+}
+
+func runOutdated(_ *cobra.Command, _ []string) {
+	templater := newTemplater()
+	variants := newVariants()
+
+	verifyDataLayout(templater.DataLayout)
+	verifyFormat(outputFormat)
+	initTemplateFuncs(templater)
+
+	templater.loadVariants(variants)
+	templater.initTemplate()
+
+	templater.VerifyBaseImages = true
+	for _, v := range variants.Variants {
+		templater.collectBaseImages(v, templater.renderDockerfile(v))
+	}
+
+	var entries []outdatedEntry
+
+	progress := utils.NewProgress("Checking", len(templater.baseImageOrder))
+	for _, ref := range templater.baseImageOrder {
+		progress.Step(ref)
+
+		update, err := utils.CheckImageUpdate(ref)
+		if err != nil {
+			utils.Warn("Could not check '%s': %s", ref, err)
+			continue
+		}
+		if update == nil {
+			utils.Debug("'%s' is up to date", ref)
+			continue
+		}
+
+		kind := "digest"
+		if update.Tag != "" {
+			kind = "patch"
+		}
+
+		entry := outdatedEntry{
+			Image:        ref,
+			Latest:       updatedReference(ref, update),
+			Update:       kind,
+			Variants:     templater.baseImages[ref],
+			replacements: make(map[string]string),
+		}
+
+		image := utils.ParseImageReference(ref)
+		for _, v := range variants.Variants {
+			for _, name := range entry.Variants {
+				if *v.Name != name {
+					continue
+				}
+				// The image of the variant itself is not a base image
+				data := utils.CopyMap(v.Data)
+				delete(data, "image")
+				delete(data, "name")
+				outdatedValues(data, image, update, entry.replacements)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+	progress.Done()
+
+	if entries == nil {
+		entries = []outdatedEntry{}
+	}
+
+	printFormatted(entries, func(out io.Writer) {
+		if len(entries) == 0 {
+			utils.Info("All base images are up to date")
+			return
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "IMAGE\tLATEST\tUPDATE\tVARIANTS")
+
+		for _, e := range entries {
+			fmt.Fprintf(
+				w, "%s\t%s\t%s\t%s\n",
+				e.Image, e.Latest, e.Update, strings.Join(e.Variants, ", "),
+			)
+		}
+
+		if err := w.Flush(); err != nil {
+			utils.Error("%s", err)
+		}
+	})
+
+	if outdatedWrite && len(entries) > 0 {
+		writeOutdated(variants, entries)
+	}
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Evaluates the policies against the rendered Dockerfile of a variant and
+// fails listing the violations.
+func (t *templater) checkPolicies(v *variant, rendered []byte) {
+	if len(t.PolicyFiles) == 0 {
+		return
+	}
+
+	violations, err := utils.EvaluatePolicies(
+		t.PolicyFiles, t.PolicyQuery, utils.NewPolicyInput(*v.Name, rendered),
+	)
+	if err != nil {
+		utils.Error("Could not evaluate the policies for variant '%s': %s", *v.Name, err)
+	}
+
+	if len(violations) > 0 {
+		utils.Error(
+			"Dockerfile of variant '%s' violates the policies:\n - %s",
+			*v.Name, strings.Join(violations, "\n - "),
+		)
+	}
+}
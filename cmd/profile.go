@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Serves the pprof endpoints (/debug/pprof/) on the address in the
+// background for the lifetime of the templater.
+func startProfiling(addr string) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		utils.Error("Could not serve pprof on '%s': %s", addr, err)
+	}
+
+	utils.Info(
+		"Serving pprof on 'http://%s/debug/pprof/'", listener.Addr(),
+	)
+
+	go func() {
+		if err := http.Serve(listener, nil); err != nil {
+			utils.Warn("pprof server stopped: %s", err)
+		}
+	}()
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Returns the files the Dockerfiles were generated from.
+func (t *templater) inputFiles(v *variants) []string {
+	files := []string{v.VariantsTplFile}
+
+	if v.VariantsCfgFile != "" {
+		files = append(files, v.VariantsCfgFile)
+	}
+	files = append(files, v.ValuesFiles...)
+
+	if config != "" {
+		files = append(files, config)
+	}
+
+	files = append(files, t.DockerfileTpl)
+
+	if t.DockerignoreTpl != "" {
+		files = append(files, t.DockerignoreTpl)
+	}
+
+	bases := make([]string, 0, len(t.templates))
+	for base := range t.templates {
+		if base != "" {
+			bases = append(bases, base)
+		}
+	}
+	sort.Strings(bases)
+	files = append(files, bases...)
+
+	for _, dir := range t.DockerfileTplDirs {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.tpl"))
+		if err != nil {
+			utils.Error(
+				"Could not list templates in '%s': %s", dir, err,
+			)
+		}
+		files = append(files, matches...)
+	}
+
+	return files
+}
+
+// Returns the parameters of the run which influence the generated files.
+func provenanceParameters() map[string]interface{} {
+	params := make(map[string]interface{})
+
+	for _, flag := range []string{
+		tplAdditionalVarsFlag,
+		tplStringVarsFlag,
+		tplJSONVarsFlag,
+		tplSnippetsFlag,
+		variantsKeyFlag,
+		outFmtFlag,
+		outEOLFlag,
+		mergeStrategyFlag,
+		dataLayoutFlag,
+	} {
+		params[flag] = viper.Get(flag)
+	}
+
+	return params
+}
+
+// Writes the provenance statement of the run if requested.
+func writeProvenance(t *templater, v *variants) {
+	file := viper.GetString(provenanceFileFlag)
+	if file == "" {
+		return
+	}
+
+	seen := make(map[string]bool)
+	var deps []utils.ResourceDescriptor
+
+	for _, input := range t.inputFiles(v) {
+		if seen[input] {
+			continue
+		}
+		seen[input] = true
+		deps = append(deps, utils.FileDescriptor(".", input))
+	}
+
+	subjects := make([]utils.ResourceDescriptor, 0, t.written.Len())
+	t.written.Each(func(output generatedFile) {
+		subjects = append(subjects, utils.FileDescriptor(t.OutputDir, output.Path))
+	})
+
+	if abs, err := filepath.Abs(file); err == nil {
+		t.rollback.Track(abs)
+	}
+
+	utils.WriteProvenance(
+		utils.NewProvenanceStatement(
+			version,
+			buildTime().Format(time.RFC3339),
+			provenanceParameters(),
+			deps,
+			subjects,
+		),
+		file,
+		viper.GetString(provenanceKeyFlag),
+	)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+var (
+	renderOutput string
+
+	renderCMD = &cobra.Command{
+		Use:   "render <variant>",
+		Short: "Render the Dockerfile of a single variant to stdout or a file",
+		Long: "Render the Dockerfile of a single variant to stdout or a file without touching " +
+			"the output directory, e.g. to inspect it or to pipe it to 'docker build -f -'",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeVariantNames,
+		Run:               runRender,
+	}
+)
+
+func init() {
+	renderCMD.Flags().StringVar(
+		&renderOutput, "file", "",
+		"Write the Dockerfile to this file instead of stdout",
+	)
+
+	TemplaterCMD.AddCommand(renderCMD)
+}
+
+// Returns the variant with the name, fails if there is none.
+func (t *variants) find(name string) *variant {
+	names := make([]string, 0, len(t.Variants))
+	for _, v := range t.Variants {
+		if *v.Name == name {
+			return v
+		}
+		names = append(names, *v.Name)
+	}
+
+	hint := ""
+	if suggestions := utils.Suggestions(name, names); len(suggestions) > 0 {
+		hint = ", did you mean '" + strings.Join(suggestions, "', '") + "'?"
+	}
+	utils.Error("Variant '%s' does not exist%s", name, hint)
+
+	return nil
+}
+
+func runRender(_ *cobra.Command, args []string) {
+	templater := newTemplater()
+	variants := newVariants()
+
+	verifyDataLayout(templater.DataLayout)
+	utils.VerifyEOL(templater.OutputEOL)
+	initTemplateFuncs(templater)
+
+	if viper.GetBool(variantsStreamFlag) {
+		utils.Error(
+			"Streaming the variants (--%s) is not supported when rendering a single variant",
+			variantsStreamFlag,
+		)
+	}
+
+	templater.loadVariants(variants)
+	variant := variants.find(args[0])
+
+	templater.initTemplate()
+	rendered := templater.renderDockerfile(variant)
+
+	templater.collectBaseImages(variant, rendered)
+	templater.scanDockerfile(variant, rendered)
+	templater.verifyBaseImages()
+	templater.reportScan()
+
+	content := utils.ConvertLineEndings(rendered, templater.OutputEOL)
+
+	if renderOutput == "" {
+		if _, err := os.Stdout.Write(content); err != nil {
+			utils.Error("Could not write the Dockerfile: %s", err)
+		}
+		return
+	}
+
+	utils.Info("Writing to '%s'", renderOutput)
+	if err := os.WriteFile(renderOutput, content, 0o644); err != nil {
+		utils.Error("Could not write '%s': %s", renderOutput, err)
+	}
+}
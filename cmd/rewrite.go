@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Reads the variants definition in order to rewrite it, fails if it is a
+// template, jsonnet or CUE since the rendered values cannot be written back.
+func (t *variants) readDefinition() []byte {
+	if t.isTemplated() || t.isEvaluated() {
+		utils.Error(
+			"The variants definition '%s' is a template or evaluated and cannot be rewritten, "+
+				"update the values manually", t.VariantsTplFile,
+		)
+	}
+
+	content, err := os.ReadFile(t.VariantsTplFile)
+	if err != nil {
+		utils.Error("Could not read '%s': %s", t.VariantsTplFile, err)
+	}
+
+	return content
+}
+
+// Replaces the variants definition with the edited content (see
+// utils.ReplaceYMLNodes) after verifying that it is still valid yml with
+// the same documents. The file is replaced atomically, so an interrupted
+// write never leaves a truncated definition behind.
+func (t *variants) writeDefinition(original []byte, edited []byte, count int, expected int) {
+	if count < expected {
+		utils.Warn(
+			"%d value(s) could not be edited in place (block scalars or plain scalars "+
+				"spanning multiple lines), update them manually", expected-count,
+		)
+	}
+	if count == 0 {
+		return
+	}
+
+	before, err := utils.DecodeYMLNodes(original)
+	if err != nil {
+		utils.Error("Could not parse '%s': %s", t.VariantsTplFile, err)
+	}
+	after, err := utils.DecodeYMLNodes(edited)
+	if err != nil || len(after) != len(before) {
+		utils.Error(
+			"Rewriting '%s' would corrupt the file, it was not changed: %v",
+			t.VariantsTplFile, err,
+		)
+	}
+
+	info, err := os.Stat(t.VariantsTplFile)
+	if err != nil {
+		utils.Error("%s", err)
+	}
+
+	if err := replaceFile(t.VariantsTplFile, edited, info.Mode()); err != nil {
+		utils.Error("Could not write '%s': %s", t.VariantsTplFile, err)
+	}
+
+	utils.Info("Updated %d value(s) in '%s'", count, t.VariantsTplFile)
+}
+
+// Replaces the file with the content by writing a temporary file next to
+// it and renaming it. Symlinks are resolved so the link is kept.
+func replaceFile(file string, content []byte, mode os.FileMode) error {
+	if resolved, err := filepath.EvalSymlinks(file); err == nil {
+		file = resolved
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+"-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
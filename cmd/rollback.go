@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Records the state of the files a run writes, so the state before the run
+// can be restored if it fails.
+type rollback struct {
+	// The directory holding copies of the overwritten files.
+	dir     string
+	files   []backupFile
+	tracked map[string]bool
+	// The directories created by the run in the order of creation.
+	created []string
+	done    bool
+}
+
+// A file written by the run.
+type backupFile struct {
+	path string
+	// The copy of the previous content, empty if the file did not exist.
+	backup string
+	mode   fs.FileMode
+}
+
+// Returns a new rollback which restores the tracked files if the
+// templater exits because of an error.
+func newRollback() *rollback {
+	dir, err := os.MkdirTemp("", "dtpl-rollback-")
+	if err != nil {
+		utils.Error("Could not create rollback directory: %s", err)
+	}
+
+	r := &rollback{dir: dir, tracked: make(map[string]bool)}
+	utils.AtExit(r.Restore)
+
+	return r
+}
+
+// Records the state of a file before it is written for the first time,
+// as well as the directories which will be created for it.
+func (r *rollback) Track(path string) {
+	if r == nil || r.tracked[path] {
+		return
+	}
+	r.tracked[path] = true
+
+	var missing []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil || dir == filepath.Dir(dir) {
+			break
+		}
+		missing = append(missing, dir)
+	}
+	for idx := len(missing) - 1; idx >= 0; idx-- {
+		r.created = append(r.created, missing[idx])
+	}
+
+	file := backupFile{path: path}
+
+	info, err := os.Stat(path)
+	if err == nil {
+		file.mode = info.Mode()
+		file.backup = filepath.Join(r.dir, fmt.Sprintf("%d", len(r.files)))
+		if err := copyFile(path, file.backup); err != nil {
+			utils.Error("Could not back up '%s': %s", path, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		utils.Error("Could not back up '%s': %s", path, err)
+	}
+
+	r.files = append(r.files, file)
+}
+
+// Restores the tracked files and removes the created directories.
+func (r *rollback) Restore() {
+	if r == nil || r.done {
+		return
+	}
+	r.done = true
+
+	utils.Warn("Restoring the state of the output before the run")
+
+	for idx := len(r.files) - 1; idx >= 0; idx-- {
+		file := r.files[idx]
+
+		var err error
+		if file.backup == "" {
+			if err = os.Remove(file.path); errors.Is(err, fs.ErrNotExist) {
+				err = nil
+			}
+		} else if err = copyFile(file.backup, file.path); err == nil {
+			err = os.Chmod(file.path, file.mode)
+		}
+
+		if err != nil {
+			utils.Warn("Could not restore '%s': %s", file.path, err)
+		}
+	}
+
+	for idx := len(r.created) - 1; idx >= 0; idx-- {
+		_ = os.Remove(r.created[idx])
+	}
+
+	r.cleanup()
+}
+
+// Discards the recorded state after a successful run.
+func (r *rollback) Discard() {
+	if r == nil || r.done {
+		return
+	}
+	r.done = true
+
+	r.cleanup()
+}
+
+// Removes the copies of the overwritten files.
+func (r *rollback) cleanup() {
+	if err := os.RemoveAll(r.dir); err != nil {
+		utils.Warn("Could not remove rollback directory '%s': %s", r.dir, err)
+	}
+}
+
+// Copies the content of a file.
+func copyFile(src string, dst string) error {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, content, 0o600)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// The findings of the config scanner for the Dockerfile of a variant.
+type scanEntry struct {
+	Variant  string              `json:"variant"`
+	Findings []utils.ScanFinding `json:"findings"`
+}
+
+// Scans the rendered Dockerfile of a variant for misconfigurations, the
+// findings are reported once all variants are rendered.
+func (t *templater) scanDockerfile(v *variant, rendered []byte) {
+	if !t.ScanConfig {
+		return
+	}
+
+	defer utils.Measure("scan")()
+
+	findings, err := utils.ScanDockerfile(rendered)
+	if err != nil {
+		utils.Error("Could not scan the Dockerfile of variant '%s': %s", *v.Name, err)
+	}
+
+	if findings == nil {
+		findings = []utils.ScanFinding{}
+	}
+	t.scanResults = append(t.scanResults, scanEntry{Variant: *v.Name, Findings: findings})
+}
+
+// Reports the findings of the config scanner, writes them to the report
+// file if configured and fails if any finding reaches the severity
+// threshold.
+func (t *templater) reportScan() {
+	if !t.ScanConfig {
+		return
+	}
+
+	threshold := utils.SeverityRank(t.ScanSeverity)
+	total, failures := 0, 0
+
+	for _, entry := range t.scanResults {
+		for _, f := range entry.Findings {
+			total++
+
+			report := utils.Info
+			if utils.SeverityRank(f.Severity) >= threshold {
+				failures++
+				report = utils.Warn
+			}
+			location := ""
+			if f.Line > 0 {
+				location = fmt.Sprintf(" (line %d)", f.Line)
+			}
+			report(
+				"Dockerfile of variant '%s'%s: %s %s: %s",
+				entry.Variant, location, f.Severity, f.ID, f.Message,
+			)
+		}
+	}
+
+	if t.ScanReport != "" {
+		content, err := json.MarshalIndent(t.scanResults, "", "  ")
+		if err != nil {
+			utils.Error("Could not encode the scan report: %s", err)
+		}
+		if err := os.WriteFile(t.ScanReport, append(content, '\n'), 0o644); err != nil {
+			utils.Error("Could not write the scan report '%s': %s", t.ScanReport, err)
+		}
+	}
+
+	if failures > 0 {
+		utils.Error(
+			"%d finding(s) of severity %s or higher in the Dockerfiles", failures, t.ScanSeverity,
+		)
+	}
+
+	utils.Info("Scanned %d Dockerfile(s), %d finding(s)", len(t.scanResults), total)
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+var (
+	schemaCMD = &cobra.Command{
+		Use:   "schema",
+		Short: "Output a JSON Schema of the variants definition",
+		Long: "Output a JSON Schema describing the structure of the variants definition, including the " +
+			"inputs declared by the Dockerfile template, for completion and validation in editors",
+		Args: cobra.NoArgs,
+		Run:  runSchema,
+	}
+)
+
+func init() {
+	TemplaterCMD.AddCommand(schemaCMD)
+}
+
+// The JSON Schema dialect of the generated schema.
+const jsonSchemaDialect = "http://json-schema.org/draft-07/schema#"
+
+// A JSON Schema object.
+type jsonSchema map[string]interface{}
+
+// Returns the JSON Schema type of an input type.
+func inputJSONType(typ string) string {
+	switch typ {
+	case inputTypeList:
+		return "array"
+	case inputTypeMap:
+		return "object"
+	case inputTypeAny:
+		return ""
+	default:
+		return typ
+	}
+}
+
+// Returns the schema of an object with the properties.
+func objectSchema(properties map[string]interface{}) jsonSchema {
+	return jsonSchema{"type": "object", "properties": properties}
+}
+
+// Adds the schema of an input at its key path to the properties.
+func addInputSchema(properties map[string]interface{}, keyPath []string, input *templateInput) {
+	if len(keyPath) > 1 {
+		nested, ok := properties[keyPath[0]].(jsonSchema)
+		if !ok {
+			nested = objectSchema(make(map[string]interface{}))
+			properties[keyPath[0]] = nested
+		}
+		addInputSchema(nested["properties"].(map[string]interface{}), keyPath[1:], input)
+		return
+	}
+
+	schema := jsonSchema{}
+	if typ := inputJSONType(input.Type); typ != "" {
+		schema["type"] = typ
+	}
+	if input.Description != "" {
+		schema["description"] = input.Description
+	}
+	if input.Default != nil {
+		schema["default"] = input.Default
+	}
+
+	properties[keyPath[0]] = schema
+}
+
+// Returns the schema of a single variant including the template inputs.
+func variantSchema(inputs *templateSchema) jsonSchema {
+	properties := map[string]interface{}{
+		"name": jsonSchema{
+			"type": "string", "description": "The unique name of the variant",
+		},
+		descriptionKey: jsonSchema{
+			"type": "string", "description": "A human readable description of the variant",
+		},
+		"image": objectSchema(map[string]interface{}{
+			"name": jsonSchema{"type": "string", "description": "The image name"},
+			"tag": jsonSchema{
+				"type": []string{"string", "number"}, "description": "The image tag",
+			},
+			"tags": jsonSchema{
+				"type":        "array",
+				"items":       jsonSchema{"type": []string{"string", "number"}},
+				"description": "Additional image tags",
+			},
+		}),
+		outputKey: objectSchema(map[string]interface{}{
+			"name": jsonSchema{
+				"type":        "string",
+				"description": "The output name format of the variant, it overrides --" + outFmtFlag,
+			},
+		}),
+		extendsKey: jsonSchema{
+			"type":        []string{"string", "array"},
+			"items":       jsonSchema{"type": "string"},
+			"description": "The variant(s) this variant inherits from",
+		},
+		baseTemplateKey: jsonSchema{
+			"type": "string", "description": "The base template of the variant",
+		},
+		dependsOnKey: jsonSchema{
+			"type":        []string{"string", "array"},
+			"items":       jsonSchema{"type": "string"},
+			"description": "The variant(s) whose images are built before the image of this variant",
+		},
+		buildArgsKey: jsonSchema{
+			"type":                 "object",
+			"additionalProperties": jsonSchema{"type": []string{"string", "number", "boolean", "null"}},
+			"description":          "Build args passed to the builders",
+		},
+		ciKey: objectSchema(map[string]interface{}{
+			"tags": jsonSchema{
+				"type":        "array",
+				"items":       jsonSchema{"type": "string"},
+				"description": "Tags of the runners the variant is built on (label or label=value)",
+			},
+			"timeout": jsonSchema{
+				"type": "string", "description": "Maximum duration of the build, e.g. 90m",
+			},
+			"priority": jsonSchema{
+				"type": "string", "description": "Priority of the build",
+			},
+		}),
+	}
+
+	if inputs != nil {
+		for _, key := range inputs.Keys() {
+			addInputSchema(properties, strings.Split(key, "."), inputs.Inputs[key])
+		}
+	}
+
+	schema := objectSchema(properties)
+	schema["required"] = []string{"name"}
+
+	return schema
+}
+
+// Returns the JSON Schema of the variants definition, the variants list is
+// placed at the variants key.
+func variantsSchema(inputs *templateSchema, key string) jsonSchema {
+	list := jsonSchema{
+		"type":  "array",
+		"items": jsonSchema{"$ref": "#/definitions/variant"},
+	}
+
+	var root jsonSchema
+	if key == "" || key == "." {
+		root = list
+	} else {
+		keyPath := strings.Split(key, ".")
+		var node interface{} = list
+		for idx := len(keyPath) - 1; idx >= 0; idx-- {
+			obj := objectSchema(map[string]interface{}{keyPath[idx]: node})
+			obj["required"] = []string{keyPath[idx]}
+			node = obj
+		}
+		root = node.(jsonSchema)
+		root["properties"].(map[string]interface{})["defaults"] = jsonSchema{
+			"type":        "object",
+			"description": "Values shared by all variants of the document",
+		}
+	}
+
+	root["$schema"] = jsonSchemaDialect
+	root["title"] = "dockerfile-templater variants"
+	root["definitions"] = map[string]interface{}{
+		"variant": variantSchema(inputs),
+	}
+
+	return root
+}
+
+func runSchema(_ *cobra.Command, _ []string) {
+	templater := newTemplater()
+
+	schema := variantsSchema(
+		loadTemplateSchema(templater.DockerfileTpl),
+		viper.GetString(variantsKeyFlag),
+	)
+
+	content, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		utils.Error("Could not encode schema: %s", err)
+	}
+
+	os.Stdout.Write(append(content, '\n'))
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// A temporary directory the files of a run are written to, it replaces the
+// output directory once the run succeeded.
+type staging struct {
+	dir    string
+	outDir string
+	done   bool
+}
+
+// Verifies that the output directory only contains files generated by the
+// previous run, as it is replaced as a whole.
+func verifyReplaceable(dir string) {
+	generated := map[string]bool{manifestFile: true, lockFileName: true}
+	if m := readManifest(dir); m != nil {
+		for _, file := range m.Files {
+			generated[file.Path] = true
+		}
+	}
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if !generated[filepath.ToSlash(rel)] {
+			utils.Error(
+				"The output directory '%s' contains '%s' which was not generated by the templater, "+
+					"it would be lost when the directory is replaced (--%s)",
+				dir, rel, outAtomicFlag,
+			)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		utils.Error("Could not check output directory '%s': %s", dir, err)
+	}
+}
+
+// Creates the staging directory next to the output directory, so it can be
+// moved into its place by renaming it. It is removed if the run fails.
+func newStaging(outDir string) *staging {
+	outDir, err := filepath.Abs(outDir)
+	if err != nil {
+		utils.Error("%s", err)
+	}
+
+	verifyReplaceable(outDir)
+
+	dir, err := os.MkdirTemp(
+		filepath.Dir(outDir), "."+filepath.Base(outDir)+".dtpl-",
+	)
+	if err != nil {
+		utils.Error("Could not create staging directory: %s", err)
+	}
+
+	// Temporary directories are only accessible by the owner
+	if info, err := os.Stat(outDir); err == nil {
+		_ = os.Chmod(dir, info.Mode().Perm())
+	}
+
+	utils.Debug("Staging the output in '%s'", dir)
+
+	s := &staging{dir: dir, outDir: outDir}
+	utils.AtExit(s.Discard)
+
+	return s
+}
+
+// Replaces the output directory with the staging directory. The lock next
+// to the output directory is held while it is renamed, the staging directory
+// gets a lock of its own so the output directory stays locked until the
+// lock is released.
+func (s *staging) Commit() {
+	replaceLock := lockReplace(s.outDir)
+	defer replaceLock.Release()
+
+	old := s.outDir + ".dtpl-old"
+	if err := os.RemoveAll(old); err != nil {
+		utils.Error("Could not remove '%s': %s", old, err)
+	}
+
+	lock := filepath.Join(s.dir, lockFileName)
+	if err := os.WriteFile(lock, []byte(lockOwner()), 0o644); err != nil {
+		utils.Error("Could not write lock '%s': %s", lock, err)
+	}
+
+	if err := os.Rename(s.outDir, old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		utils.Error("Could not move output directory '%s': %s", s.outDir, err)
+	}
+	if err := os.Rename(s.dir, s.outDir); err != nil {
+		_ = os.Rename(old, s.outDir)
+		utils.Error(
+			"Could not move staging directory '%s' to '%s': %s", s.dir, s.outDir, err,
+		)
+	}
+	s.done = true
+
+	if err := os.RemoveAll(old); err != nil {
+		utils.Warn("Could not remove previous output '%s': %s", old, err)
+	}
+
+	utils.Debug("Replaced output directory '%s'", s.outDir)
+}
+
+// Removes the staging directory if it was not committed.
+func (s *staging) Discard() {
+	if s.done {
+		return
+	}
+	s.done = true
+
+	if err := os.RemoveAll(s.dir); err != nil {
+		utils.Warn("Could not remove staging directory '%s': %s", s.dir, err)
+	}
+}
+
+// Returns the path with the staging directory replaced by the output
+// directory.
+func (s *staging) outputPath(path string) string {
+	if rel, ok := strings.CutPrefix(path, s.dir); ok {
+		return s.outDir + rel
+	}
+	return path
+}
+
+// Prepares the output directory for writing. It is locked and, if
+// requested, the files are staged or backed up for a rollback. The
+// returned function completes the writing once all files were written.
+func (t *templater) openOutput() func() {
+	lock := lockOutputDir(t.OutputDir, viper.GetDuration(outWaitFlag))
+
+	var stage *staging
+	if viper.GetBool(outAtomicFlag) {
+		stage = newStaging(t.OutputDir)
+		t.OutputDir = stage.dir
+	} else if viper.GetBool(outRollbackFlag) {
+		t.rollback = newRollback()
+	}
+
+	return func() {
+		if stage != nil {
+			stage.Commit()
+
+			t.OutputDir = stage.outDir
+			for idx := range t.outputs {
+				t.outputs[idx] = stage.outputPath(t.outputs[idx])
+			}
+			t.written.mapPath = stage.outputPath
+		}
+
+		t.rollback.Discard()
+		lock.Release()
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Returns the names of the entries in the directory.
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestStagingCommit(t *testing.T) {
+	parent := t.TempDir()
+	outDir := filepath.Join(parent, "out")
+	if err := os.Mkdir(outDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	lock := lockOutputDir(outDir, 0)
+	stage := newStaging(outDir)
+	writeFiles(t, stage.dir, "Dockerfile")
+
+	stage.Commit()
+	if !stage.done {
+		t.Error("staging is not done after the commit")
+	}
+
+	// The output directory is still locked by the run
+	if got, want := dirEntries(t, outDir), []string{lockFileName, "Dockerfile"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("output directory contains %v, want %v", got, want)
+	}
+	if _, held := heldLock(lock.path); !held {
+		t.Errorf("lock '%s' is not held after the commit", lock.path)
+	}
+
+	lock.Release()
+
+	// Neither the staging directory, the previous output nor the lock
+	// held while replacing the directory are left behind
+	if got, want := dirEntries(t, parent), []string{"out"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parent directory contains %v, want %v", got, want)
+	}
+	if got, want := dirEntries(t, outDir), []string{"Dockerfile"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("output directory contains %v after the release, want %v", got, want)
+	}
+}
+
+func TestLockOutputDirReplacing(t *testing.T) {
+	parent := t.TempDir()
+	outDir := filepath.Join(parent, "out")
+	if err := os.Mkdir(outDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another run replaces the directory, it has no lock inside
+	if err := os.WriteFile(replaceLockPath(outDir), []byte(lockOwner()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := expectExit(t, func() { lockOutputDir(outDir, 0) })
+	if want := ".out" + lockFileName + "'"; !strings.Contains(out, want) {
+		t.Errorf("error %q does not mention the lock '%s'", out, want)
+	}
+}
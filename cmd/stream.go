@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Loads the variants one at a time and passes each to handle once it is
+// complete, instead of loading all variants first. Only the current
+// document is held in memory, the yml decoder cannot decode the items of a
+// list one by one, so a document is the unit of streaming. Templated and
+// evaluated definitions are rendered to memory before they are streamed.
+// The defaults of a document apply to the variants of the document and all
+// following documents. Inheritance and sorting by name are not supported
+// as they need all variants at once.
+func (t *variants) Stream(handle func(v *variant)) {
+	utils.VerifyMergeStrategy(t.MergeStrategy)
+	verifySortOrder(t.SortOrder)
+
+	if t.SortOrder == variantsSortName {
+		utils.Error(
+			"Variants cannot be sorted by name when they are streamed",
+		)
+	}
+
+	var reader io.Reader
+	if t.isEvaluated() {
+		reader = bytes.NewReader(t.evaluate())
+	} else if t.isTemplated() {
+		reader = bytes.NewReader(t.renderTemplate())
+	} else {
+		utils.Debug(
+			"Streaming variants from '%s'", t.VariantsTplFile,
+		)
+
+		file, err := os.Open(t.VariantsTplFile)
+		if err != nil {
+			utils.Error(
+				"Failed to load file '%s': %s", t.VariantsTplFile, err,
+			)
+		}
+		defer file.Close()
+
+		reader = file
+	}
+
+	index := newVariantIndex()
+	environmentFound := false
+
+	utils.DecodeYMLDocuments(reader, func(doc *yaml.Node) {
+		if root := utils.GetYMLNodeByPath(doc, nil); root.Tag == "!!null" {
+			return
+		}
+
+		var list *yaml.Node
+		if t.VariantsKey != "" && t.VariantsKey != "." {
+			var root struct {
+				Defaults map[string]interface{} `yaml:"defaults"`
+			}
+			if err := doc.Decode(&root); err != nil {
+				utils.Error("Failed to parse yaml document: %s", err)
+			}
+			t.Defaults = utils.MergeMaps(t.Defaults, root.Defaults, t.MergeStrategy)
+
+			if list = utils.GetYMLNodeByPath(doc, strings.Split(t.VariantsKey, ".")); list == nil {
+				utils.Warn(
+					"Variants document does not contain the key '%s'", t.VariantsKey,
+				)
+				return
+			}
+		} else {
+			list = utils.GetYMLNodeByPath(doc, nil)
+		}
+
+		if list.Kind != yaml.SequenceNode {
+			utils.Error(
+				"The variants at key '%s' must be a list", t.VariantsKey,
+			)
+		}
+
+		for _, item := range list.Content {
+			v := &variant{}
+			if err := item.Decode(v); err != nil {
+				utils.Error("Failed to parse variant: %s", err)
+			}
+
+			if _, ok := v.Data[extendsKey]; ok {
+				utils.Error(
+					"Variant at line %d uses '%s' which is not supported when streaming",
+					item.Line, extendsKey,
+				)
+			}
+
+			if len(t.Defaults) > 0 {
+				v.ApplyDefaults(t.Defaults, t.MergeStrategy)
+			}
+			if v.ApplyEnvironment(t.Environment, t.MergeStrategy) {
+				environmentFound = true
+			}
+
+			v.Verify()
+
+			if duplicates := index.add(v); len(duplicates) > 0 {
+				utils.Error(
+					"Variants must be unique:\n - %s",
+					strings.Join(duplicates, "\n - "),
+				)
+			}
+
+			handle(v)
+		}
+
+		// Release the parsed document before the next one is decoded
+		list.Content = nil
+	})
+
+	if len(index.added) == 0 {
+		utils.Error("No variants configured")
+	}
+
+	if t.Environment != "" && !environmentFound {
+		utils.Warn("No variant defines the environment '%s'", t.Environment)
+	}
+}
+
+// Renders the Dockerfiles while the variants are streamed, the variants
+// are not kept after they were rendered.
+func renderStream() {
+	templater := newTemplater()
+	variants := newVariants()
+
+	templater.streaming = true
+	templater.written.Spool()
+	defer templater.written.Close()
+
+	verifyDataLayout(templater.DataLayout)
+	utils.VerifyEOL(templater.OutputEOL)
+	initTemplateFuncs(templater)
+
+	stop := utils.Measure("parse templates")
+	templater.Init()
+	stop()
+
+	closeOutput := templater.openOutput()
+
+	schema := loadTemplateSchema(templater.DockerfileTpl)
+
+	variants.Stream(func(v *variant) {
+		templater.Values = variants.Values
+
+		if schema != nil {
+			schema.Validate([]*variant{v})
+		}
+		templater.Resolve([]*variant{v})
+
+		templater.renderVariant(v)
+	})
+	templater.verifyBaseImages()
+	templater.reportScan()
+
+	stop = utils.Measure("write")
+	writeProvenance(templater, variants)
+	templater.writeManifest()
+	closeOutput()
+	stop()
+
+	templater.addToRunReport()
+}
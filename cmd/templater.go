@@ -2,12 +2,15 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"log"
 	"os"
-	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
@@ -21,29 +24,108 @@ var (
 	config       string
 	verbose      bool
 	debug        bool
+	trace        bool
+	interactive  bool
+	noColor      bool
+	stats        bool
+	pprofAddr    string
 	printVersion bool
 
 	version string = "dev"
 
 	TemplaterCMD = &cobra.Command{
-		Use:              "templater",
-		Short:            "Process Dockerfile templates",
-		Long:             "Generate Dockerfiles in multiple variants from a template",
-		PersistentPreRun: preRun,
-		Run:              run,
+		Use:               "templater",
+		Short:             "Process Dockerfile templates",
+		Long:              "Generate Dockerfiles in multiple variants from a template",
+		PersistentPreRun:  preRun,
+		PersistentPostRun: postRun,
+		Run:               run,
 	}
 )
 
 const (
 	dockerfileTplFlag     = "dockerfile.tpl"
 	dockerfileTplDirFlag  = "dockerfile.tpldir"
+	dockerfileBaseTplFlag = "dockerfile.base"
 	tplAdditionalVarsFlag = "dockerfile.var"
+	tplStringVarsFlag     = "dockerfile.stringvar"
+	tplJSONVarsFlag       = "dockerfile.jsonvar"
+	tplStrictVarsFlag     = "dockerfile.strict"
+	tplRootsFlag          = "dockerfile.root"
+	tplSnippetsFlag       = "dockerfile.snippets"
+	tplSyntaxFlag         = "dockerfile.syntax"
+	tplMergeRunsFlag      = "dockerfile.runs.merge"
+	tplDeclareArgsFlag    = "dockerfile.args.declare"
+	tplIgnoreArgsFlag     = "dockerfile.args.ignore"
+	tplAssertFlag         = "dockerfile.assert"
+	tplTidyFlag           = "dockerfile.tidy"
+	tplStripCommentsFlag  = "dockerfile.comments.strip"
 
-	variantsDefFlag = "variants.def"
-	variantsCfgFlag = "variants.cfg"
+	variantsDefFlag    = "variants.def"
+	variantsCfgFlag    = "variants.cfg"
+	variantsValuesFlag = "variants.values"
+	variantsKeyFlag    = "variants.key"
+	variantsSortFlag   = "variants.sort"
+	variantsStreamFlag = "variants.stream"
 
-	outDirFlag = "out.dir"
-	outFmtFlag = "out.fmt"
+	environmentFlag = "environment"
+
+	imageFmtFlag = "image.fmt"
+
+	flavorFlag = "flavor"
+
+	dockerignoreTplFlag = "dockerignore.tpl"
+	dockerignoreFmtFlag = "dockerignore.fmt"
+
+	outDirFlag      = "out.dir"
+	outFmtFlag      = "out.fmt"
+	outEOLFlag      = "out.eol"
+	outWaitFlag     = "out.wait"
+	outRollbackFlag = "out.rollback"
+	outAtomicFlag   = "out.atomic"
+	outFormatFlag   = "out.format"
+
+	mergeStrategyFlag = "merge.strategy"
+
+	dataLayoutFlag = "data.layout"
+
+	provenanceFileFlag = "provenance.file"
+	provenanceKeyFlag  = "provenance.key"
+
+	// The variant key selecting the base template of the variant.
+	baseTemplateKey = "base_template"
+
+	allowNetworkFlag = "allow.network"
+	allowExecFlag    = "allow.exec"
+
+	caFileFlag = "ca.file"
+
+	notifyWebhookFlag = "notify.webhook"
+	notifyFormatFlag  = "notify.format"
+
+	networkTimeoutFlag = "network.timeout"
+	networkRetriesFlag = "network.retries"
+	networkBackoffFlag = "network.backoff"
+
+	hermeticFlag = "hermetic"
+
+	funcsDisableFlag = "funcs.disable"
+
+	baseVerifyFlag = "base.verify"
+
+	policyFilesFlag = "policy.files"
+	policyQueryFlag = "policy.query"
+
+	scanConfigFlag   = "scan.config"
+	scanSeverityFlag = "scan.severity"
+	scanReportFlag   = "scan.report"
+
+	timeNowFlag  = "time.now"
+	timeZoneFlag = "time.zone"
+
+	logFileFlag = "log.file"
+	logSizeFlag = "log.size"
+	logKeepFlag = "log.keep"
 )
 
 func init() {
@@ -65,16 +147,189 @@ func init() {
 		TemplaterCMD.PersistentFlags().Lookup(dockerfileTplDirFlag),
 	)
 
-	TemplaterCMD.PersistentFlags().StringToStringP(
-		tplAdditionalVarsFlag, "a", make(map[string]string, 0),
-		"Key=Value pairs of additional variables / variable overrides which "+
-			"should be available when rendendering the Dockerfile template",
+	TemplaterCMD.PersistentFlags().String(
+		dockerfileBaseTplFlag, "",
+		"Path to a base template whose blocks may be overridden by the Dockerfile template. "+
+			"Variants may select their own base template with the key "+baseTemplateKey,
+	)
+	_ = viper.BindPFlag(
+		dockerfileBaseTplFlag,
+		TemplaterCMD.PersistentFlags().Lookup(dockerfileBaseTplFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().StringArrayP(
+		tplAdditionalVarsFlag, "a", make([]string, 0),
+		"Key=Value pair of an additional variable / variable override which "+
+			"should be available when rendendering the Dockerfile template "+
+			"(may be used multiple times, applied in order)",
 	)
 	_ = viper.BindPFlag(
 		tplAdditionalVarsFlag,
 		TemplaterCMD.PersistentFlags().Lookup(tplAdditionalVarsFlag),
 	)
 
+	TemplaterCMD.PersistentFlags().StringArray(
+		tplStringVarsFlag, make([]string, 0),
+		"Key=Value pair like dockerfile.var whose value is always treated as string",
+	)
+	_ = viper.BindPFlag(
+		tplStringVarsFlag,
+		TemplaterCMD.PersistentFlags().Lookup(tplStringVarsFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().StringArray(
+		tplJSONVarsFlag, make([]string, 0),
+		"Additional variable or variable override with a JSON value, e.g. 'build={\"static\": true}' "+
+			"(same key syntax as --"+tplAdditionalVarsFlag+", may be used multiple times)",
+	)
+	_ = viper.BindPFlag(
+		tplJSONVarsFlag,
+		TemplaterCMD.PersistentFlags().Lookup(tplJSONVarsFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Bool(
+		tplStrictVarsFlag, os.Getenv("CI") != "",
+		"Fail if an additional variable cannot be applied to a variant instead of ignoring it. "+
+			"Enabled by default if the CI environment variable is set",
+	)
+	_ = viper.BindPFlag(
+		tplStrictVarsFlag,
+		TemplaterCMD.PersistentFlags().Lookup(tplStrictVarsFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().StringArray(
+		tplRootsFlag, make([]string, 0),
+		"Additional directory the template file functions (readFile, glob) may access. "+
+			"The working directory and the template directories are always accessible",
+	)
+	_ = viper.BindPFlag(
+		tplRootsFlag,
+		TemplaterCMD.PersistentFlags().Lookup(tplRootsFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Bool(
+		tplSnippetsFlag, false,
+		"Include the built-in snippet library (templates prefixed with dtpl/)",
+	)
+	_ = viper.BindPFlag(
+		tplSnippetsFlag,
+		TemplaterCMD.PersistentFlags().Lookup(tplSnippetsFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		tplSyntaxFlag, "",
+		"Syntax the rendered Dockerfiles must declare (e.g. docker/dockerfile:1.7), "+
+			"the directive is inserted if it is missing",
+	)
+	_ = viper.BindPFlag(
+		tplSyntaxFlag,
+		TemplaterCMD.PersistentFlags().Lookup(tplSyntaxFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Bool(
+		tplMergeRunsFlag, false,
+		"Merge consecutive RUN instructions of the rendered Dockerfiles into a single one",
+	)
+	_ = viper.BindPFlag(
+		tplMergeRunsFlag,
+		TemplaterCMD.PersistentFlags().Lookup(tplMergeRunsFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Bool(
+		tplDeclareArgsFlag, false,
+		"Insert missing ARG declarations for variables used in the stages of the rendered Dockerfiles",
+	)
+	_ = viper.BindPFlag(
+		tplDeclareArgsFlag,
+		TemplaterCMD.PersistentFlags().Lookup(tplDeclareArgsFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Bool(
+		tplTidyFlag, false,
+		"Strip trailing whitespace and collapse consecutive blank lines of the rendered Dockerfiles",
+	)
+	_ = viper.BindPFlag(
+		tplTidyFlag,
+		TemplaterCMD.PersistentFlags().Lookup(tplTidyFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		tplStripCommentsFlag, "",
+		"Marker of template comments (e.g. '#~'), lines starting with it are removed from "+
+			"the rendered Dockerfiles",
+	)
+	_ = viper.BindPFlag(
+		tplStripCommentsFlag,
+		TemplaterCMD.PersistentFlags().Lookup(tplStripCommentsFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().StringArray(
+		tplIgnoreArgsFlag, make([]string, 0),
+		"Variable which is never declared automatically. "+
+			"This flag can be used multiple times",
+	)
+	_ = viper.BindPFlag(
+		tplIgnoreArgsFlag,
+		TemplaterCMD.PersistentFlags().Lookup(tplIgnoreArgsFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().StringArray(
+		tplAssertFlag, make([]string, 0),
+		"Assertion the rendered Dockerfiles must satisfy as <kind>=<value>, kind is one of "+
+			"instruction, not_instruction, contains, not_contains, matches or not_matches. "+
+			"This flag can be used multiple times",
+	)
+	_ = viper.BindPFlag(
+		tplAssertFlag,
+		TemplaterCMD.PersistentFlags().Lookup(tplAssertFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().StringArray(
+		policyFilesFlag, make([]string, 0),
+		"Rego policy file or directory the rendered Dockerfiles are evaluated against with opa. "+
+			"This flag can be used multiple times",
+	)
+	_ = viper.BindPFlag(
+		policyFilesFlag,
+		TemplaterCMD.PersistentFlags().Lookup(policyFilesFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		policyQueryFlag, "data.dockerfile.deny",
+		"Query of the policies returning the violations of a Dockerfile",
+	)
+	_ = viper.BindPFlag(
+		policyQueryFlag,
+		TemplaterCMD.PersistentFlags().Lookup(policyQueryFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Bool(
+		scanConfigFlag, false,
+		"Scan the rendered Dockerfiles for misconfigurations with trivy",
+	)
+	_ = viper.BindPFlag(
+		scanConfigFlag,
+		TemplaterCMD.PersistentFlags().Lookup(scanConfigFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		scanSeverityFlag, "HIGH",
+		"Severity of scan findings (UNKNOWN, LOW, MEDIUM, HIGH or CRITICAL) from which on the run fails",
+	)
+	_ = viper.BindPFlag(
+		scanSeverityFlag,
+		TemplaterCMD.PersistentFlags().Lookup(scanSeverityFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		scanReportFlag, "",
+		"File to write the scan findings of all variants to as JSON",
+	)
+	_ = viper.BindPFlag(
+		scanReportFlag,
+		TemplaterCMD.PersistentFlags().Lookup(scanReportFlag),
+	)
+
 	TemplaterCMD.PersistentFlags().StringP(
 		variantsDefFlag, "i", "variants.yml",
 		"Path to the variants definition. "+
@@ -95,6 +350,96 @@ func init() {
 		TemplaterCMD.PersistentFlags().Lookup(variantsCfgFlag),
 	)
 
+	TemplaterCMD.PersistentFlags().StringArray(
+		variantsValuesFlag, make([]string, 0),
+		"Path to a templated values yml rendered before the variants configuration. "+
+			"This flag can be used multiple times, each file is rendered with the values of the previous ones",
+	)
+	_ = viper.BindPFlag(
+		variantsValuesFlag,
+		TemplaterCMD.PersistentFlags().Lookup(variantsValuesFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		variantsKeyFlag, "variants",
+		"Key path (separated by dots) of the variants list in the variants definition. "+
+			"Use '.' if the list is the root of the document",
+	)
+	_ = viper.BindPFlag(
+		variantsKeyFlag,
+		TemplaterCMD.PersistentFlags().Lookup(variantsKeyFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		variantsSortFlag, variantsSortFile,
+		"Order in which the variants are rendered, either 'file' (order of definition) or 'name'",
+	)
+	_ = viper.BindPFlag(
+		variantsSortFlag,
+		TemplaterCMD.PersistentFlags().Lookup(variantsSortFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Bool(
+		variantsStreamFlag, false,
+		"Render each variant as soon as it is loaded instead of loading all variants first, "+
+			"for very large variant sets",
+	)
+	_ = viper.BindPFlag(
+		variantsStreamFlag,
+		TemplaterCMD.PersistentFlags().Lookup(variantsStreamFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		dockerignoreTplFlag, "",
+		"Path to a .dockerignore template rendered per variant",
+	)
+	_ = viper.BindPFlag(
+		dockerignoreTplFlag,
+		TemplaterCMD.PersistentFlags().Lookup(dockerignoreTplFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		dockerignoreFmtFlag, "",
+		"Name format for generated .dockerignore files, defaults to the Dockerfile name with the suffix "+
+			"'.dockerignore'. The format accepts a valid go template string which may contain any keys present in the variants",
+	)
+	_ = viper.BindPFlag(
+		dockerignoreFmtFlag,
+		TemplaterCMD.PersistentFlags().Lookup(dockerignoreFmtFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().StringP(
+		environmentFlag, "e", "",
+		"Environment whose overrides (the key "+environmentsKey+" of the variants) are merged "+
+			"over the data of the variants",
+	)
+	_ = viper.BindPFlag(
+		environmentFlag,
+		TemplaterCMD.PersistentFlags().Lookup(environmentFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		imageFmtFlag, "{{ .image.name }}:{{ .image.tag }}",
+		"Format of the image references of the variants (registry/namespace/name:tag). "+
+			"The format accepts a valid go template string which may contain any keys present in the variants, "+
+			"it is rendered once per tag",
+	)
+	_ = viper.BindPFlag(
+		imageFmtFlag,
+		TemplaterCMD.PersistentFlags().Lookup(imageFmtFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		flavorFlag, flavorDocker,
+		"Conventions of the generated files, one of 'docker' or 'containerfile'. The containerfile "+
+			"flavor defaults to the template Containerfile.tpl, the output name format "+
+			"'Containerfile.<name>.<tag>' and .containerignore files",
+	)
+	_ = viper.BindPFlag(
+		flavorFlag,
+		TemplaterCMD.PersistentFlags().Lookup(flavorFlag),
+	)
+
 	TemplaterCMD.PersistentFlags().StringP(
 		outDirFlag, "o", "dockerfiles",
 		"Directory to write generated Dockerfiles to",
@@ -114,48 +459,465 @@ func init() {
 		TemplaterCMD.PersistentFlags().Lookup(outFmtFlag),
 	)
 
-	TemplaterCMD.Flags().StringVarP(
+	TemplaterCMD.PersistentFlags().String(
+		outEOLFlag, utils.EOLLF,
+		"Line endings of generated Dockerfiles, one of 'lf', 'crlf' or 'native' (line endings of the platform)",
+	)
+	_ = viper.BindPFlag(
+		outEOLFlag,
+		TemplaterCMD.PersistentFlags().Lookup(outEOLFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Duration(
+		outWaitFlag, 0,
+		"How long to wait for another run writing to the output directory to finish (e.g. 30s), "+
+			"fails immediately by default",
+	)
+	_ = viper.BindPFlag(
+		outWaitFlag,
+		TemplaterCMD.PersistentFlags().Lookup(outWaitFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Bool(
+		outRollbackFlag, false,
+		"Restore the files written by a run to their previous state if the run fails",
+	)
+	_ = viper.BindPFlag(
+		outRollbackFlag,
+		TemplaterCMD.PersistentFlags().Lookup(outRollbackFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Bool(
+		outAtomicFlag, false,
+		"Write the files to a temporary directory which replaces the output directory "+
+			"once all files were written",
+	)
+	_ = viper.BindPFlag(
+		outAtomicFlag,
+		TemplaterCMD.PersistentFlags().Lookup(outAtomicFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Bool(
+		outFormatFlag, false,
+		"Normalize the instruction casing, argument spacing and continuation indentation "+
+			"of the generated Dockerfiles",
+	)
+	_ = viper.BindPFlag(
+		outFormatFlag,
+		TemplaterCMD.PersistentFlags().Lookup(outFormatFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		provenanceFileFlag, "",
+		"Path to write an in-toto provenance statement for the generated Dockerfiles to",
+	)
+	_ = viper.BindPFlag(
+		provenanceFileFlag,
+		TemplaterCMD.PersistentFlags().Lookup(provenanceFileFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		provenanceKeyFlag, "",
+		"Path to a PEM encoded ed25519 private key (PKCS #8) to sign the provenance statement with",
+	)
+	_ = viper.BindPFlag(
+		provenanceKeyFlag,
+		TemplaterCMD.PersistentFlags().Lookup(provenanceKeyFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		mergeStrategyFlag, utils.MergeReplaceSlice,
+		"Strategy of all deep merges (defaults, extends, values files and deepMerge). "+
+			"One of "+utils.MergeOverwrite+", "+utils.MergeReplaceSlice+", "+
+			utils.MergeAppendSlice+" or "+utils.MergeUniqueSlice,
+	)
+	_ = viper.BindPFlag(
+		mergeStrategyFlag,
+		TemplaterCMD.PersistentFlags().Lookup(mergeStrategyFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		dataLayoutFlag, dataLayoutFlat,
+		"Layout of the data passed to the Dockerfile template. "+
+			"Either "+dataLayoutFlat+" (flat variant data) or "+dataLayoutNamespaced+
+			" (namespaced under .Variant, .Values, .Env and .Build)",
+	)
+	_ = viper.BindPFlag(
+		dataLayoutFlag,
+		TemplaterCMD.PersistentFlags().Lookup(dataLayoutFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Bool(
+		allowNetworkFlag, false,
+		"Allow templates to access the network with the httpGet and httpHead functions",
+	)
+	_ = viper.BindPFlag(
+		allowNetworkFlag,
+		TemplaterCMD.PersistentFlags().Lookup(allowNetworkFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		caFileFlag, "",
+		"Path to PEM encoded certificates trusted in addition to the system certificates for "+
+			"network requests (network functions, registry lookups), e.g. of a TLS intercepting proxy",
+	)
+	_ = viper.BindPFlag(
+		caFileFlag,
+		TemplaterCMD.PersistentFlags().Lookup(caFileFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		notifyWebhookFlag, "",
+		"Webhook to post a report of the run to when rendering or building succeeds or fails",
+	)
+	_ = viper.BindPFlag(
+		notifyWebhookFlag,
+		TemplaterCMD.PersistentFlags().Lookup(notifyWebhookFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		notifyFormatFlag, notifyFormatGeneric,
+		"Format of the run report, either 'generic' (the report as JSON) or 'slack' (a message "+
+			"for Slack compatible incoming webhooks)",
+	)
+	_ = viper.BindPFlag(
+		notifyFormatFlag,
+		TemplaterCMD.PersistentFlags().Lookup(notifyFormatFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Duration(
+		networkTimeoutFlag, 30*time.Second,
+		"Timeout of each attempt of a network request (network functions, registry lookups)",
+	)
+	_ = viper.BindPFlag(
+		networkTimeoutFlag,
+		TemplaterCMD.PersistentFlags().Lookup(networkTimeoutFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Int(
+		networkRetriesFlag, 3,
+		"How often network requests failing with a network error, rate limit or server error are retried",
+	)
+	_ = viper.BindPFlag(
+		networkRetriesFlag,
+		TemplaterCMD.PersistentFlags().Lookup(networkRetriesFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Duration(
+		networkBackoffFlag, time.Second,
+		"Delay before the first retry of a network request, doubled with each retry",
+	)
+	_ = viper.BindPFlag(
+		networkBackoffFlag,
+		TemplaterCMD.PersistentFlags().Lookup(networkBackoffFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Bool(
+		allowExecFlag, false,
+		"Allow templates to execute commands with the exec function",
+	)
+	_ = viper.BindPFlag(
+		allowExecFlag,
+		TemplaterCMD.PersistentFlags().Lookup(allowExecFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Bool(
+		hermeticFlag, false,
+		"Disable all template functions accessing the environment, the network or commands "+
+			"and files outside the template directories",
+	)
+	_ = viper.BindPFlag(
+		hermeticFlag,
+		TemplaterCMD.PersistentFlags().Lookup(hermeticFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().StringSlice(
+		funcsDisableFlag, make([]string, 0),
+		"Template function groups which are not allowed: "+
+			strings.Join(utils.FuncGroups(), ", "),
+	)
+	_ = viper.BindPFlag(
+		funcsDisableFlag,
+		TemplaterCMD.PersistentFlags().Lookup(funcsDisableFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Bool(
+		baseVerifyFlag, false,
+		"Verify that the base images (FROM) of the rendered Dockerfiles exist in their registries, "+
+			"using the credentials of the docker config",
+	)
+	_ = viper.BindPFlag(
+		baseVerifyFlag,
+		TemplaterCMD.PersistentFlags().Lookup(baseVerifyFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		timeNowFlag, "",
+		"Fix the time of the run (unix timestamp or RFC 3339) used by now and the date functions, "+
+			"defaults to "+sourceDateEpochEnv+" or the current time",
+	)
+	_ = viper.BindPFlag(
+		timeNowFlag,
+		TemplaterCMD.PersistentFlags().Lookup(timeNowFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		timeZoneFlag, "Local",
+		"Time zone the date functions format dates in (e.g. UTC or Europe/Zurich)",
+	)
+	_ = viper.BindPFlag(
+		timeZoneFlag,
+		TemplaterCMD.PersistentFlags().Lookup(timeZoneFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().String(
+		logFileFlag, "",
+		"Write the logs to this file instead of stderr",
+	)
+	_ = viper.BindPFlag(
+		logFileFlag,
+		TemplaterCMD.PersistentFlags().Lookup(logFileFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Int(
+		logSizeFlag, 10,
+		"Size in MiB at which the log file is rotated, 0 disables rotation",
+	)
+	_ = viper.BindPFlag(
+		logSizeFlag,
+		TemplaterCMD.PersistentFlags().Lookup(logSizeFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().Int(
+		logKeepFlag, 3,
+		"Number of rotated log files to keep",
+	)
+	_ = viper.BindPFlag(
+		logKeepFlag,
+		TemplaterCMD.PersistentFlags().Lookup(logKeepFlag),
+	)
+
+	TemplaterCMD.PersistentFlags().StringVarP(
 		&config, "config", "c", "", "Configuration file",
 	)
-	TemplaterCMD.Flags().BoolVarP(
+	TemplaterCMD.PersistentFlags().BoolVarP(
 		&verbose, "verbose", "v", false, "Be more verbose",
 	)
-	TemplaterCMD.Flags().BoolVarP(
+	TemplaterCMD.PersistentFlags().BoolVarP(
 		&debug, "debug", "y", false, "Output processed yml variant files",
 	)
+	TemplaterCMD.PersistentFlags().BoolVar(
+		&trace, "trace", false,
+		"Log each include and tpl invocation with its arguments and output size",
+	)
+	TemplaterCMD.PersistentFlags().BoolVar(
+		&interactive, "interactive", false,
+		"Prompt for missing required values instead of failing",
+	)
+	TemplaterCMD.PersistentFlags().BoolVar(
+		&noColor, "no-color", false,
+		"Disable colored log levels (also disabled by the NO_COLOR environment variable)",
+	)
+	TemplaterCMD.PersistentFlags().BoolVar(
+		&stats, "stats", false,
+		"Report the time spent in each phase (load variants, parse templates, render, write)",
+	)
+	TemplaterCMD.PersistentFlags().StringVar(
+		&pprofAddr, "pprof", "",
+		"Serve the pprof profiling endpoints on this address while running",
+	)
+	_ = TemplaterCMD.PersistentFlags().MarkHidden("pprof")
 	TemplaterCMD.Flags().BoolVarP(
-		&printVersion, "version", "V", false, "Get the templater version",
+		&printVersion, "version", "V", false,
+		"Get the templater version, with --verbose the build information and enabled features as JSON",
 	)
 
+	registerCompletions()
+
 	viper.SetEnvPrefix("DTPL")
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
 }
 
-func run(_ *cobra.Command, _ []string) {
-	templater := &templater{
+// Returns a templater configured with the flags.
+func newTemplater() *templater {
+	return &templater{
 		DockerfileTpl:       viper.GetString(dockerfileTplFlag),
 		DockerfileTplDirs:   viper.GetStringSlice(dockerfileTplDirFlag),
+		DockerfileBaseTpl:   viper.GetString(dockerfileBaseTplFlag),
+		DockerignoreTpl:     viper.GetString(dockerignoreTplFlag),
+		DockerignoreFmt:     viper.GetString(dockerignoreFmtFlag),
 		OutputDir:           viper.GetString(outDirFlag),
-		AdditionalVariables: viper.GetStringMapString(tplAdditionalVarsFlag),
+		OutputEOL:           viper.GetString(outEOLFlag),
+		AdditionalVariables: flagVariables(tplAdditionalVarsFlag),
+		StringVariables:     flagVariables(tplStringVarsFlag),
+		JSONVariables:       jsonVariables(flagVariables(tplJSONVarsFlag)),
+		DataLayout:          viper.GetString(dataLayoutFlag),
+		Snippets:            viper.GetBool(tplSnippetsFlag),
+		Syntax:              viper.GetString(tplSyntaxFlag),
+		MergeRuns:           viper.GetBool(tplMergeRunsFlag),
+		DeclareArgs:         viper.GetBool(tplDeclareArgsFlag),
+		IgnoredArgs:         viper.GetStringSlice(tplIgnoreArgsFlag),
+		Tidy:                viper.GetBool(tplTidyFlag),
+		CommentMarker:       viper.GetString(tplStripCommentsFlag),
+		Format:              viper.GetBool(outFormatFlag),
+		Assertions:          viper.GetStringSlice(tplAssertFlag),
+		PolicyFiles:         viper.GetStringSlice(policyFilesFlag),
+		PolicyQuery:         viper.GetString(policyQueryFlag),
+		ScanConfig:          viper.GetBool(scanConfigFlag),
+		ScanSeverity:        viper.GetString(scanSeverityFlag),
+		ScanReport:          viper.GetString(scanReportFlag),
+		VerifyBaseImages:    viper.GetBool(baseVerifyFlag),
 	}
-	variants := &variants{
+}
+
+// Returns the variants configured with the flags.
+func newVariants() *variants {
+	return &variants{
 		VariantsTplFile: viper.GetString(variantsDefFlag),
 		VariantsCfgFile: viper.GetString(variantsCfgFlag),
+		ValuesFiles:     viper.GetStringSlice(variantsValuesFlag),
+		VariantsKey:     viper.GetString(variantsKeyFlag),
+		SortOrder:       viper.GetString(variantsSortFlag),
+		MergeStrategy:   viper.GetString(mergeStrategyFlag),
+		Environment:     viper.GetString(environmentFlag),
+
+		AdditionalVariables: flagVariables(tplAdditionalVarsFlag),
 	}
+}
 
-	variants.Load()
+// Configures what the template functions are allowed to access.
+func initTemplateFuncs(templater *templater) {
+	// Hermetic renders may only read files from the template directories
+	// and the explicitly allowed roots
+	if viper.GetBool(hermeticFlag) {
+		for _, flag := range []string{allowNetworkFlag, allowExecFlag} {
+			if viper.GetBool(flag) {
+				utils.Error("--%s cannot be used with --%s", flag, hermeticFlag)
+			}
+		}
+		utils.SetHermetic()
+	} else {
+		utils.AddSandboxRoots(".")
+	}
+
+	utils.AddSandboxRoots(filepath.Dir(templater.DockerfileTpl))
+	utils.AddSandboxRoots(templater.DockerfileTplDirs...)
+	utils.AddSandboxRoots(viper.GetStringSlice(tplRootsFlag)...)
+
+	utils.SetTemplateMergeStrategy(viper.GetString(mergeStrategyFlag))
+	utils.DisableFuncGroups(viper.GetStringSlice(funcsDisableFlag)...)
+	utils.SetTemplateTime(buildTime(), templateZone())
+
+	if viper.GetBool(allowNetworkFlag) {
+		utils.AllowNetwork()
+	}
+	if viper.GetBool(allowExecFlag) {
+		utils.AllowExec()
+	}
+}
+
+func run(_ *cobra.Command, _ []string) {
+	if viper.GetBool(variantsStreamFlag) {
+		renderStream()
+		return
+	}
+
+	render()
+}
+
+// Renders the Dockerfiles of all variants and returns the templater and
+// the variants it rendered.
+func render() (*templater, *variants) {
+	templater := newTemplater()
+	variants := newVariants()
+
+	verifyDataLayout(templater.DataLayout)
+	utils.VerifyEOL(templater.OutputEOL)
+	initTemplateFuncs(templater)
+
+	if viper.GetBool(variantsStreamFlag) {
+		utils.Error(
+			"Streaming the variants (--%s) is only supported when rendering the Dockerfiles",
+			variantsStreamFlag,
+		)
+	}
+
+	stop := utils.Measure("load variants")
+	templater.loadVariants(variants)
+	stop()
 
 	if verbose {
 		variants.Debug()
 	}
 
+	stop = utils.Measure("parse templates")
 	templater.Init()
+	stop()
+
+	closeOutput := templater.openOutput()
+
 	templater.Render(variants.Variants)
+	templater.verifyBaseImages()
+	templater.reportScan()
+
+	stop = utils.Measure("write")
+	writeProvenance(templater, variants)
+	templater.writeManifest()
+	closeOutput()
+	stop()
+
+	templater.addToRunReport()
+
+	return templater, variants
 }
 
-func preRun(_ *cobra.Command, _ []string) {
-	if printVersion {
+// Loads the variants, applies the inputs declared by the template and
+// resolves them.
+func (t *templater) loadVariants(variants *variants) {
+	variants.Load()
+	t.Values = variants.Values
+
+	if schema := loadTemplateSchema(t.DockerfileTpl); schema != nil {
+		schema.Validate(variants.Variants)
+	}
+
+	t.Resolve(variants.Variants)
+}
+
+// Loads the flags from the configuration file if one is given.
+func loadConfig() {
+	if config == "" {
+		return
+	}
+
+	utils.Debug(
+		"Loading flags from configuration file '%s'",
+		config,
+	)
+
+	abs, err := filepath.Abs(config)
+	if err != nil {
+		utils.Error(
+			"Could not find config file '%s': %s",
+			config, err,
+		)
+	}
+
+	viper.SetConfigType("yaml")
+	viper.SetConfigFile(abs)
+
+	if err := viper.ReadInConfig(); err != nil {
+		utils.Error(
+			"Failed to read config file '%s': %s",
+			config, err,
+		)
+	}
+}
+
+func preRun(cmd *cobra.Command, _ []string) {
+	// The detailed version includes the features of the configuration
+	if printVersion && !verbose {
 		log.Println(version)
 		os.Exit(0)
 	}
@@ -164,40 +926,70 @@ func preRun(_ *cobra.Command, _ []string) {
 		utils.SetVerbose()
 	}
 
-	if config != "" {
-		utils.Debug(
-			"Loading flags from configuration file '%s'",
-			config,
-		)
+	if trace {
+		utils.SetTrace()
+	}
 
-		abs, err := filepath.Abs(config)
-		if err != nil {
-			utils.Error(
-				"Could not find config file '%s': %s",
-				config, err,
-			)
-		}
+	if interactive {
+		utils.SetInteractive()
+	}
+
+	if noColor {
+		utils.DisableColor()
+	}
 
-		viper.SetConfigType("yaml")
-		viper.SetConfigFile(abs)
+	if stats {
+		utils.EnableStats()
+	}
 
-		if err := viper.ReadInConfig(); err != nil {
-			utils.Error(
-				"Failed to read config file '%s': %s",
-				config, err,
-			)
-		}
+	if pprofAddr != "" {
+		startProfiling(pprofAddr)
+	}
+
+	loadConfig()
+	applyFlavor()
+
+	if printVersion {
+		printVersionInfo()
+		os.Exit(0)
+	}
+
+	if file := viper.GetString(logFileFlag); file != "" {
+		utils.Debug("Writing logs to '%s'", file)
+		utils.SetLogFile(
+			file, int64(viper.GetInt(logSizeFlag))<<20, viper.GetInt(logKeepFlag),
+		)
+	}
+
+	if file := viper.GetString(caFileFlag); file != "" {
+		utils.SetCAFile(file)
 	}
+
+	utils.SetRequestTimeout(viper.GetDuration(networkTimeoutFlag))
+	utils.SetRequestRetries(viper.GetInt(networkRetriesFlag), viper.GetDuration(networkBackoffFlag))
+
+	startRunReport(cmd)
+}
+
+func postRun(_ *cobra.Command, _ []string) {
+	utils.ReportStats()
+	sendRunReport(runSucceeded)
 }
 
 // The actual variant of Dockerfile which will be passed to the template.
 type variant struct {
-	Name  *string `yaml:"name"`
+	Name  *string `yaml:"name,omitempty"`
 	Image *struct {
-		Name *string `yaml:"name"`
-		Tag  *string `yaml:"tag"`
-	} `yaml:"image"`
+		Name *string  `yaml:"name,omitempty"`
+		Tag  *string  `yaml:"tag,omitempty"`
+		Tags []string `yaml:"tags,omitempty"`
+	} `yaml:"image,omitempty"`
 	Data map[string]interface{} `yaml:",inline"`
+
+	// The data passed to the template if it differs from Data.
+	context map[string]interface{}
+	// Whether the image and additional variables were added to the data.
+	resolved bool
 }
 
 // Verifies if the required attributes for each variant are defined and
@@ -233,10 +1025,55 @@ func (v *variant) Verify() {
 	}
 }
 
-// Get the output filename of this variant.
+// The key of the optional output name format of a variant (output.name).
+const outputKey = "output"
+
+// Get the output filename of this variant, it is named with the output
+// name format of the variant or the global one.
 func (v *variant) OutputFile() string {
-	fmt := viper.GetString(outFmtFlag)
+	format := viper.GetString(outFmtFlag)
+
+	if output, ok := v.Data[outputKey].(map[string]interface{}); ok {
+		switch name := output["name"].(type) {
+		case string:
+			format = name
+		case nil:
+		default:
+			utils.Error(
+				"Invalid value '%v' for '%s.name' of variant '%s', must be a string",
+				name, outputKey, *v.Name,
+			)
+		}
+	}
+
+	return v.outputName(format)
+}
+
+// Returns the filename of the variant's .dockerignore, it is named after
+// the Dockerfile (Dockerfile.x.dockerignore, Containerfile.x.containerignore
+// with the containerfile flavor) if no format is given.
+func (v *variant) DockerignoreFile(format string) string {
+	if format == "" {
+		return v.OutputFile() + ignoreFileSuffix()
+	}
+	return v.outputName(format)
+}
+
+// Returns the data output names are rendered with, the variant data with
+// the name and image of the variant. The data does not depend on whether
+// the variant was prepared already, values set by additional variables
+// take precedence.
+func (v *variant) nameData() map[string]interface{} {
+	data := map[string]interface{}{
+		"name":  *v.Name,
+		"image": v.imageData(),
+	}
 
+	return utils.MergeMaps(data, v.Data, utils.MergeReplaceSlice)
+}
+
+// Returns an output filename rendered with the format.
+func (v *variant) outputName(fmt string) string {
 	tpl, err := template.New("OutputFile").Parse(fmt)
 	if err != nil {
 		utils.Error(
@@ -246,14 +1083,21 @@ func (v *variant) OutputFile() string {
 	}
 
 	var filename = bytes.Buffer{}
-	if err := tpl.Execute(&filename, v.Data); err != nil {
+	if err := tpl.Execute(&filename, v.nameData()); err != nil {
 		utils.Error(
 			"Failed to generate output file name: %s",
 			err,
 		)
 	}
 
-	return filename.String()
+	if err := utils.ValidateFileName(filename.String()); err != nil {
+		utils.Error(
+			"Failed to generate output file name for variant '%s': %s",
+			*v.Name, err,
+		)
+	}
+
+	return filepath.FromSlash(filename.String())
 }
 
 // Returns the variant as yml.
@@ -262,7 +1106,16 @@ func (v *variant) String(dataOnly bool) string {
 	var err error
 
 	if !dataOnly {
-		res, err = yaml.Marshal(v)
+		// The data of resolved variants holds the name and image too, they
+		// would conflict with the fields of the inlined map
+		dump := *v
+		dump.Data = make(map[string]interface{}, len(v.Data))
+		for key, val := range v.Data {
+			if key != "name" && key != "image" {
+				dump.Data[key] = val
+			}
+		}
+		res, err = yaml.Marshal(&dump)
 	} else {
 		res, err = yaml.Marshal(v.Data)
 	}
@@ -278,9 +1131,43 @@ func (v *variant) String(dataOnly bool) string {
 	return ""
 }
 
-// Adds the variables to the data passed to the template.
-func (v *variant) UpdateData(variables map[string]string) {
-	for key, val := range variables {
+// Deep merges the defaults into the variant, values defined on the variant
+// itself take precedence over the defaults.
+func (v *variant) ApplyDefaults(
+	defaults map[string]interface{},
+	strategy string,
+) {
+	var data map[string]interface{}
+	utils.ConvertYML(v, &data)
+
+	merged := utils.MergeMaps(utils.CopyMap(defaults), data, strategy)
+
+	*v = variant{}
+	utils.ConvertYML(merged, v)
+}
+
+// Returns the data which will be passed to the template.
+func (v *variant) TemplateData() map[string]interface{} {
+	if v.context != nil {
+		return v.context
+	}
+	return v.Data
+}
+
+// Adds the variables to the data passed to the template in their order.
+// Values are converted with parse (e.g. interpreted as yml) if it is given
+// and kept as strings otherwise.
+func (v *variant) UpdateData(
+	variables []variable,
+	parse func(raw string) interface{},
+) {
+	for _, variable := range variables {
+		key, raw := variable.Key, variable.Value
+
+		var val interface{} = raw
+		if parse != nil {
+			val = parse(raw)
+		}
 
 		// Check if a variant name prefix is specified in the key
 		// if yes, add it only to the variant with the matching name
@@ -296,10 +1183,28 @@ func (v *variant) UpdateData(variables map[string]string) {
 			}
 		}
 
-		elem := v.Data
+		elem := v.TemplateData()
 		keyPath := variantKey[len(variantKey)-1]
+
+		// A key path ending with [] appends the value to a list.
+		appendValue := strings.HasSuffix(keyPath, "[]")
+		keyPath = strings.TrimSuffix(keyPath, "[]")
+
 		keyPathList := strings.Split(keyPath, ".")
 
+		// A null value removes the key from the variant.
+		if val == nil {
+			if utils.DeleteMapElementByPath(elem, keyPathList) {
+				utils.Debug("Removed variable '%s'", keyPath)
+			} else {
+				utils.Debug(
+					"Variable '%s' to remove does not exist in variant '%s'",
+					keyPath, *v.Name,
+				)
+			}
+			continue
+		}
+
 		// If there are multiple keys in the path we need to traverse the
 		// struct and find the one containing the last key.
 		// The algorithm below returns structs only and we want the struct
@@ -311,43 +1216,353 @@ func (v *variant) UpdateData(variables map[string]string) {
 			)
 		}
 
-		if elem == nil {
-			utils.Warn(
-				"Please check the path of the additional variable '%s'."+
-					"The key path '%s' is invalid for variant '%s'",
-				key, keyPath, *v.Name,
-			)
+		if elem == nil {
+			conflictKey, conflictVal := utils.FindNonMapElementByPath(
+				v.TemplateData(), keyPathList[:len(keyPathList)-1],
+			)
+			invalidVariable(
+				"Please check the path of the additional variable '%s'. "+
+					"The key path '%s' is invalid for variant '%s' as '%s' "+
+					"is not a map but has the value '%v'",
+				key, keyPath, *v.Name, conflictKey, conflictVal,
+			)
+			continue
+		}
+
+		lastKey := keyPathList[len(keyPathList)-1]
+
+		if appendValue {
+			curr, exists := elem[lastKey]
+			list, ok := curr.([]interface{})
+			if exists && curr != nil && !ok {
+				invalidVariable(
+					"Cannot append '%v' to '%s' of variant '%s' as it is not a list "+
+						"but has the value '%v'",
+					val, keyPath, *v.Name, curr,
+				)
+				continue
+			}
+
+			if values, ok := val.([]interface{}); ok {
+				list = append(list, values...)
+			} else {
+				list = append(list, val)
+			}
+
+			utils.Debug("Appending '%v' to variable '%s'", val, keyPath)
+			elem[lastKey] = list
+			continue
+		}
+
+		if curr, ok := elem[lastKey]; ok {
+			utils.Warn(
+				"Overriding variant value '%v' of '%s' with '%v'",
+				curr, keyPath, val,
+			)
+		}
+
+		utils.Debug("Adding variable '%s' with value '%v'", keyPath, val)
+		elem[lastKey] = val
+	}
+}
+
+// A variable passed with a flag, the key path may be prefixed with the name
+// of a variant (<variant>:<key>).
+type variable struct {
+	Key   string
+	Value string
+}
+
+// Returns the key value pairs of the variables flag in the order they were
+// given, fails if a pair is invalid. The variables of the environment (JSON)
+// or the config may be a map as well, they are applied in the order of
+// their keys.
+func flagVariables(flag string) []variable {
+	var pairs []string
+	var values map[string]string
+
+	switch val := viper.Get(flag).(type) {
+	case nil:
+		return nil
+	case []string, []interface{}:
+		pairs = viper.GetStringSlice(flag)
+	case string:
+		if strings.HasPrefix(strings.TrimSpace(val), "{") {
+			values = jsonObjectVariables(flag, val)
+		} else {
+			pairs = []string{val}
+		}
+	default:
+		values = viper.GetStringMapString(flag)
+	}
+
+	if values != nil {
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		variables := make([]variable, 0, len(keys))
+		for _, key := range keys {
+			variables = append(variables, variable{Key: key, Value: values[key]})
+		}
+		return variables
+	}
+
+	variables := make([]variable, 0, len(pairs))
+	for _, pair := range pairs {
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			utils.Error(
+				"Invalid variable '%s' of --%s, must be <KEY_PATH>=<VALUE>", pair, flag,
+			)
+		}
+		variables = append(variables, variable{Key: key, Value: val})
+	}
+
+	return variables
+}
+
+// Returns the variables of a JSON object (e.g. from the environment), values
+// which are no strings are kept as JSON, which is valid yml as well.
+func jsonObjectVariables(flag, raw string) map[string]string {
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &object); err != nil {
+		utils.Error("Invalid JSON object of --%s: %s", flag, err)
+	}
+
+	values := make(map[string]string, len(object))
+	for key, val := range object {
+		if str, ok := val.(string); ok {
+			values[key] = str
+			continue
+		}
+		encoded, _ := json.Marshal(val)
+		values[key] = string(encoded)
+	}
+
+	return values
+}
+
+// Returns the JSON variables, fails if a value is invalid.
+func jsonVariables(variables []variable) []variable {
+	for _, variable := range variables {
+		if !json.Valid([]byte(variable.Value)) {
+			utils.Error(
+				"Invalid JSON value of variable '%s': %s", variable.Key, variable.Value,
+			)
+		}
+	}
+
+	return variables
+}
+
+// Parses a JSON value, numbers without fraction become integers like in yml.
+func parseJSONValue(raw string) interface{} {
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+
+	var val interface{}
+	if err := decoder.Decode(&val); err != nil {
+		utils.Error("Invalid JSON value '%s': %s", raw, err)
+	}
+
+	return convertJSONNumbers(val)
+}
+
+// Converts the JSON numbers of a value to int or float64.
+func convertJSONNumbers(val interface{}) interface{} {
+	switch v := val.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i)
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, elem := range v {
+			v[key] = convertJSONNumbers(elem)
+		}
+	case []interface{}:
+		for idx, elem := range v {
+			v[idx] = convertJSONNumbers(elem)
+		}
+	}
+	return val
+}
+
+// Reports an additional variable which cannot be applied to a variant.
+// This is an error in strict mode and a warning otherwise.
+func invalidVariable(message string, v ...any) {
+	if viper.GetBool(tplStrictVarsFlag) {
+		utils.Error(message, v...)
+	}
+	utils.Warn(message+", ignoring it", v...)
+}
+
+// Adds the image object to the Data struct which will be passed to the template.
+func (v *variant) SetDataImage() {
+	if v.Data == nil {
+		v.Data = make(map[string]interface{})
+	}
+
+	v.Data["image"] = v.imageData()
+	v.Data["name"] = *v.Name
+}
+
+// Updates the image of the variant from its data, so additional variables
+// overriding the image are reflected in the image references too.
+func (v *variant) syncImage() {
+	image, ok := v.Data["image"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	prevTag := *v.Image.Tag
+
+	if name, ok := image["name"]; ok && name != nil {
+		val := fmt.Sprint(name)
+		v.Image.Name = &val
+	}
+	if tag, ok := image["tag"]; ok && tag != nil {
+		val := fmt.Sprint(tag)
+		v.Image.Tag = &val
+	}
+	if tags, ok := image["tags"].([]interface{}); ok {
+		v.Image.Tags = make([]string, 0, len(tags))
+		for _, tag := range tags {
+			// The data lists the previous tag first, it is replaced by the
+			// overriding tag
+			if val := fmt.Sprint(tag); val != prevTag || prevTag == *v.Image.Tag {
+				v.Image.Tags = append(v.Image.Tags, val)
+			}
+		}
+	}
+
+	image["tags"] = v.imageData()["tags"]
+}
+
+// Returns the image of the variant as it is passed to the templates.
+func (v *variant) imageData() map[string]interface{} {
+	tags := make([]interface{}, 0, len(v.Image.Tags)+1)
+	for _, tag := range v.ImageTags() {
+		tags = append(tags, tag)
+	}
+
+	return map[string]interface{}{
+		"name": *v.Image.Name,
+		"tag":  *v.Image.Tag,
+		"tags": tags,
+	}
+}
+
+// Returns all tags of the variant's image, the tag followed by the
+// additional tags without duplicates.
+func (v *variant) ImageTags() []string {
+	tags := []string{*v.Image.Tag}
+	seen := map[string]bool{*v.Image.Tag: true}
+
+	for _, tag := range v.Image.Tags {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
 		}
+	}
 
-		lastKey := keyPathList[len(keyPathList)-1]
+	return tags
+}
 
-		if curr, ok := elem[lastKey]; ok {
-			utils.Warn(
-				"Overriding variant value '%s' of '%s' with '%s'",
-				curr, keyPath, val,
-			)
-		}
+// The key of the optional human readable description of a variant.
+const descriptionKey = "description"
 
-		utils.Debug("Adding variable '%s' with value '%s'", keyPath, val)
-		elem[lastKey] = val
+// Returns the description of a variant or an empty string if it has none.
+func (v *variant) Description() string {
+	raw, ok := v.Data[descriptionKey]
+	if !ok || raw == nil {
+		return ""
 	}
+
+	description, ok := raw.(string)
+	if !ok {
+		utils.Error(
+			"Invalid value '%v' for '%s' of variant '%s', must be a string",
+			raw, descriptionKey, *v.Name,
+		)
+	}
+
+	return strings.TrimSpace(description)
 }
 
-// Adds the image object to the Data struct which will be passed to the template.
-func (v *variant) SetDataImage() {
-	v.Data["image"] = map[string]interface{}{
-		"name": *v.Image.Name,
-		"tag":  *v.Image.Tag,
+// Supported orders in which the variants are rendered.
+const (
+	variantsSortFile = "file"
+	variantsSortName = "name"
+)
+
+// Verifies that the sort order is supported and fails if not.
+func verifySortOrder(order string) {
+	if order != variantsSortFile && order != variantsSortName {
+		utils.Error(
+			"Invalid variants sort order '%s', must be one of '%s' or '%s'",
+			order, variantsSortFile, variantsSortName,
+		)
 	}
-	v.Data["name"] = *v.Name
 }
 
 // The container for the variants yml.
 type variants struct {
-	Variants []*variant `yaml:"variants"`
+	Defaults map[string]interface{} `yaml:"defaults"`
+	Variants []*variant             `yaml:"variants"`
 
 	VariantsCfgFile string
 	VariantsTplFile string
+	ValuesFiles     []string
+	VariantsKey     string
+	SortOrder       string
+	MergeStrategy   string
+	Environment     string
+	// The additional variables, passed to jsonnet variants definitions.
+	AdditionalVariables []variable
+
+	// The values the variants template was rendered with.
+	Values map[string]interface{}
+
+	documents []*variantsDocument
+}
+
+// A single document of the variants yml.
+type variantsDocument struct {
+	Defaults map[string]interface{}
+	Variants []*variant
+
+	key string
+}
+
+// Loads the document, the variants list is looked up at the configured key
+// path while the defaults are always read from the document root.
+func (d *variantsDocument) UnmarshalYAML(node *yaml.Node) error {
+	if d.key == "" || d.key == "." {
+		return node.Decode(&d.Variants)
+	}
+
+	var root struct {
+		Defaults map[string]interface{} `yaml:"defaults"`
+	}
+	if err := node.Decode(&root); err != nil {
+		return err
+	}
+	d.Defaults = root.Defaults
+
+	list := utils.GetYMLNodeByPath(node, strings.Split(d.key, "."))
+	if list == nil {
+		utils.Warn(
+			"Variants document does not contain the key '%s'", d.key,
+		)
+		return nil
+	}
+
+	return list.Decode(&d.Variants)
 }
 
 // Verifies if the variants configuration is valid.
@@ -359,6 +1574,71 @@ func (t *variants) Verify() {
 	for _, v := range t.Variants {
 		v.Verify()
 	}
+
+	t.verifyUnique()
+}
+
+// Verifies that no two variants share the same name or image and fails
+// listing all duplicates if they do.
+func (t *variants) verifyUnique() {
+	index := newVariantIndex()
+
+	var duplicates []string
+	for _, v := range t.Variants {
+		duplicates = append(duplicates, index.add(v)...)
+	}
+
+	if len(duplicates) > 0 {
+		utils.Error(
+			"Variants must be unique:\n - %s",
+			strings.Join(duplicates, "\n - "),
+		)
+	}
+}
+
+// Tracks the names and image references of variants to find duplicates.
+type variantIndex struct {
+	names  map[string]int
+	images map[string]int
+	// The names of the added variants by position.
+	added []string
+}
+
+func newVariantIndex() *variantIndex {
+	return &variantIndex{
+		names:  make(map[string]int),
+		images: make(map[string]int),
+	}
+}
+
+// Adds a variant and returns the duplicates it introduces.
+func (i *variantIndex) add(v *variant) []string {
+	var duplicates []string
+
+	idx := len(i.added)
+	i.added = append(i.added, *v.Name)
+
+	if first, ok := i.names[*v.Name]; ok {
+		duplicates = append(duplicates, fmt.Sprintf(
+			"name '%s' is used by variant #%d and #%d",
+			*v.Name, first+1, idx+1,
+		))
+	} else {
+		i.names[*v.Name] = idx
+	}
+
+	for _, image := range v.ImageRefs("") {
+		if first, ok := i.images[image]; ok {
+			duplicates = append(duplicates, fmt.Sprintf(
+				"image '%s' is used by variant '%s' (#%d) and '%s' (#%d)",
+				image, i.added[first], first+1, *v.Name, idx+1,
+			))
+		} else {
+			i.images[image] = idx
+		}
+	}
+
+	return duplicates
 }
 
 // Outputs the processed variants as yml.
@@ -381,6 +1661,11 @@ func (t *variants) Debug() {
 
 // Loads the variants configuration from a templated variants.yml.
 func (t *variants) loadFromTemplate() {
+	utils.LoadYMLDocumentsFromBytes(t.renderTemplate(), t.newDocument)
+}
+
+// Renders the templated variants.yml with the values and the config.
+func (t *variants) renderTemplate() []byte {
 	utils.Debug(
 		"Loading variant config from '%s'", t.VariantsCfgFile,
 	)
@@ -388,14 +1673,80 @@ func (t *variants) loadFromTemplate() {
 		"Variants ('%s') will be treated as template", t.VariantsTplFile,
 	)
 
-	var vc map[string]interface{}
+	vc := t.loadValues()
+
+	if t.VariantsCfgFile != "" {
+		var cfg map[string]interface{}
 
-	utils.LoadYMLFromFile(t.VariantsCfgFile, &vc)
+		if len(t.ValuesFiles) > 0 {
+			utils.Debug(
+				"Variants config ('%s') will be treated as template",
+				t.VariantsCfgFile,
+			)
+			tpl := utils.ParseTemplate(t.VariantsCfgFile)
+			utils.LoadYMLFromBytes(utils.ExecuteTemplate(vc, tpl), &cfg)
+		} else {
+			utils.LoadYMLFromFile(t.VariantsCfgFile, &cfg)
+		}
+
+		vc = utils.MergeMaps(vc, cfg, t.MergeStrategy)
+	}
 
 	tpl := utils.ParseTemplate(t.VariantsTplFile)
 	res := utils.ExecuteTemplate(vc, tpl)
 
-	utils.LoadYMLFromBytes(res, t)
+	t.Values = vc
+
+	return res
+}
+
+// Loads the chain of values files. Each file is treated as template and
+// rendered with the values of all the previous files.
+func (t *variants) loadValues() map[string]interface{} {
+	values := make(map[string]interface{})
+
+	for _, file := range t.ValuesFiles {
+		utils.Debug(
+			"Loading values from template '%s'", file,
+		)
+
+		var pass map[string]interface{}
+
+		tpl := utils.ParseTemplate(file)
+		utils.LoadYMLFromBytes(utils.ExecuteTemplate(values, tpl), &pass)
+
+		values = utils.MergeMaps(values, pass, t.MergeStrategy)
+	}
+
+	return values
+}
+
+// Returns whether the variants.yml is a template, which is the case if a
+// config or values are given.
+func (t *variants) isTemplated() bool {
+	return t.VariantsCfgFile != "" || len(t.ValuesFiles) > 0
+}
+
+// Returns whether the variants definition is evaluated with jsonnet or CUE
+// instead of being loaded as (templated) yml.
+func (t *variants) isEvaluated() bool {
+	return t.isJsonnet() || t.isCUE()
+}
+
+// Returns the yml (or JSON) an evaluated variants definition results in.
+func (t *variants) evaluate() []byte {
+	if t.isTemplated() {
+		utils.Error(
+			"The variants definition '%s' is evaluated and cannot be used with --%s or --%s",
+			t.VariantsTplFile, variantsCfgFlag, variantsValuesFlag,
+		)
+	}
+
+	if t.isCUE() {
+		return t.exportCUE()
+	}
+
+	return t.evaluateJsonnet()
 }
 
 // Loads the variants configuration from a plain variants.yml.
@@ -404,72 +1755,390 @@ func (t *variants) loadFromPlain() {
 		"Loading variants from '%s'", t.VariantsTplFile,
 	)
 
-	utils.LoadYMLFromFile(t.VariantsTplFile, t)
+	utils.LoadYMLDocumentsFromFile(t.VariantsTplFile, t.newDocument)
+}
+
+// Returns a new document for a multi-document variants yml. The variants
+// of each document are concatenated and their defaults merged once the
+// documents are loaded.
+func (t *variants) newDocument() interface{} {
+	doc := &variantsDocument{key: t.VariantsKey}
+	t.documents = append(t.documents, doc)
+	return doc
+}
+
+// Combines the variants and the defaults of all loaded documents.
+func (t *variants) mergeDocuments() {
+	utils.Debug(
+		"Combining %d variants document(s)", len(t.documents),
+	)
+
+	for _, doc := range t.documents {
+		t.Variants = append(t.Variants, doc.Variants...)
+		t.Defaults = utils.MergeMaps(t.Defaults, doc.Defaults, t.MergeStrategy)
+	}
+
+	t.documents = nil
+}
+
+// Merges the defaults into each variant.
+func (t *variants) applyDefaults() {
+	if len(t.Defaults) == 0 {
+		return
+	}
+
+	utils.Debug(
+		"Merging defaults into variants with strategy '%s'", t.MergeStrategy,
+	)
+
+	for _, v := range t.Variants {
+		v.ApplyDefaults(t.Defaults, t.MergeStrategy)
+	}
 }
 
 // Loads the template data from the yml file(s).
 func (t *variants) Load() {
-	if t.VariantsCfgFile == "" {
+	utils.VerifyMergeStrategy(t.MergeStrategy)
+	verifySortOrder(t.SortOrder)
+
+	switch {
+	case t.isEvaluated():
+		utils.LoadYMLDocumentsFromBytes(t.evaluate(), t.newDocument)
+	case !t.isTemplated():
 		t.loadFromPlain()
-	} else {
+	default:
 		t.loadFromTemplate()
 	}
 
+	t.mergeDocuments()
+	t.resolveExtends()
+	t.applyDefaults()
+	t.applyEnvironment()
 	t.Verify()
+	t.sort()
+}
+
+// Sorts the variants according to the sort order, variants keep the order
+// of their definition (across all documents) by default.
+func (t *variants) sort() {
+	if t.SortOrder != variantsSortName {
+		return
+	}
+
+	sort.SliceStable(t.Variants, func(i, j int) bool {
+		return *t.Variants[i].Name < *t.Variants[j].Name
+	})
 }
 
 // templater holds the main logic to render the Dockerfiles to the output directory.
 type templater struct {
 	DockerfileTpl     string
+	DockerfileBaseTpl string
 	DockerfileTplDirs []string
 	OutputDir         string
+	OutputEOL         string
+
+	AdditionalVariables []variable
+	StringVariables     []variable
+	JSONVariables       []variable
+
+	DataLayout string
+	Values     map[string]interface{}
+	Snippets   bool
+
+	DockerignoreTpl string
+	DockerignoreFmt string
+
+	Syntax      string
+	MergeRuns   bool
+	DeclareArgs bool
+	IgnoredArgs []string
+	Tidy        bool
+	Format      bool
+	// Lines starting with the marker are removed, disabled if empty.
+	CommentMarker string
+
+	// The assertions given as flags, see utils.ParseAssertion.
+	Assertions []string
+
+	// The rego policies and the query returning the violations.
+	PolicyFiles []string
+	PolicyQuery string
+
+	// Whether the rendered Dockerfiles are scanned for misconfigurations,
+	// the severity failing the run and the file to write the findings to.
+	ScanConfig   bool
+	ScanSeverity string
+	ScanReport   string
+
+	VerifyBaseImages bool
+
+	template     *template.Template
+	templates    map[string]*template.Template
+	library      *template.Template
+	dockerignore *template.Template
+	// The assertions of the flags and the template front matter.
+	assertions []*utils.Assertion
+
+	// Whether the variants are streamed, nothing is kept per variant then.
+	streaming bool
+	// The number of rendered variants.
+	rendered int
+	// The Dockerfiles written by Render in the order of the variants, not
+	// kept when streaming.
+	outputs []string
+	// All files written by Render.
+	written generatedFiles
+	// Restores the written files if the run fails, nil if disabled.
+	rollback *rollback
+	// The base images of the rendered Dockerfiles by variant name.
+	fromImages map[string][]string
+	// The image references of the resolved variants by name, returned by
+	// the variantImage function. Not kept when streaming.
+	variantImages map[string]string
+	// The variants by the normalized references (see imageKey) of all
+	// their images. Not kept when streaming.
+	variantRefs map[string]string
+	// The base images of the rendered Dockerfiles with the variants using
+	// them, collected if they are verified.
+	baseImages     map[string][]string
+	baseImageOrder []string
+	// The findings of the config scanner per variant.
+	scanResults []scanEntry
+}
+
+// Resolves the variants into their final state: the image is added to the
+// data, the additional variables are applied and the data passed to the
+// template is built. The resolved variants are used for everything derived
+// from them (output names, image references, debug output and manifest).
+func (t *templater) Resolve(variants []*variant) {
+	for _, v := range variants {
+		t.resolve(v)
+	}
+}
+
+// Resolves a single variant, variants are only resolved once.
+func (t *templater) resolve(variant *variant) {
+	if variant.resolved {
+		return
+	}
+	variant.resolved = true
+
+	variant.SetDataImage()
+
+	if t.DataLayout == dataLayoutNamespaced {
+		variant.context = namespacedData(variant, t.Values)
+	}
+
+	variant.UpdateData(t.AdditionalVariables, utils.ParseYMLValue)
+	variant.UpdateData(t.StringVariables, nil)
+	variant.UpdateData(t.JSONVariables, parseJSONValue)
+	variant.syncImage()
 
-	AdditionalVariables map[string]string
+	if !t.streaming {
+		if t.variantImages == nil {
+			t.variantImages = make(map[string]string)
+		}
+		if t.variantRefs == nil {
+			t.variantRefs = make(map[string]string)
+		}
+
+		refs := variant.ImageRefs("")
+		t.variantImages[*variant.Name] = refs[0]
+		for _, ref := range refs {
+			if key := imageKey(ref); key != "" {
+				t.variantRefs[key] = *variant.Name
+			}
+		}
+	}
 
-	template *template.Template
+	if len(t.AdditionalVariables)+len(t.StringVariables)+len(t.JSONVariables) > 0 && debug {
+		utils.Debug("Adjusted variant: \n\n")
+		log.Printf("%s\n", variant.String(true))
+	}
 }
 
 // Renders the Dockerfiles to the output directory.
 func (t *templater) Render(variants []*variant) {
+	progress := utils.NewProgress("Rendering", len(variants))
+	defer progress.Done()
+
 	for _, variant := range variants {
+		progress.Step(*variant.Name)
+		t.renderVariant(variant)
+	}
+}
 
-		variant.SetDataImage()
-		variant.UpdateData(t.AdditionalVariables)
+// Renders the Dockerfile (and .dockerignore) of a resolved variant to the
+// output directory.
+func (t *templater) renderVariant(variant *variant) {
+	stop := utils.Measure("render")
 
-		if len(t.AdditionalVariables) > 0 && debug {
-			utils.Debug("Adjusted variant: \n\n")
-			log.Printf("%s\n", variant.String(true))
-		}
+	dockerfile := t.outputPath(variant.OutputFile())
+	rendered := t.renderDockerfile(variant)
+	t.collectBaseImages(variant, rendered)
+	t.collectFromImages(variant, rendered)
+	t.scanDockerfile(variant, rendered)
 
-		dockerfile := path.Join(
-			t.OutputDir,
-			variant.OutputFile(),
-		)
+	var ignore []byte
+	if t.dockerignore != nil {
+		ignore = utils.ExecuteTemplate(variant.TemplateData(), t.dockerignore)
+	}
+	stop()
 
-		dockerfile, err := filepath.Abs(dockerfile)
-		if err != nil {
-			utils.Error("%s", err)
-		}
+	t.writeOutput(variant, dockerfile, rendered)
+	t.rendered++
+	if !t.streaming {
+		t.outputs = append(t.outputs, dockerfile)
+	}
 
-		rendered := utils.ExecuteTemplate(
-			variant.Data,
-			t.template,
+	if t.dockerignore != nil {
+		t.writeOutput(
+			variant,
+			t.outputPath(variant.DockerignoreFile(t.DockerignoreFmt)),
+			ignore,
 		)
+	}
+}
+
+// Renders and post processes the Dockerfile of a resolved variant.
+func (t *templater) renderDockerfile(variant *variant) []byte {
+	defer t.recoverPanic(variant)
+
+	utils.Trace("Rendering variant '%s'", *variant.Name)
+
+	rendered := t.postProcess(variant, t.executeTemplate(variant))
+	t.checkAssertions(variant, rendered)
+	t.checkPolicies(variant, rendered)
+
+	return rendered
+}
 
-		utils.Info(
-			"Writing to '%s'", dockerfile,
+// Executes the template of a resolved variant without post processing.
+func (t *templater) executeTemplate(variant *variant) []byte {
+	tpl := t.templateFor(variant)
+	tpl.Funcs(template.FuncMap{
+		"autoArgs":     utils.AutoArgs(variant.BuildArgs()),
+		"required":     utils.Required(*variant.Name),
+		"variantImage": utils.VariantImage(t.variantImages),
+	})
+
+	return utils.ExecuteTemplate(variant.TemplateData(), tpl)
+}
+
+// Applies the transformations to a rendered Dockerfile.
+func (t *templater) postProcess(variant *variant, rendered []byte) []byte {
+	if t.CommentMarker != "" {
+		rendered = utils.StripComments(rendered, t.CommentMarker)
+	}
+
+	// Most likely all conditionals of the template were false
+	if len(bytes.TrimSpace(rendered)) == 0 {
+		utils.Error(
+			"Dockerfile of variant '%s' is empty, check the data of the variant "+
+				"and the conditions of the template", *variant.Name,
 		)
+	}
 
-		if err := os.WriteFile(dockerfile, rendered, os.ModePerm); err != nil {
+	if t.Syntax != "" {
+		var err error
+		if rendered, err = utils.EnsureSyntax(rendered, t.Syntax); err != nil {
 			utils.Error(
-				"Could not write Dockerfile to '%s': %s", dockerfile, err,
+				"Dockerfile of variant '%s' %s", *variant.Name, err,
 			)
 		}
 	}
+
+	if t.MergeRuns {
+		rendered = utils.MergeRuns(rendered)
+	}
+
+	if description := variant.Description(); description != "" {
+		rendered = utils.AddHeaderComment(
+			rendered, *variant.Name+": "+description,
+		)
+	}
+
+	for _, warning := range utils.AnalyzeStages(rendered) {
+		utils.Warn(
+			"Dockerfile of variant '%s': %s", *variant.Name, warning,
+		)
+	}
+
+	if t.DeclareArgs {
+		rendered = utils.DeclareArgs(
+			rendered, sortedStringKeys(variant.BuildArgs()), t.IgnoredArgs,
+		)
+	}
+
+	if t.Format {
+		rendered = utils.FormatDockerfile(rendered)
+	}
+
+	if t.Tidy {
+		rendered = utils.TidyDockerfile(rendered)
+	}
+
+	return rendered
+}
+
+// Returns the absolute path of a file in the output directory.
+func (t *templater) outputPath(name string) string {
+	path, err := filepath.Abs(filepath.Join(t.OutputDir, name))
+	if err != nil {
+		utils.Error("%s", err)
+	}
+	return path
+}
+
+// Writes a generated file of a variant with the configured line endings.
+func (t *templater) writeOutput(v *variant, file string, content []byte) {
+	defer utils.Measure("write")()
+
+	content = utils.ConvertLineEndings(content, t.OutputEOL)
+	t.rollback.Track(file)
+
+	utils.Info(
+		"Writing to '%s'", file,
+	)
+
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		utils.Error(
+			"Could not create directory for '%s': %s", file, err,
+		)
+	}
+
+	if err := os.WriteFile(file, content, os.ModePerm); err != nil {
+		utils.Error(
+			"Could not write '%s': %s", file, err,
+		)
+	}
+
+	t.written.Add(generatedFile{
+		Path: file, Variant: *v.Name, Description: v.Description(),
+	})
+}
+
+// Returns the includable template definitions (built-in snippets and the
+// template directories), they are parsed once and shared by all templates.
+func (t *templater) templateLibrary() *template.Template {
+	if t.library != nil {
+		return t.library
+	}
+
+	t.library = utils.NewTemplateSet("library")
+
+	if t.Snippets {
+		utils.ParseSnippets(t.library)
+	}
+
+	t.initTemplateDirs(t.library)
+
+	return t.library
 }
 
 // Loads the includable template definitions.
-func (t *templater) initTemplateDirs() {
+func (t *templater) initTemplateDirs(tpl *template.Template) {
 	for _, dir := range t.DockerfileTplDirs {
 		utils.Debug(
 			"Including templates from '%s'", dir,
@@ -482,8 +2151,7 @@ func (t *templater) initTemplateDirs() {
 
 		glob := filepath.Join(path, "*.tpl")
 
-		t.template, err = t.template.ParseGlob(glob)
-		if err != nil {
+		if _, err = tpl.ParseGlob(glob); err != nil {
 			utils.Error(
 				"Could not parse templates in '%s': %s",
 				dir, err,
@@ -492,10 +2160,111 @@ func (t *templater) initTemplateDirs() {
 	}
 }
 
+// Parses the Dockerfile template. If a base template is given, it is the
+// template which will be executed while the Dockerfile template may
+// override its blocks.
+func (t *templater) parseTemplate(base string) *template.Template {
+	t.verifyTemplateNames(base)
+
+	var tpl *template.Template
+
+	if base == "" {
+		tpl = utils.ParseDockerfileTemplate(t.DockerfileTpl)
+	} else {
+		utils.Debug(
+			"Using base template '%s' for '%s'", base, t.DockerfileTpl,
+		)
+		tpl = utils.ParseDockerfileTemplate(base)
+		utils.ParseTemplateFiles(tpl, t.DockerfileTpl)
+	}
+
+	utils.AddTemplates(tpl, t.templateLibrary())
+	utils.VerifyTemplateReferences(tpl)
+
+	return tpl
+}
+
+// Fails if the Dockerfile template, the base template and the files of the
+// template directories do not have distinct file names (unless they are
+// the same file). Templates are named after their files, so one would
+// silently replace the other.
+func (t *templater) verifyTemplateNames(base string) {
+	files := []string{t.DockerfileTpl}
+	if base != "" {
+		files = append(files, base)
+	}
+
+	names := make(map[string]string)
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			utils.Error("%s", err)
+		}
+
+		name := filepath.Base(abs)
+		if other, ok := names[name]; ok && other != abs {
+			utils.Error(
+				"The templates '%s' and '%s' have the same file name, rename one of them",
+				other, abs,
+			)
+		}
+		names[name] = abs
+	}
+
+	for _, dir := range t.DockerfileTplDirs {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.tpl"))
+		if err != nil {
+			utils.Error(
+				"Could not list templates in '%s': %s", dir, err,
+			)
+		}
+
+		for _, match := range matches {
+			abs, err := filepath.Abs(match)
+			if err != nil {
+				utils.Error("%s", err)
+			}
+
+			if other, ok := names[filepath.Base(abs)]; ok && other != abs {
+				utils.Error(
+					"The template '%s' of the template directory '%s' has the same file name as '%s', "+
+						"rename one of them", abs, dir, other,
+				)
+			}
+			names[filepath.Base(abs)] = abs
+		}
+	}
+}
+
+// Returns the template for a variant, variants may select their own base
+// template with the key base_template.
+func (t *templater) templateFor(v *variant) *template.Template {
+	base, ok := v.Data[baseTemplateKey].(string)
+	if !ok || base == "" {
+		base = t.DockerfileBaseTpl
+	}
+
+	if tpl, ok := t.templates[base]; ok {
+		return tpl
+	}
+
+	tpl := t.parseTemplate(base)
+	t.templates[base] = tpl
+
+	return tpl
+}
+
 // Initializes the main Dockerfile template.
 func (t *templater) initTemplate() {
-	t.template = utils.ParseTemplate(t.DockerfileTpl)
-	t.initTemplateDirs()
+	t.templates = make(map[string]*template.Template)
+	t.template = t.parseTemplate(t.DockerfileBaseTpl)
+	t.templates[t.DockerfileBaseTpl] = t.template
+	t.verifyTemplateVersions()
+	t.initAssertions()
+
+	if t.ScanConfig {
+		utils.VerifySeverity(t.ScanSeverity)
+	}
 }
 
 // Creates the output directory.
@@ -532,4 +2301,8 @@ func (t *templater) ensureOutDir() {
 func (t *templater) Init() {
 	t.initTemplate()
 	t.ensureOutDir()
+
+	if t.DockerignoreTpl != "" {
+		t.dockerignore = utils.ParseTemplate(t.DockerignoreTpl)
+	}
 }
@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Returns a variant decoded from the yml definition.
+func testVariant(t *testing.T, def string) *variant {
+	t.Helper()
+
+	v := &variant{}
+	if err := yaml.Unmarshal([]byte(def), v); err != nil {
+		t.Fatalf("could not decode variant: %s", err)
+	}
+	v.Verify()
+
+	return v
+}
+
+func TestResolveVariant(t *testing.T) {
+	tpl := &templater{
+		AdditionalVariables: []variable{
+			{Key: "app:image.tag", Value: "2.1"},
+			{Key: "app:debug", Value: "true"},
+			{Key: "packages[]", Value: "curl"},
+			{Key: "packages[]", Value: "git"},
+		},
+	}
+
+	v := testVariant(t, `
+name: app
+image:
+  name: acme/app
+  tag: "2.0"
+  tags: [latest]
+base: debian
+`)
+	tpl.resolve(v)
+
+	if got := *v.Image.Tag; got != "2.1" {
+		t.Errorf("image tag = %q, want %q", got, "2.1")
+	}
+	if got := v.Data["name"]; got != "app" {
+		t.Errorf("data name = %v, want %q", got, "app")
+	}
+	if got := v.Data["debug"]; got != true {
+		t.Errorf("data debug = %v, want true", got)
+	}
+	if got, want := v.Data["packages"], []interface{}{"curl", "git"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("data packages = %#v, want %#v", got, want)
+	}
+	if got := tpl.variantImages["app"]; got != "acme/app:2.1" {
+		t.Errorf("variant image = %q, want %q", got, "acme/app:2.1")
+	}
+
+	// Variants are only resolved once
+	tpl.AdditionalVariables = []variable{{Key: "app:image.tag", Value: "3.0"}}
+	tpl.resolve(v)
+	if got := *v.Image.Tag; got != "2.1" {
+		t.Errorf("image tag after second resolve = %q, want %q", got, "2.1")
+	}
+}
+
+func TestFlagVariables(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []variable
+	}{
+		{
+			name:  "unset",
+			value: nil,
+			want:  []variable{},
+		},
+		{
+			name:  "flags in order",
+			value: []string{"b=1", "a[]=x", "a[]=y,z", "c=d=e"},
+			want: []variable{
+				{Key: "b", Value: "1"},
+				{Key: "a[]", Value: "x"},
+				{Key: "a[]", Value: "y,z"},
+				{Key: "c", Value: "d=e"},
+			},
+		},
+		{
+			name:  "environment json",
+			value: `{"dev.verbose": true, "dev.debug": "yes", "packages": ["a", "b"]}`,
+			want: []variable{
+				{Key: "dev.debug", Value: "yes"},
+				{Key: "dev.verbose", Value: "true"},
+				{Key: "packages", Value: `["a","b"]`},
+			},
+		},
+		{
+			name:  "environment pair",
+			value: "image.tag=latest",
+			want:  []variable{{Key: "image.tag", Value: "latest"}},
+		},
+		{
+			name:  "config map",
+			value: map[string]interface{}{"b": 2, "a": "x"},
+			want: []variable{
+				{Key: "a", Value: "x"},
+				{Key: "b", Value: "2"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			viper.Set(tplAdditionalVarsFlag, tc.value)
+			t.Cleanup(func() { viper.Set(tplAdditionalVarsFlag, nil) })
+
+			if got := flagVariables(tplAdditionalVarsFlag); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("flagVariables(%#v) = %#v, want %#v", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestVariantString(t *testing.T) {
+	def := `
+name: app
+image:
+  name: acme/app
+  tag: "2.0"
+base: debian
+`
+
+	tests := []struct {
+		name     string
+		resolve  bool
+		dataOnly bool
+		want     []string
+		// The top-level keys of the dump in order, each key only once.
+		keys []string
+	}{
+		{
+			name: "unresolved",
+			want: []string{"name: app", "name: acme/app", "base: debian"},
+			keys: []string{"name", "image", "base"},
+		},
+		{
+			name:    "resolved",
+			resolve: true,
+			want:    []string{"name: app", "name: acme/app", "base: debian"},
+			keys:    []string{"name", "image", "base"},
+		},
+		{
+			name:     "resolved data only",
+			resolve:  true,
+			dataOnly: true,
+			want:     []string{"name: app", "base: debian", "tags:"},
+			keys:     []string{"base", "image", "name"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			v := testVariant(t, def)
+			if tc.resolve {
+				(&templater{}).resolve(v)
+			}
+
+			got := v.String(tc.dataOnly)
+			if got == "" {
+				t.Fatal("String returned no yml")
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("String(%t) = %q, missing %q", tc.dataOnly, got, want)
+				}
+			}
+
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(got), &doc); err != nil {
+				t.Fatalf("String(%t) = %q is no valid yml: %s", tc.dataOnly, got, err)
+			}
+			var keys []string
+			root := doc.Content[0]
+			for idx := 0; idx < len(root.Content); idx += 2 {
+				keys = append(keys, root.Content[idx].Value)
+			}
+			if !reflect.DeepEqual(keys, tc.keys) {
+				t.Errorf("String(%t) has the keys %v, want %v", tc.dataOnly, keys, tc.keys)
+			}
+
+			// The dump is valid yml decoding to the same variant
+			if !tc.dataOnly {
+				decoded := testVariant(t, got)
+				if *decoded.Name != *v.Name || *decoded.Image.Tag != *v.Image.Tag {
+					t.Errorf("String(false) = %q does not decode to the variant", got)
+				}
+			}
+		})
+	}
+}
+
+// Runs fn in a subprocess of the test and returns its output, fails the
+// test if fn does not exit with an error (utils.Error exits the process).
+func expectExit(t *testing.T, fn func()) string {
+	t.Helper()
+
+	if os.Getenv("DTPL_TEST_EXIT") == t.Name() {
+		fn()
+		os.Exit(0)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^"+t.Name()+"$")
+	cmd.Env = append(os.Environ(), "DTPL_TEST_EXIT="+t.Name())
+	out, err := cmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("expected an exit with an error, got %v: %s", err, out)
+	}
+
+	return string(out)
+}
+
+// Creates the files with the content in dir.
+func writeFiles(t *testing.T, dir string, files ...string) {
+	t.Helper()
+
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("FROM scratch\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerifyTemplateNames(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir,
+		"Dockerfile.tpl", "base/Dockerfile.tpl", "base/base.tpl",
+		"a/install.tpl", "b/install.tpl", "b/other.tpl",
+	)
+	at := func(file string) string { return filepath.Join(dir, file) }
+
+	// Distinct names and the same file listed twice are fine
+	(&templater{
+		DockerfileTpl:     at("Dockerfile.tpl"),
+		DockerfileTplDirs: []string{at("a"), dir},
+	}).verifyTemplateNames(at("base/base.tpl"))
+
+	tests := []struct {
+		name string
+		tpl  *templater
+		base string
+		want string
+	}{
+		{
+			name: "base",
+			tpl:  &templater{DockerfileTpl: at("Dockerfile.tpl")},
+			base: at("base/Dockerfile.tpl"),
+			want: "have the same file name",
+		},
+		{
+			name: "directory",
+			tpl: &templater{
+				DockerfileTpl:     at("Dockerfile.tpl"),
+				DockerfileTplDirs: []string{at("base")},
+			},
+			want: "Dockerfile.tpl",
+		},
+		{
+			name: "directories",
+			tpl: &templater{
+				DockerfileTpl:     at("Dockerfile.tpl"),
+				DockerfileTplDirs: []string{at("a"), at("b")},
+			},
+			want: "install.tpl",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := expectExit(t, func() { tc.tpl.verifyTemplateNames(tc.base) })
+			if !strings.Contains(out, tc.want) {
+				t.Errorf("error %q does not mention %q", out, tc.want)
+			}
+		})
+	}
+}
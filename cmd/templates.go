@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+	"text/template/parse"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+var (
+	templatesCMD = &cobra.Command{
+		Use:   "templates",
+		Short: "List the defined templates",
+		Long: "List all named templates loaded from the base template, the Dockerfile template, " +
+			"the snippet library and the template directories with their source and usage",
+		Args: cobra.NoArgs,
+		Run:  runTemplates,
+	}
+)
+
+func init() {
+	addFormatFlag(templatesCMD)
+
+	TemplaterCMD.AddCommand(templatesCMD)
+}
+
+// A template as listed by the templates command.
+type templateEntry struct {
+	Name   string `json:"name" yaml:"name"`
+	Source string `json:"source" yaml:"source"`
+	Status string `json:"status" yaml:"status"`
+}
+
+// Usage states of a template definition.
+const (
+	templateRoot         = "root"
+	templateReferenced   = "referenced"
+	templateUnreferenced = "unreferenced"
+	templateOverridden   = "overridden"
+)
+
+// Returns the definitions of all templates in the order they are parsed,
+// later definitions override earlier ones with the same name.
+func (t *templater) definitions() []*utils.TemplateDefinition {
+	var defs []*utils.TemplateDefinition
+
+	if t.DockerfileBaseTpl != "" {
+		defs = append(defs, utils.ParseDefinitions(t.DockerfileBaseTpl)...)
+	}
+
+	defs = append(defs, utils.ParseDefinitions(t.DockerfileTpl)...)
+
+	if t.Snippets {
+		defs = append(defs, utils.SnippetDefinitions()...)
+	}
+
+	for _, dir := range t.DockerfileTplDirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*.tpl"))
+		if err != nil {
+			utils.Error(
+				"Could not list templates in '%s': %s", dir, err,
+			)
+		}
+
+		for _, file := range files {
+			defs = append(defs, utils.ParseDefinitions(file)...)
+		}
+	}
+
+	return defs
+}
+
+// Returns the name of the template which is executed.
+func (t *templater) rootTemplate() string {
+	if t.DockerfileBaseTpl != "" {
+		return filepath.Base(t.DockerfileBaseTpl)
+	}
+	return filepath.Base(t.DockerfileTpl)
+}
+
+// Returns the usage state of each definition (by index).
+func templateStates(
+	defs []*utils.TemplateDefinition,
+	root string,
+) []string {
+	active := make(map[string]int, len(defs))
+	for idx, def := range defs {
+		active[def.Name] = idx
+	}
+
+	referenced := make(map[string]bool)
+	for idx, def := range defs {
+		if active[def.Name] != idx {
+			continue
+		}
+		for _, ref := range utils.TemplateReferences(def.Tree) {
+			referenced[ref.Name] = true
+		}
+	}
+
+	states := make([]string, len(defs))
+	for idx, def := range defs {
+		switch {
+		case active[def.Name] != idx:
+			states[idx] = templateOverridden
+		case def.Name == root:
+			states[idx] = templateRoot
+		case referenced[def.Name]:
+			states[idx] = templateReferenced
+		default:
+			states[idx] = templateUnreferenced
+		}
+	}
+
+	return states
+}
+
+// Returns whether a definition is the empty top-level template of a file
+// which only contains definitions.
+func isEmptyFileTemplate(def *utils.TemplateDefinition) bool {
+	return def.TopLevel &&
+		(def.Tree.Root == nil || parse.IsEmptyTree(def.Tree.Root))
+}
+
+func runTemplates(_ *cobra.Command, _ []string) {
+	templater := newTemplater()
+	verifyFormat(outputFormat)
+
+	defs := templater.definitions()
+	states := templateStates(defs, templater.rootTemplate())
+
+	entries := make([]templateEntry, 0, len(defs))
+	for idx, def := range defs {
+		if isEmptyFileTemplate(def) {
+			continue
+		}
+		entries = append(entries, templateEntry{
+			Name: def.Name, Source: def.Source, Status: states[idx],
+		})
+	}
+
+	printFormatted(entries, func(out io.Writer) {
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		defer w.Flush()
+
+		_, _ = w.Write([]byte("NAME\tSOURCE\tSTATUS\n"))
+
+		for _, e := range entries {
+			_, _ = w.Write([]byte(e.Name + "\t" + e.Source + "\t" + e.Status + "\n"))
+		}
+	})
+}
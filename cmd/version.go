@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	rtdebug "runtime/debug"
+
+	"github.com/spf13/viper"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Build information set with ldflags, e.g.
+// -X github.com/bossm8/dockerfile-templater/cmd.commit=<sha>.
+// If not set, they are read from the build info of the binary.
+var (
+	commit  string
+	builtAt string
+)
+
+// The features enabled by the configuration.
+type versionFeatures struct {
+	Network       bool     `json:"network"`
+	Exec          bool     `json:"exec"`
+	Hermetic      bool     `json:"hermetic"`
+	DisabledFuncs []string `json:"disabled_funcs"`
+}
+
+// The detailed version printed with --version --verbose.
+type versionInfo struct {
+	Version   string          `json:"version"`
+	Commit    string          `json:"commit,omitempty"`
+	BuildDate string          `json:"build_date,omitempty"`
+	Modified  bool            `json:"modified,omitempty"`
+	GoVersion string          `json:"go_version"`
+	Platform  string          `json:"platform"`
+	Features  versionFeatures `json:"features"`
+}
+
+// Returns the detailed version, the commit and build date fall back to
+// the version control information embedded by go build (the time of the
+// commit is used as build date).
+func newVersionInfo() versionInfo {
+	info := versionInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: builtAt,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Features: versionFeatures{
+			Network:       viper.GetBool(allowNetworkFlag),
+			Exec:          viper.GetBool(allowExecFlag),
+			Hermetic:      viper.GetBool(hermeticFlag),
+			DisabledFuncs: viper.GetStringSlice(funcsDisableFlag),
+		},
+	}
+
+	if build, ok := rtdebug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	return info
+}
+
+// Prints the detailed version as JSON to stdout.
+func printVersionInfo() {
+	content, err := json.MarshalIndent(newVersionInfo(), "", "  ")
+	if err != nil {
+		utils.Error("Could not encode the version: %s", err)
+	}
+
+	fmt.Fprintln(os.Stdout, string(content))
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bossm8/dockerfile-templater/utils"
+)
+
+// Verifies the version requirements of the templates (e.g.
+// {{/* requires dtpl/apt-cache >= 2 */}}) against the versions declared by
+// the files defining the required templates and fails if one is not met.
+func (t *templater) verifyTemplateVersions() {
+	defs := t.definitions()
+
+	reqs := utils.TemplateRequirements(defs)
+	if len(reqs) == 0 {
+		return
+	}
+
+	versions, err := utils.TemplateVersions(defs)
+	if err != nil {
+		utils.Error("%s", err)
+	}
+
+	// Later definitions override earlier ones with the same name
+	sources := make(map[string]string, len(defs))
+	for _, def := range defs {
+		sources[def.Name] = def.Source
+	}
+
+	var failed []string
+	for _, req := range reqs {
+		source, ok := sources[req.Name]
+		if !ok {
+			failed = append(failed, fmt.Sprintf(
+				"'%s' requires the undefined template '%s'", req.Source, req.Name,
+			))
+			continue
+		}
+
+		version, ok := versions[source]
+		if !ok {
+			failed = append(failed, fmt.Sprintf(
+				"'%s' requires '%s %s' but '%s' declares no version",
+				req.Source, req.Name, req.Constraint, source,
+			))
+			continue
+		}
+
+		satisfied, err := req.Check(version)
+		if err != nil {
+			utils.Error("%s", err)
+		}
+		if !satisfied {
+			failed = append(failed, fmt.Sprintf(
+				"'%s' requires '%s %s' but '%s' has version %s",
+				req.Source, req.Name, req.Constraint, source, version,
+			))
+			continue
+		}
+
+		utils.Trace("'%s' %s satisfies '%s'", req.Name, version, req.Constraint)
+	}
+
+	if len(failed) > 0 {
+		utils.Error("Unsatisfied template version requirements:\n  %s", strings.Join(failed, "\n  "))
+	}
+}
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"path/filepath"
+	"sort"
+	"text/template/parse"
+)
+
+// A named template and the file it is defined in.
+type TemplateDefinition struct {
+	Name   string
+	Source string
+	Tree   *parse.Tree
+
+	// Whether this is the top-level template of the file.
+	TopLevel bool
+}
+
+// Parses the template definitions of a file without executing or
+// resolving its functions. The top-level template is named after the file.
+func ParseDefinitions(file string) []*TemplateDefinition {
+	_, body := SplitFrontMatter(string(readFile(file)))
+	return parseDefinitions(filepath.Base(file), file, body)
+}
+
+// Parses the template definitions of the text.
+func parseDefinitions(
+	name string,
+	source string,
+	text string,
+) []*TemplateDefinition {
+	trees := make(map[string]*parse.Tree)
+
+	tree := parse.New(name)
+	tree.Mode = parse.SkipFuncCheck | parse.ParseComments
+
+	if _, err := tree.Parse(text, "", "", trees); err != nil {
+		Error(
+			"Could not parse template '%s': %s", source, err,
+		)
+	}
+
+	defs := make([]*TemplateDefinition, 0, len(trees))
+	for treeName, t := range trees {
+		defs = append(defs, &TemplateDefinition{
+			Name:     treeName,
+			Source:   source,
+			Tree:     t,
+			TopLevel: treeName == name,
+		})
+	}
+
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Name < defs[j].Name
+	})
+
+	return defs
+}
+
+// Calls fn for the node and all of its descendants.
+func WalkTemplateNodes(node parse.Node, fn func(parse.Node)) {
+	if node == nil {
+		return
+	}
+
+	fn(node)
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			WalkTemplateNodes(child, fn)
+		}
+	case *parse.ActionNode:
+		WalkTemplateNodes(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, decl := range n.Decl {
+			WalkTemplateNodes(decl, fn)
+		}
+		for _, cmd := range n.Cmds {
+			WalkTemplateNodes(cmd, fn)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			WalkTemplateNodes(arg, fn)
+		}
+	case *parse.ChainNode:
+		WalkTemplateNodes(n.Node, fn)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.TemplateNode:
+		WalkTemplateNodes(n.Pipe, fn)
+	}
+}
+
+// Walks the pipeline and lists of a branch (if, range, with).
+func walkBranch(n *parse.BranchNode, fn func(parse.Node)) {
+	WalkTemplateNodes(n.Pipe, fn)
+	WalkTemplateNodes(n.List, fn)
+	WalkTemplateNodes(n.ElseList, fn)
+}
+
+// A reference to a named template by the template action or the include
+// function.
+type TemplateReference struct {
+	Name string
+	Node parse.Node
+}
+
+// Returns the templates referenced by a template with the template action
+// or the include function (with a constant name).
+func TemplateReferences(tree *parse.Tree) []TemplateReference {
+	var refs []TemplateReference
+
+	if tree == nil || tree.Root == nil {
+		return refs
+	}
+
+	WalkTemplateNodes(tree.Root, func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.TemplateNode:
+			refs = append(refs, TemplateReference{Name: n.Name, Node: n})
+		case *parse.CommandNode:
+			if len(n.Args) < 2 {
+				return
+			}
+			ident, ok := n.Args[0].(*parse.IdentifierNode)
+			if !ok || ident.Ident != "include" {
+				return
+			}
+			if name, ok := n.Args[1].(*parse.StringNode); ok {
+				refs = append(refs, TemplateReference{Name: name.Text, Node: n})
+			}
+		}
+	})
+
+	return refs
+}
+
+// Returns the key paths of the data fields a template references, e.g.
+// [image name] for .image.name or $.image.name. Fields are only collected
+// where dot refers to the data passed to the template, which is assumed to
+// be the case at the top of every named template.
+func FieldReferences(tree *parse.Tree) [][]string {
+	var refs [][]string
+
+	if tree == nil || tree.Root == nil {
+		return refs
+	}
+
+	var walk func(node parse.Node, root bool)
+	walk = func(node parse.Node, root bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child, root)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe, root)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd, root)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg, root)
+			}
+		case *parse.ChainNode:
+			walk(n.Node, root)
+		case *parse.FieldNode:
+			if root {
+				refs = append(refs, n.Ident)
+			}
+		case *parse.VariableNode:
+			if len(n.Ident) > 1 && n.Ident[0] == "$" {
+				refs = append(refs, n.Ident[1:])
+			}
+		case *parse.IfNode:
+			walk(n.Pipe, root)
+			walk(n.List, root)
+			walk(n.ElseList, root)
+		case *parse.RangeNode:
+			walk(n.Pipe, root)
+			walk(n.List, false)
+			walk(n.ElseList, root)
+		case *parse.WithNode:
+			walk(n.Pipe, root)
+			walk(n.List, false)
+			walk(n.ElseList, root)
+		case *parse.TemplateNode:
+			walk(n.Pipe, root)
+		}
+	}
+
+	walk(tree.Root, true)
+
+	return refs
+}
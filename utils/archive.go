@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A file of an archive.
+type ArchiveFile struct {
+	// The path in the archive with forward slashes.
+	Name    string
+	Content []byte
+	Mode    os.FileMode
+}
+
+// Writes the files to a gzip compressed tar archive. The modification
+// times are omitted so archives of the same files are identical.
+func WriteArchive(file string, files []ArchiveFile) error {
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	for _, f := range files {
+		if err = tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     f.Name,
+			Mode:     int64(f.Mode.Perm()),
+			Size:     int64(len(f.Content)),
+			ModTime:  time.Unix(0, 0),
+			Format:   tar.FormatPAX,
+		}); err != nil {
+			break
+		}
+		if _, err = tw.Write(f.Content); err != nil {
+			break
+		}
+	}
+
+	for _, closer := range []io.Closer{tw, gz, out} {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// Extracts the regular files of a gzip compressed tar archive to the
+// directory, fails for entries pointing outside of it.
+func ExtractArchive(file string, dir string) error {
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			Debug("Skipping archive entry '%s' which is no regular file", header.Name)
+			continue
+		}
+
+		name := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("the archive entry '%s' points outside of the archive", header.Name)
+		}
+
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+
+		out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, tr)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
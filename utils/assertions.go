@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Supported kinds of assertions on rendered Dockerfiles.
+const (
+	AssertInstruction    = "instruction"
+	AssertNotInstruction = "not_instruction"
+	AssertContains       = "contains"
+	AssertNotContains    = "not_contains"
+	AssertMatches        = "matches"
+	AssertNotMatches     = "not_matches"
+)
+
+// An assertion on a rendered Dockerfile, exactly one of the checks must be
+// set.
+type Assertion struct {
+	// An instruction the Dockerfile must contain, e.g. USER.
+	Instruction string `yaml:"instruction,omitempty"`
+	// An instruction the Dockerfile must not contain, e.g. ADD.
+	NotInstruction string `yaml:"not_instruction,omitempty"`
+	// Text the Dockerfile must contain.
+	Contains string `yaml:"contains,omitempty"`
+	// Text the Dockerfile must not contain, e.g. 'ADD http'.
+	NotContains string `yaml:"not_contains,omitempty"`
+	// A regular expression the Dockerfile must match.
+	Matches string `yaml:"matches,omitempty"`
+	// A regular expression the Dockerfile must not match.
+	NotMatches string `yaml:"not_matches,omitempty"`
+	// Explains the assertion when it fails.
+	Message string `yaml:"message,omitempty"`
+
+	kind  string
+	value string
+	regex *regexp.Regexp
+}
+
+// Parses an assertion given as <kind>=<value>, e.g. 'instruction=USER'.
+func ParseAssertion(raw string) (*Assertion, error) {
+	kind, value, _ := strings.Cut(raw, "=")
+
+	a := &Assertion{}
+	switch kind {
+	case AssertInstruction:
+		a.Instruction = value
+	case AssertNotInstruction:
+		a.NotInstruction = value
+	case AssertContains:
+		a.Contains = value
+	case AssertNotContains:
+		a.NotContains = value
+	case AssertMatches:
+		a.Matches = value
+	case AssertNotMatches:
+		a.NotMatches = value
+	default:
+		return nil, fmt.Errorf(
+			"invalid assertion '%s', must be <kind>=<value> with kind one of %s",
+			raw, strings.Join([]string{
+				AssertInstruction, AssertNotInstruction, AssertContains,
+				AssertNotContains, AssertMatches, AssertNotMatches,
+			}, ", "),
+		)
+	}
+
+	if err := a.Compile(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Verifies that exactly one check is set and compiles regular expressions.
+func (a *Assertion) Compile() error {
+	checks := [][2]string{
+		{AssertInstruction, a.Instruction},
+		{AssertNotInstruction, a.NotInstruction},
+		{AssertContains, a.Contains},
+		{AssertNotContains, a.NotContains},
+		{AssertMatches, a.Matches},
+		{AssertNotMatches, a.NotMatches},
+	}
+
+	a.kind = ""
+	for _, check := range checks {
+		if check[1] == "" {
+			continue
+		}
+		if a.kind != "" {
+			return fmt.Errorf("assertion defines both '%s' and '%s'", a.kind, check[0])
+		}
+		a.kind, a.value = check[0], check[1]
+	}
+
+	switch a.kind {
+	case "":
+		return fmt.Errorf("assertion defines no check")
+	case AssertInstruction, AssertNotInstruction:
+		a.value = strings.ToUpper(a.value)
+	case AssertMatches, AssertNotMatches:
+		regex, err := regexp.Compile(a.value)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression of assertion: %s", err)
+		}
+		a.regex = regex
+	}
+
+	return nil
+}
+
+// Returns a description of the assertion, e.g. 'must contain a USER
+// instruction'.
+func (a *Assertion) String() string {
+	var res string
+	switch a.kind {
+	case AssertInstruction:
+		res = "must contain a " + a.value + " instruction"
+	case AssertNotInstruction:
+		res = "must not contain a " + a.value + " instruction"
+	case AssertContains:
+		res = "must contain '" + a.value + "'"
+	case AssertNotContains:
+		res = "must not contain '" + a.value + "'"
+	case AssertMatches:
+		res = "must match '" + a.value + "'"
+	case AssertNotMatches:
+		res = "must not match '" + a.value + "'"
+	}
+
+	if a.Message != "" {
+		res += " (" + a.Message + ")"
+	}
+
+	return res
+}
+
+// Returns whether the rendered Dockerfile satisfies the assertion.
+func (a *Assertion) Check(content []byte) bool {
+	switch a.kind {
+	case AssertInstruction, AssertNotInstruction:
+		found := false
+		for _, node := range ParseDockerfile(string(content)).Nodes {
+			if node.Instruction == a.value {
+				found = true
+				break
+			}
+		}
+		return found == (a.kind == AssertInstruction)
+	case AssertContains:
+		return strings.Contains(string(content), a.value)
+	case AssertNotContains:
+		return !strings.Contains(string(content), a.value)
+	case AssertMatches:
+		return a.regex.Match(content)
+	case AssertNotMatches:
+		return !a.regex.Match(content)
+	}
+
+	return true
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"os"
+	"strings"
+)
+
+// Returns the images the stages of a Dockerfile are based on, in order and
+// without duplicates. Stages based on other stages and scratch are omitted.
+// Variables are substituted with the args and the defaults of the global
+// ARG declarations, images with unresolved variables are omitted.
+func BaseImages(content []byte, args map[string]string) []string {
+	d := ParseDockerfile(string(content))
+
+	values := make(map[string]string)
+	for _, node := range d.Nodes {
+		if node.Instruction == "FROM" {
+			break
+		}
+		if node.Instruction != "ARG" {
+			continue
+		}
+		for _, operand := range d.Operands(node) {
+			if name, val, ok := strings.Cut(operand, "="); ok {
+				values[name] = strings.Trim(val, `"'`)
+			}
+		}
+	}
+	for name, val := range args {
+		values[name] = val
+	}
+
+	var images []string
+	seen := make(map[string]bool)
+
+	stages := d.Stages()
+	for idx, stage := range stages {
+		if referencedStage(stage.Base, stages, idx) != nil ||
+			strings.EqualFold(stage.Base, "scratch") {
+			continue
+		}
+
+		resolved := true
+		image := os.Expand(stage.Base, func(name string) string {
+			// ${VAR:-default} and ${VAR-default}
+			name, def, hasDef := strings.Cut(name, "-")
+			name = strings.TrimSuffix(name, ":")
+			if val, ok := values[name]; ok && val != "" {
+				return val
+			}
+			if !hasDef {
+				resolved = false
+			}
+			return def
+		})
+
+		if !resolved {
+			Debug("Skipping base image '%s' with unresolved variables", stage.Base)
+			continue
+		}
+
+		if !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+
+	return images
+}
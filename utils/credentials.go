@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// The user name credential helpers return for identity tokens.
+const identityTokenUser = "<token>"
+
+// The registry credentials of the docker config.
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth string `json:"auth"`
+	} `json:"auths"`
+	// The credential helper for all registries, e.g. desktop.
+	CredsStore string `json:"credsStore"`
+	// The credential helpers by registry, e.g. ecr-login.
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// A username and password of a registry.
+type registryCredential struct {
+	user     string
+	password string
+}
+
+// The credentials by registry domain, they are resolved once per run.
+var registryCredentialCache = make(map[string]registryCredential)
+
+// Returns the credentials of the registry from the docker config
+// ($DOCKER_CONFIG/config.json or ~/.docker/config.json), empty if there
+// are none. Like docker, a credential helper configured for the registry
+// (credHelpers) takes precedence over the auths entries, which take
+// precedence over the default credential store (credsStore).
+func registryCredentials(domain string) (string, string) {
+	cred, ok := registryCredentialCache[domain]
+	if !ok {
+		cred = lookupRegistryCredentials(domain)
+		registryCredentialCache[domain] = cred
+	}
+	return cred.user, cred.password
+}
+
+// Reads the docker config, nil if there is none.
+func readDockerConfig() *dockerConfig {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return nil
+	}
+
+	var config dockerConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		Warn("Could not parse the docker config: %s", err)
+		return nil
+	}
+
+	return &config
+}
+
+// Looks up the credentials of the registry in the docker config.
+func lookupRegistryCredentials(domain string) registryCredential {
+	config := readDockerConfig()
+	if config == nil {
+		return registryCredential{}
+	}
+
+	keys := []string{domain, "https://" + domain}
+	if domain == dockerHubDomain {
+		keys = append(keys, dockerHubAuthKey, "index.docker.io")
+	}
+
+	for _, key := range keys {
+		if helper, ok := config.CredHelpers[key]; ok {
+			return helperCredentials(helper, key)
+		}
+	}
+
+	for _, key := range keys {
+		entry, ok := config.Auths[key]
+		if !ok || entry.Auth == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			continue
+		}
+		user, password, _ := strings.Cut(string(decoded), ":")
+		return registryCredential{user, password}
+	}
+
+	if config.CredsStore != "" {
+		// Docker stores the credentials of Docker Hub with its legacy key
+		server := domain
+		if domain == dockerHubDomain {
+			server = dockerHubAuthKey
+		}
+		return helperCredentials(config.CredsStore, server)
+	}
+
+	return registryCredential{}
+}
+
+// Gets the credentials of the server from the credential helper
+// docker-credential-<helper>, empty if it has none.
+func helperCredentials(helper string, server string) registryCredential {
+	bin := "docker-credential-" + helper
+	Debug("Getting the credentials of '%s' from '%s'", server, bin)
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(bin, "get")
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// Helpers report missing credentials on stdout
+		msg := strings.TrimSpace(stdout.String() + stderr.String())
+		if !strings.Contains(strings.ToLower(msg), "credentials not found") {
+			Warn("Credential helper '%s' failed: %s", bin, helperError(err, msg))
+		}
+		return registryCredential{}
+	}
+
+	var cred struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &cred); err != nil {
+		Warn("Could not parse the output of credential helper '%s': %s", bin, err)
+		return registryCredential{}
+	}
+
+	if cred.Username == identityTokenUser {
+		Warn(
+			"Credential helper '%s' returned an identity token for '%s', which is not supported",
+			bin, server,
+		)
+		return registryCredential{}
+	}
+
+	return registryCredential{cred.Username, cred.Secret}
+}
+
+// Returns the error of a credential helper with its output.
+func helperError(err error, output string) error {
+	if output == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %s", err, output)
+}
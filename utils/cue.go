@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Evaluates and validates the CUE file with 'cue export' and returns the
+// resulting yml. The export fails if the values violate the constraints of
+// the file or are not concrete.
+func ExportCUE(file string) ([]byte, error) {
+	args := []string{"export", "--out", "yaml", file}
+	Trace("Executing 'cue %s'", strings.Join(args, " "))
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("cue", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf(
+			"cue failed: %s: %s", err, strings.TrimSpace(stderr.String()),
+		)
+	}
+
+	return stdout.Bytes(), nil
+}
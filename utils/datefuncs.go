@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"time"
+)
+
+var (
+	// The time now returns, the current time is used if not set.
+	templateTime time.Time
+
+	// The time zone dates are formatted in if none is given.
+	templateZone = time.Local
+)
+
+// Fixes the time returned by now and used by the date functions if no date
+// is given, dates are formatted in the zone by default. This keeps the
+// output identical for all variants and renders of a run.
+func SetTemplateTime(t time.Time, zone *time.Location) {
+	templateTime = t
+	templateZone = zone
+}
+
+// Returns the fixed template time or the current time if none is set.
+func templateNow() time.Time {
+	if templateTime.IsZero() {
+		return time.Now().In(templateZone)
+	}
+	return templateTime.In(templateZone)
+}
+
+// Returns the sprig date functions using the fixed template time and zone
+// instead of the current time and the local zone.
+func dateFuncMap() map[string]interface{} {
+	return map[string]interface{}{
+		"now":            templateNow,
+		"date":           formatDate,
+		"dateInZone":     formatDateInZone,
+		"date_in_zone":   formatDateInZone,
+		"htmlDate":       func(date interface{}) string { return formatDate("2006-01-02", date) },
+		"htmlDateInZone": func(date interface{}, zone string) string { return formatDateInZone("2006-01-02", date, zone) },
+		"ago":            dateAgo,
+		"toDate":         toDate,
+	}
+}
+
+// Converts a date function argument to a time, the template time is used
+// for unsupported types (e.g. a missing value).
+func toTime(date interface{}) time.Time {
+	switch d := date.(type) {
+	case time.Time:
+		return d
+	case *time.Time:
+		return *d
+	case int64:
+		return time.Unix(d, 0)
+	case int:
+		return time.Unix(int64(d), 0)
+	case int32:
+		return time.Unix(int64(d), 0)
+	}
+	return templateNow()
+}
+
+// Formats the date in the template zone.
+func formatDate(format string, date interface{}) string {
+	return toTime(date).In(templateZone).Format(format)
+}
+
+// Formats the date in the zone, UTC is used if the zone is invalid.
+func formatDateInZone(format string, date interface{}, zone string) string {
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return toTime(date).In(loc).Format(format)
+}
+
+// Returns the duration between the date and the template time.
+func dateAgo(date interface{}) string {
+	return templateNow().Sub(toTime(date)).Round(time.Second).String()
+}
+
+// Parses a date in the template zone.
+func toDate(format, str string) time.Time {
+	t, _ := time.ParseInLocation(format, str, templateZone)
+	return t
+}
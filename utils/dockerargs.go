@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+)
+
+// Args which are available in all stages without declaration.
+var predefinedArgs = map[string]bool{
+	"HTTP_PROXY": true, "http_proxy": true,
+	"HTTPS_PROXY": true, "https_proxy": true,
+	"FTP_PROXY": true, "ftp_proxy": true,
+	"NO_PROXY": true, "no_proxy": true,
+	"ALL_PROXY": true, "all_proxy": true,
+}
+
+// Instructions in which the builder substitutes variables.
+var substitutingInstructions = map[string]bool{
+	"ADD": true, "ARG": true, "COPY": true, "ENV": true, "EXPOSE": true,
+	"LABEL": true, "STOPSIGNAL": true, "USER": true, "VOLUME": true,
+	"WORKDIR": true,
+}
+
+// Instructions whose variables are expanded by the shell, the args are
+// available as environment variables only if declared in the stage.
+var shellInstructions = map[string]bool{
+	"RUN": true, "CMD": true, "ENTRYPOINT": true,
+}
+
+// Returns the names declared by an ARG or ENV instruction.
+func (d *Dockerfile) declaredNames(node *DockerfileNode) []string {
+	operands := d.Operands(node)
+
+	// legacy form: ENV KEY value
+	if node.Instruction == "ENV" && len(operands) > 0 && !strings.Contains(operands[0], "=") {
+		return operands[:1]
+	}
+
+	var names []string
+	for _, operand := range operands {
+		name, _, _ := strings.Cut(operand, "=")
+		if name != "" && !strings.ContainsAny(name, `"'`) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Returns the sorted keys of a set.
+func sortedSet(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns ARG nodes declaring the names.
+func argNodes(names []string) []*DockerfileNode {
+	nodes := make([]*DockerfileNode, 0, len(names))
+	for _, name := range names {
+		nodes = append(nodes, &DockerfileNode{
+			Instruction: "ARG", Lines: []string{"ARG " + name}, heredocStart: 1,
+		})
+	}
+	return nodes
+}
+
+// Inserts the missing ARG declarations into a rendered Dockerfile:
+//
+//   - Variables used in FROM which are not declared before the first FROM
+//   - Variables used in a stage which are known args (declared before the
+//     first FROM or passed as build args) but not declared in the stage (by
+//     ARG or ENV)
+//
+// Other variables (e.g. PATH or HOME) are left to the environment of the
+// builder and the shell. Variables in ignored are never declared.
+func DeclareArgs(
+	content []byte,
+	buildArgs []string,
+	ignored []string,
+) []byte {
+	d := ParseDockerfile(string(content))
+
+	skip := make(map[string]bool, len(ignored))
+	for _, name := range ignored {
+		skip[name] = true
+	}
+
+	known := make(map[string]bool)
+	for _, name := range buildArgs {
+		known[name] = true
+	}
+
+	globals := make(map[string]bool)
+	missingGlobals := make(map[string]bool)
+	missing := make(map[*DockerfileNode]map[string]bool)
+	env := make(map[string]map[string]bool)
+
+	var stage map[string]bool
+	var from *DockerfileNode
+
+	for _, node := range d.Nodes {
+		switch node.Instruction {
+		case "":
+			continue
+		case "FROM":
+			for _, name := range d.Variables(d.Args(node)) {
+				if !globals[name] && !predefinedArgs[name] && !skip[name] {
+					missingGlobals[name] = true
+					known[name] = true
+				}
+			}
+
+			from = node
+			missing[from] = make(map[string]bool)
+			stage = make(map[string]bool)
+
+			// ENV is inherited from the stage the stage is based on
+			operands := d.Operands(node)
+			if len(operands) > 0 {
+				for name := range env[strings.ToLower(operands[0])] {
+					stage[name] = true
+				}
+			}
+			if len(operands) > 2 && strings.EqualFold(operands[1], "AS") {
+				env[strings.ToLower(operands[2])] = stage
+			}
+			continue
+		}
+
+		if from == nil {
+			if node.Instruction == "ARG" {
+				for _, name := range d.declaredNames(node) {
+					globals[name] = true
+					known[name] = true
+				}
+			}
+			continue
+		}
+
+		if substitutingInstructions[node.Instruction] || shellInstructions[node.Instruction] {
+			for _, name := range d.Variables(d.Args(node)) {
+				if known[name] && !stage[name] && !predefinedArgs[name] && !skip[name] {
+					missing[from][name] = true
+				}
+			}
+		}
+
+		if node.Instruction == "ARG" || node.Instruction == "ENV" {
+			for _, name := range d.declaredNames(node) {
+				stage[name] = true
+			}
+		}
+	}
+
+	var nodes []*DockerfileNode
+	firstFrom := true
+
+	for _, node := range d.Nodes {
+		if node.Instruction == "FROM" && firstFrom {
+			firstFrom = false
+			if len(missingGlobals) > 0 {
+				Debug("Declaring global args %v", sortedSet(missingGlobals))
+				nodes = append(nodes, argNodes(sortedSet(missingGlobals))...)
+			}
+		}
+
+		nodes = append(nodes, node)
+
+		if names := missing[node]; len(names) > 0 {
+			Debug("Declaring args %v in stage '%s'", sortedSet(names), d.Args(node))
+			nodes = append(nodes, argNodes(sortedSet(names))...)
+		}
+	}
+
+	d.Nodes = nodes
+	return []byte(d.String())
+}
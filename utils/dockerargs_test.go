@@ -0,0 +1,101 @@
+package utils
+
+import "testing"
+
+func TestDeclareArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		buildArgs []string
+		ignored   []string
+		want      string
+	}{
+		{
+			name: "global args in stages",
+			content: `ARG BASE=debian
+FROM ${BASE}
+ARG VERSION
+RUN echo ${BASE} ${VERSION}
+COPY app-${BASE} /app
+`,
+			want: `ARG BASE=debian
+FROM ${BASE}
+ARG BASE
+ARG VERSION
+RUN echo ${BASE} ${VERSION}
+COPY app-${BASE} /app
+`,
+		},
+		{
+			name: "undeclared in FROM",
+			content: `FROM debian:${TAG}
+RUN echo $TAG
+`,
+			want: `ARG TAG
+FROM debian:${TAG}
+ARG TAG
+RUN echo $TAG
+`,
+		},
+		{
+			name: "build args",
+			content: `FROM debian
+WORKDIR /src/${APP}
+RUN make ${TARGET}
+`,
+			buildArgs: []string{"APP"},
+			want: `FROM debian
+ARG APP
+WORKDIR /src/${APP}
+RUN make ${TARGET}
+`,
+		},
+		{
+			name: "environment of the builder",
+			content: `FROM debian
+ENV PATH=/opt/bin:$PATH
+WORKDIR $HOME
+COPY . ${HOME}/src
+RUN echo $HOME
+`,
+		},
+		{
+			name: "declared by ENV and inherited",
+			content: `ARG VERSION=1
+FROM debian AS base
+ENV VERSION=${VERSION}
+FROM base
+RUN echo ${VERSION}
+`,
+			want: `ARG VERSION=1
+FROM debian AS base
+ARG VERSION
+ENV VERSION=${VERSION}
+FROM base
+RUN echo ${VERSION}
+`,
+		},
+		{
+			name: "predefined and ignored",
+			content: `ARG SECRET
+FROM debian
+RUN curl -x $HTTP_PROXY $SECRET
+`,
+			ignored: []string{"SECRET"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Nothing is declared if no result is given
+			want := tc.want
+			if want == "" {
+				want = tc.content
+			}
+
+			if got := string(DeclareArgs([]byte(tc.content), tc.buildArgs, tc.ignored)); got != want {
+				t.Errorf("DeclareArgs(%q) = %q, want %q", tc.content, got, want)
+			}
+		})
+	}
+}
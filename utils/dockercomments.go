@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"strings"
+)
+
+// Removes the comment lines starting with the marker (e.g. '#~'), also
+// within continued instructions. Heredoc bodies are left as they are.
+func StripComments(content []byte, marker string) []byte {
+	d := ParseDockerfile(string(content))
+
+	isMarked := func(line string) bool {
+		return strings.HasPrefix(strings.TrimSpace(line), marker)
+	}
+
+	var nodes []*DockerfileNode
+	for _, node := range d.Nodes {
+		if node.Instruction == "" {
+			if !isMarked(node.Lines[0]) {
+				nodes = append(nodes, node)
+			}
+			continue
+		}
+
+		heredocs := node.Heredocs()
+
+		var lines []string
+		for idx, line := range node.Lines[:node.heredocStart] {
+			if idx == 0 || !isMarked(line) {
+				lines = append(lines, line)
+			}
+		}
+
+		node.heredocStart = len(lines)
+		node.Lines = append(lines, heredocs...)
+		nodes = append(nodes, node)
+	}
+	d.Nodes = nodes
+
+	return []byte(d.String())
+}
@@ -0,0 +1,51 @@
+package utils
+
+import "testing"
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		marker  string
+		want    string
+	}{
+		{
+			name:    "comment lines",
+			content: "#~ template docs\n# syntax=docker/dockerfile:1\nFROM debian\n  #~ indented\n# kept\nRUN make\n",
+			marker:  "#~",
+			want:    "# syntax=docker/dockerfile:1\nFROM debian\n# kept\nRUN make\n",
+		},
+		{
+			name:    "continuations",
+			content: "FROM debian\nRUN apt-get update \\\n    #~ the tools\n    # kept\n    && apt-get install -y curl\n",
+			marker:  "#~",
+			want:    "FROM debian\nRUN apt-get update \\\n    # kept\n    && apt-get install -y curl\n",
+		},
+		{
+			name:    "heredoc",
+			content: "FROM debian\nRUN <<EOF\n#~ kept\nmake\nEOF\n#~ removed\n",
+			marker:  "#~",
+			want:    "FROM debian\nRUN <<EOF\n#~ kept\nmake\nEOF\n",
+		},
+		{
+			name:    "other marker",
+			content: "## docs\nFROM debian\n# kept\n#~ kept\n",
+			marker:  "##",
+			want:    "FROM debian\n# kept\n#~ kept\n",
+		},
+		{
+			name:    "instruction",
+			content: "FROM debian\nRUN echo '#~ kept'\n",
+			marker:  "#~",
+			want:    "FROM debian\nRUN echo '#~ kept'\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := string(StripComments([]byte(tc.content), tc.marker)); got != tc.want {
+				t.Errorf("StripComments(%q, %q) = %q, want %q", tc.content, tc.marker, got, tc.want)
+			}
+		})
+	}
+}
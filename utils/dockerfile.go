@@ -0,0 +1,332 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A parsed Dockerfile, it keeps the original lines to allow transforming
+// it without changing the formatting of untouched instructions.
+type Dockerfile struct {
+	// The escape character of the Dockerfile (escape directive).
+	Escape rune
+	Nodes  []*DockerfileNode
+}
+
+// An instruction (including its continuation and heredoc lines) or a
+// comment or blank line.
+type DockerfileNode struct {
+	// The upper case instruction, empty for comments and blank lines.
+	Instruction string
+	Lines       []string
+
+	// The index of the first heredoc line in Lines.
+	heredocStart int
+}
+
+var (
+	// Matches a parser directive, e.g. '# escape=`'.
+	directiveRegex = regexp.MustCompile(`^#\s*([a-zA-Z]+)\s*=\s*(\S+)\s*$`)
+	// Matches a heredoc marker, e.g. <<EOF or <<-"EOF".
+	heredocRegex = regexp.MustCompile(`<<(-?)(["']?)([a-zA-Z_][a-zA-Z0-9_]*)(["']?)`)
+)
+
+// A heredoc opened by an instruction.
+type heredoc struct {
+	word      string
+	stripTabs bool
+}
+
+// Returns whether a line is a comment or blank.
+func isCommentOrBlank(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
+
+// Returns whether the line is continued on the next one.
+func (d *Dockerfile) continues(line string) bool {
+	return strings.HasSuffix(strings.TrimRight(line, " \t\r"), string(d.Escape))
+}
+
+// Parses a Dockerfile into its instructions. Joining the lines of all
+// nodes with newlines yields the original content.
+func ParseDockerfile(content string) *Dockerfile {
+	d := &Dockerfile{Escape: '\\'}
+	lines := strings.Split(content, "\n")
+
+	for _, line := range lines {
+		match := directiveRegex.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil {
+			break
+		}
+		if strings.ToLower(match[1]) == "escape" && len(match[2]) == 1 {
+			d.Escape = rune(match[2][0])
+		}
+	}
+
+	for idx := 0; idx < len(lines); {
+		line := lines[idx]
+		idx++
+
+		if isCommentOrBlank(line) {
+			d.Nodes = append(d.Nodes, &DockerfileNode{Lines: []string{line}, heredocStart: 1})
+			continue
+		}
+
+		node := &DockerfileNode{
+			Instruction: strings.ToUpper(strings.Fields(line)[0]),
+			Lines:       []string{line},
+		}
+		heredocs := heredocMarkers(line)
+
+		for cont := d.continues(line); cont && idx < len(lines); idx++ {
+			line = lines[idx]
+			node.Lines = append(node.Lines, line)
+
+			if isCommentOrBlank(line) {
+				continue
+			}
+
+			heredocs = append(heredocs, heredocMarkers(line)...)
+			cont = d.continues(line)
+		}
+
+		node.heredocStart = len(node.Lines)
+
+		for _, doc := range heredocs {
+			for idx < len(lines) {
+				line = lines[idx]
+				node.Lines = append(node.Lines, line)
+				idx++
+
+				end := strings.TrimRight(line, "\r")
+				if doc.stripTabs {
+					end = strings.TrimLeft(end, "\t")
+				}
+				if end == doc.word {
+					break
+				}
+			}
+		}
+
+		d.Nodes = append(d.Nodes, node)
+	}
+
+	return d
+}
+
+// Returns the heredocs opened on a line.
+func heredocMarkers(line string) []heredoc {
+	var docs []heredoc
+	for _, match := range heredocRegex.FindAllStringSubmatch(line, -1) {
+		if match[2] != match[4] {
+			continue
+		}
+		docs = append(docs, heredoc{word: match[3], stripTabs: match[1] == "-"})
+	}
+	return docs
+}
+
+// Returns the content of the Dockerfile.
+func (d *Dockerfile) String() string {
+	var lines []string
+	for _, node := range d.Nodes {
+		lines = append(lines, node.Lines...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Returns the arguments of an instruction with continuation lines joined,
+// comments within the instruction and heredoc bodies are omitted.
+func (d *Dockerfile) Args(node *DockerfileNode) string {
+	if node.Instruction == "" {
+		return ""
+	}
+
+	var parts []string
+	for idx, line := range node.Lines[:node.heredocStart] {
+		if idx > 0 && isCommentOrBlank(line) {
+			continue
+		}
+
+		line = strings.TrimRight(line, " \t\r")
+		if d.continues(line) {
+			line = strings.TrimSuffix(line, string(d.Escape))
+		}
+		if idx == 0 {
+			line = strings.TrimSpace(line)[len(node.Instruction):]
+		}
+
+		parts = append(parts, strings.TrimSpace(line))
+	}
+
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
+
+// Returns the heredoc lines of an instruction.
+func (node *DockerfileNode) Heredocs() []string {
+	return node.Lines[node.heredocStart:]
+}
+
+// Returns the arguments of an instruction split at whitespace.
+func (d *Dockerfile) Fields(node *DockerfileNode) []string {
+	return strings.Fields(d.Args(node))
+}
+
+// Returns the value of a flag (e.g. --from=build) of an instruction.
+func (d *Dockerfile) Flag(node *DockerfileNode, name string) (string, bool) {
+	for _, field := range d.Fields(node) {
+		if !strings.HasPrefix(field, "--") {
+			break
+		}
+		key, val, _ := strings.Cut(field[2:], "=")
+		if key == name {
+			return val, true
+		}
+	}
+	return "", false
+}
+
+// Returns the arguments of an instruction without its flags.
+func (d *Dockerfile) Operands(node *DockerfileNode) []string {
+	fields := d.Fields(node)
+	for idx, field := range fields {
+		if !strings.HasPrefix(field, "--") {
+			return fields[idx:]
+		}
+	}
+	return nil
+}
+
+// A build stage of a Dockerfile.
+type DockerfileStage struct {
+	// The index of the stage.
+	Index int
+	// The name of the stage (FROM ... AS name), empty if it has none.
+	Name string
+	// The image or stage the stage is based on.
+	Base string
+	// The FROM instruction and the instructions of the stage.
+	From  *DockerfileNode
+	Nodes []*DockerfileNode
+}
+
+// Returns the build stages of the Dockerfile.
+func (d *Dockerfile) Stages() []*DockerfileStage {
+	var stages []*DockerfileStage
+
+	for _, node := range d.Nodes {
+		if node.Instruction == "FROM" {
+			stage := &DockerfileStage{Index: len(stages), From: node}
+
+			operands := d.Operands(node)
+			if len(operands) > 0 {
+				stage.Base = operands[0]
+			}
+			if len(operands) > 2 && strings.EqualFold(operands[1], "AS") {
+				stage.Name = strings.ToLower(operands[2])
+			}
+
+			stages = append(stages, stage)
+			continue
+		}
+
+		if len(stages) > 0 {
+			stage := stages[len(stages)-1]
+			stage.Nodes = append(stage.Nodes, node)
+		}
+	}
+
+	return stages
+}
+
+// Returns the names of the variables referenced in a string ($VAR or
+// ${VAR}), escaped references are ignored.
+func (d *Dockerfile) Variables(s string) []string {
+	var vars []string
+
+	isNameChar := func(c byte, first bool) bool {
+		return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(!first && c >= '0' && c <= '9')
+	}
+
+	for idx := 0; idx < len(s); idx++ {
+		if rune(s[idx]) == d.Escape && idx+1 < len(s) && s[idx+1] == '$' {
+			idx++
+			continue
+		}
+		if s[idx] != '$' || idx+1 >= len(s) {
+			continue
+		}
+
+		start := idx + 1
+		if s[start] == '{' {
+			start++
+		}
+
+		end := start
+		for end < len(s) && isNameChar(s[end], end == start) {
+			end++
+		}
+
+		if end > start {
+			vars = append(vars, s[start:end])
+		}
+	}
+
+	return vars
+}
+
+// Ensures that the Dockerfile starts with the syntax directive. An existing
+// directive is moved to the top if it is preceded by comments or blank
+// lines (which disable it), fails if it declares another syntax.
+func EnsureSyntax(content []byte, syntax string) ([]byte, error) {
+	lines := strings.Split(string(content), "\n")
+	directive := "# syntax=" + syntax
+
+	for idx, line := range lines {
+		if !isCommentOrBlank(line) {
+			break
+		}
+
+		match := directiveRegex.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil || strings.ToLower(match[1]) != "syntax" {
+			continue
+		}
+
+		if match[2] != syntax {
+			return nil, fmt.Errorf(
+				"declares the syntax '%s' instead of '%s'", match[2], syntax,
+			)
+		}
+
+		lines = append(lines[:idx], lines[idx+1:]...)
+		break
+	}
+
+	lines = append([]string{directive}, lines...)
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// Adds a comment to the top of the Dockerfile, after the parser directives
+// which must stay the first lines.
+func AddHeaderComment(content []byte, comment string) []byte {
+	lines := strings.Split(string(content), "\n")
+
+	header := make([]string, 0)
+	for _, line := range strings.Split(strings.TrimRight(comment, "\n"), "\n") {
+		header = append(header, strings.TrimRight("# "+line, " "))
+	}
+
+	pos := 0
+	for pos < len(lines) &&
+		directiveRegex.MatchString(strings.TrimRight(lines[pos], "\r")) {
+		pos++
+	}
+
+	res := append(append([]string{}, lines[:pos]...), header...)
+	res = append(res, lines[pos:]...)
+
+	return []byte(strings.Join(res, "\n"))
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDockerfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		escape   rune
+		args     []string
+		heredocs [][]string
+	}{
+		{
+			name:    "continuations and comments",
+			content: "FROM debian\n\n# install\nRUN apt-get update \\\n    # the tools\n    && apt-get install -y curl\n",
+			escape:  '\\',
+			args:    []string{"debian", "", "apt-get update && apt-get install -y curl"},
+		},
+		{
+			name:    "escape directive",
+			content: "# escape=`\nFROM windows `\n    AS build\nCOPY C:\\app C:\\app\n",
+			escape:  '`',
+			args:    []string{"", "windows AS build", `C:\app C:\app`},
+		},
+		{
+			name:    "heredocs",
+			content: "FROM debian\nRUN <<EOF cat <<-END\nFROM x \\\nEOF\n\tRUN y\n\tEND\nRUN true\n",
+			escape:  '\\',
+			args:    []string{"debian", "<<EOF cat <<-END", "true"},
+			heredocs: [][]string{
+				{}, {"FROM x \\", "EOF", "\tRUN y", "\tEND"}, {},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := ParseDockerfile(tc.content)
+
+			if d.Escape != tc.escape {
+				t.Errorf("escape = %q, want %q", d.Escape, tc.escape)
+			}
+			if got := d.String(); got != tc.content {
+				t.Errorf("String() = %q, want the original %q", got, tc.content)
+			}
+
+			var args []string
+			var heredocs [][]string
+			for _, node := range d.Nodes {
+				if node.Instruction == "" && len(node.Lines) == 1 && node.Lines[0] == "" {
+					continue
+				}
+				args = append(args, d.Args(node))
+				if node.Instruction != "" {
+					heredocs = append(heredocs, node.Heredocs())
+				}
+			}
+
+			if !reflect.DeepEqual(args, tc.args) {
+				t.Errorf("args = %#v, want %#v", args, tc.args)
+			}
+			if tc.heredocs != nil && !reflect.DeepEqual(heredocs, tc.heredocs) {
+				t.Errorf("heredocs = %#v, want %#v", heredocs, tc.heredocs)
+			}
+		})
+	}
+}
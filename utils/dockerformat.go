@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"strings"
+)
+
+// The indentation of continuation lines of formatted instructions.
+const continuationIndent = "    "
+
+// Returns the number of leading spaces and tabs of a line.
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// Returns whether all arguments of an ARG or ENV instruction are key=value
+// pairs without quotes, their spacing may be normalized then.
+func plainAssignments(fields []string) bool {
+	for _, field := range fields {
+		if !strings.Contains(field, "=") || strings.ContainsAny(field, `"'`) {
+			return false
+		}
+	}
+	return len(fields) > 0
+}
+
+// Formats the first line of an instruction: the instruction is upper cased,
+// it is separated from its arguments by a single space and so are the
+// key=value pairs of ARG and ENV as well as the AS of FROM.
+func (d *Dockerfile) formatFirstLine(node *DockerfileNode, line string, continued bool) string {
+	line = strings.TrimSpace(line)
+	if continued {
+		line = strings.TrimSpace(strings.TrimSuffix(line, string(d.Escape)))
+	}
+
+	args := strings.TrimSpace(line[len(node.Instruction):])
+	fields := strings.Fields(args)
+
+	switch node.Instruction {
+	case "ARG", "ENV":
+		if plainAssignments(fields) {
+			args = strings.Join(fields, " ")
+		}
+	case "FROM":
+		for idx, field := range fields {
+			if strings.EqualFold(field, "AS") && idx > 0 && !strings.HasPrefix(fields[idx-1], "--") {
+				fields[idx] = "AS"
+				args = strings.Join(fields, " ")
+				break
+			}
+		}
+	}
+
+	line = node.Instruction
+	if args != "" {
+		line += " " + args
+	}
+	if continued {
+		line += " " + string(d.Escape)
+	}
+
+	return line
+}
+
+// Normalizes the formatting of the instructions of a Dockerfile: the
+// instructions are upper cased and not indented, arguments are separated
+// by a single space, the escape character of continued lines is preceded
+// by a single space and continuation lines are indented by four spaces
+// (keeping their relative indentation). Comments between instructions,
+// exec form arguments and heredoc bodies are left as they are.
+func FormatDockerfile(content []byte) []byte {
+	d := ParseDockerfile(string(content))
+
+	for _, node := range d.Nodes {
+		if node.Instruction == "" {
+			continue
+		}
+
+		lines := node.Lines[:node.heredocStart]
+
+		// The continuation lines are shifted so the least indented one is
+		// indented by four spaces
+		minIndent := -1
+		for _, line := range lines[1:] {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if indent := indentation(line); minIndent < 0 || indent < minIndent {
+				minIndent = indent
+			}
+		}
+
+		for idx, line := range lines {
+			line = strings.TrimRight(line, " \t\r")
+			continued := d.continues(line)
+
+			if idx == 0 {
+				lines[idx] = d.formatFirstLine(node, line, continued)
+				continue
+			}
+
+			if strings.TrimSpace(line) == "" {
+				lines[idx] = ""
+				continue
+			}
+
+			if continued && !isCommentOrBlank(line) {
+				line = strings.TrimRight(strings.TrimSuffix(line, string(d.Escape)), " \t") +
+					" " + string(d.Escape)
+			}
+			lines[idx] = continuationIndent + line[minIndent:]
+		}
+	}
+
+	return []byte(d.String())
+}
@@ -0,0 +1,55 @@
+package utils
+
+import "testing"
+
+func TestFormatDockerfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "formatted",
+			content: "FROM debian AS build\nRUN make \\\n    install\n",
+			want:    "FROM debian AS build\nRUN make \\\n    install\n",
+		},
+		{
+			name:    "instructions",
+			content: "  from   debian  as   build\n\trun  make\ncmd [\"make\",  \"test\"]\n",
+			want:    "FROM debian AS build\nRUN make\nCMD [\"make\",  \"test\"]\n",
+		},
+		{
+			name:    "assignments",
+			content: "FROM debian\nARG  A=1   B=2\nENV C=\"a  b\"   D=1\nENV E  value\n",
+			want:    "FROM debian\nARG A=1 B=2\nENV C=\"a  b\"   D=1\nENV E  value\n",
+		},
+		{
+			name:    "flags of FROM",
+			content: "from --platform=linux/amd64   debian as build\n",
+			want:    "FROM --platform=linux/amd64 debian AS build\n",
+		},
+		{
+			name:    "continuations",
+			content: "FROM debian\nRUN apt-get update\\\n  && apt-get install -y \\\n      curl   \\\n\n  # the tools\n  && true\n",
+			want:    "FROM debian\nRUN apt-get update \\\n    && apt-get install -y \\\n        curl \\\n\n    # the tools\n    && true\n",
+		},
+		{
+			name:    "escape directive",
+			content: "# escape=`\nfrom windows\nrun dir`\n\tC:\\\n",
+			want:    "# escape=`\nFROM windows\nRUN dir `\n    C:\\\n",
+		},
+		{
+			name:    "heredoc and comments",
+			content: "  # build\nfrom debian\nrun  <<EOF\n  make   \n\tmake install\nEOF\n",
+			want:    "  # build\nFROM debian\nRUN <<EOF\n  make   \n\tmake install\nEOF\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := string(FormatDockerfile([]byte(tc.content))); got != tc.want {
+				t.Errorf("FormatDockerfile(%q) = %q, want %q", tc.content, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Returns the template functions emitting Dockerfile instructions from
+// structured data. Map keys and packages are sorted to produce stable output.
+func dockerFuncMap() map[string]interface{} {
+	return map[string]interface{}{
+		"dockerArgs":   dockerArgs,
+		"dockerEnv":    dockerEnv,
+		"dockerLabels": dockerLabels,
+		"copyChown":    copyChown,
+		"aptInstall":   aptInstall,
+		"apkInstall":   apkInstall,
+		"runHeredoc":   runHeredoc,
+		"runJoined":    runJoined,
+		"runBlock":     runBlock,
+		"autoArgs":     AutoArgs(nil),
+	}
+}
+
+// Returns the autoArgs template function which declares the build args
+// (without values, they are passed on build) as ARG instructions.
+func AutoArgs(args map[string]string) func() string {
+	return func() string {
+		keys := make([]string, 0, len(args))
+		for key := range args {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		decls := make([]string, 0, len(keys))
+		for _, key := range keys {
+			decls = append(decls, "ARG "+key)
+		}
+
+		return strings.Join(decls, "\n")
+	}
+}
+
+// Returns a double quoted string safe to use in Dockerfile instructions.
+func dockerQuote(val interface{}) string {
+	s := fmt.Sprint(val)
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// Returns a key which is quoted if it contains characters not allowed in
+// unquoted Dockerfile keys.
+func dockerKey(key string) string {
+	if strings.ContainsAny(key, " \t\"'=\\") {
+		return dockerQuote(key)
+	}
+	return key
+}
+
+// Returns the keys of a map sorted.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Converts a list (e.g. from yml or sprig's list) into strings.
+func toStringList(list interface{}) ([]string, error) {
+	switch l := list.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return l, nil
+	case []interface{}:
+		res := make([]string, len(l))
+		for idx, elem := range l {
+			res[idx] = fmt.Sprint(elem)
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("expected a list but got '%v'", list)
+	}
+}
+
+// Returns the key=value pairs of a map joined into a single instruction
+// with line continuations.
+func dockerKeyValues(instruction string, m map[string]interface{}) string {
+	if len(m) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(m))
+	for _, key := range sortedKeys(m) {
+		pairs = append(pairs, dockerKey(key)+"="+dockerQuote(m[key]))
+	}
+
+	indent := strings.Repeat(" ", len(instruction)+1)
+	return instruction + " " + strings.Join(pairs, " \\\n"+indent)
+}
+
+// Returns an ARG instruction for each key, keys with a null value are
+// declared without default.
+func dockerArgs(m map[string]interface{}) string {
+	args := make([]string, 0, len(m))
+
+	for _, key := range sortedKeys(m) {
+		if m[key] == nil {
+			args = append(args, "ARG "+key)
+		} else {
+			args = append(args, "ARG "+key+"="+dockerQuote(m[key]))
+		}
+	}
+
+	return strings.Join(args, "\n")
+}
+
+// Returns a single ENV instruction setting all keys.
+func dockerEnv(m map[string]interface{}) string {
+	return dockerKeyValues("ENV", m)
+}
+
+// Returns a single LABEL instruction setting all keys.
+func dockerLabels(m map[string]interface{}) string {
+	return dockerKeyValues("LABEL", m)
+}
+
+// Returns a COPY instruction owned by owner for each entry.
+// Entries are either strings ('src dest', whitespace separated) or maps with
+// the keys src (string or list) and dest.
+func copyChown(owner string, entries interface{}) (string, error) {
+	list, ok := entries.([]interface{})
+	if !ok {
+		strs, err := toStringList(entries)
+		if err != nil {
+			return "", err
+		}
+		for _, s := range strs {
+			list = append(list, s)
+		}
+	}
+
+	instructions := make([]string, 0, len(list))
+
+	for _, entry := range list {
+		var paths []string
+
+		switch e := entry.(type) {
+		case string:
+			paths = strings.Fields(e)
+		case map[string]interface{}:
+			src, err := toStringList(e["src"])
+			if err != nil {
+				src = []string{fmt.Sprint(e["src"])}
+			}
+			paths = append(src, fmt.Sprint(e["dest"]))
+		}
+
+		if len(paths) < 2 {
+			return "", fmt.Errorf(
+				"invalid copy entry '%v', requires a source and a destination", entry,
+			)
+		}
+
+		quoted := make([]string, len(paths))
+		for idx, p := range paths {
+			quoted[idx] = dockerQuote(p)
+		}
+
+		instructions = append(instructions, fmt.Sprintf(
+			"COPY --chown=%s [%s]", owner, strings.Join(quoted, ", "),
+		))
+	}
+
+	return strings.Join(instructions, "\n"), nil
+}
+
+// Returns the packages sorted and without duplicates.
+func sortedPackages(packages interface{}) ([]string, error) {
+	list, err := toStringList(packages)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(list))
+	res := make([]string, 0, len(list))
+
+	for _, pkg := range list {
+		if !seen[pkg] {
+			seen[pkg] = true
+			res = append(res, pkg)
+		}
+	}
+
+	sort.Strings(res)
+	return res, nil
+}
+
+// Returns a RUN instruction installing the packages with apt-get.
+func aptInstall(packages interface{}) (string, error) {
+	pkgs, err := sortedPackages(packages)
+	if err != nil || len(pkgs) == 0 {
+		return "", err
+	}
+
+	return "RUN apt-get update \\\n" +
+		"    && apt-get install -y --no-install-recommends \\\n" +
+		"        " + strings.Join(pkgs, " \\\n        ") + " \\\n" +
+		"    && rm -rf /var/lib/apt/lists/*", nil
+}
+
+// Returns a RUN instruction installing the packages with apk.
+func apkInstall(packages interface{}) (string, error) {
+	pkgs, err := sortedPackages(packages)
+	if err != nil || len(pkgs) == 0 {
+		return "", err
+	}
+
+	return "RUN apk add --no-cache \\\n" +
+		"        " + strings.Join(pkgs, " \\\n        "), nil
+}
+
+// Supported styles of RUN instructions.
+const (
+	runStyleHeredoc = "heredoc"
+	runStyleJoined  = "joined"
+)
+
+// Returns a RUN instruction using the BuildKit heredoc syntax, the commands
+// are executed with 'set -e' to fail on the first error like a joined RUN.
+func runHeredoc(commands interface{}) (string, error) {
+	cmds, err := toStringList(commands)
+	if err != nil || len(cmds) == 0 {
+		return "", err
+	}
+
+	return "RUN <<EOF\nset -e\n" + strings.Join(cmds, "\n") + "\nEOF", nil
+}
+
+// Returns a RUN instruction with the commands joined by '&&'.
+func runJoined(commands interface{}) (string, error) {
+	cmds, err := toStringList(commands)
+	if err != nil || len(cmds) == 0 {
+		return "", err
+	}
+
+	return "RUN " + strings.Join(cmds, " \\\n    && "), nil
+}
+
+// Returns a RUN instruction in the given style (heredoc or joined), which
+// allows switching the style per variant.
+func runBlock(style string, commands interface{}) (string, error) {
+	switch style {
+	case runStyleHeredoc:
+		return runHeredoc(commands)
+	case runStyleJoined, "":
+		return runJoined(commands)
+	default:
+		return "", fmt.Errorf(
+			"invalid run style '%s', must be one of '%s' or '%s'",
+			style, runStyleHeredoc, runStyleJoined,
+		)
+	}
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"strings"
+)
+
+// Returns whether a RUN instruction can be merged with others, which is
+// the case for the shell form without flags and heredocs.
+func (d *Dockerfile) mergeableRun(node *DockerfileNode) bool {
+	if node.Instruction != "RUN" || len(node.Heredocs()) > 0 {
+		return false
+	}
+
+	args := d.Args(node)
+	return args != "" && !strings.HasPrefix(args, "--") && !strings.HasPrefix(args, "[")
+}
+
+// Shell builtins which change the state of the shell (e.g. the working
+// directory) for the commands following them.
+var shellStateCommands = map[string]bool{
+	"cd": true, "pushd": true, "popd": true, "export": true, "unset": true,
+	"set": true, "shopt": true, "umask": true, "alias": true, "source": true,
+	".": true,
+}
+
+// Returns whether commands can be appended to the RUN instruction, which is
+// not the case if its commands do not end with a complete command (e.g. with
+// ; or &), end with a comment or change the state of the shell for the
+// appended commands.
+func (d *Dockerfile) appendableRun(node *DockerfileNode) bool {
+	args := d.Args(node)
+	if strings.HasSuffix(args, ";") || strings.HasSuffix(args, "&") ||
+		strings.Contains(args, "#") {
+		return false
+	}
+
+	commands := strings.FieldsFunc(args, func(r rune) bool {
+		return strings.ContainsRune(";&|(){}", r)
+	})
+	for _, command := range commands {
+		for _, word := range strings.Fields(command) {
+			switch word {
+			case "!", "if", "then", "else", "elif", "while", "until", "do":
+				continue
+			}
+			if shellStateCommands[word] {
+				return false
+			}
+			break
+		}
+	}
+
+	return true
+}
+
+// Appends the commands of the RUN instruction next to node, the commands
+// are chained with &&.
+func (d *Dockerfile) appendRun(node *DockerfileNode, next *DockerfileNode) {
+	last := len(node.Lines) - 1
+	node.Lines[last] = strings.TrimRight(node.Lines[last], " \t\r") +
+		" " + string(d.Escape)
+
+	first := strings.TrimSpace(next.Lines[0])[len(next.Instruction):]
+	lines := append(
+		[]string{"    && " + strings.TrimSpace(first)},
+		next.Lines[1:]...,
+	)
+
+	node.Lines = append(node.Lines, lines...)
+	node.heredocStart = len(node.Lines)
+}
+
+// Merges consecutive RUN instructions (separated by blank lines at most)
+// into a single one with the commands chained by &&. Dockerfiles which
+// change the shell are left as they are, as are RUN instructions whose
+// commands cannot be chained (see appendableRun).
+func MergeRuns(content []byte) []byte {
+	d := ParseDockerfile(string(content))
+
+	for _, node := range d.Nodes {
+		if node.Instruction == "SHELL" {
+			Debug("Not merging RUN instructions as the Dockerfile changes the shell")
+			return content
+		}
+	}
+
+	var nodes []*DockerfileNode
+	var run *DockerfileNode
+	var blanks []*DockerfileNode
+
+	for _, node := range d.Nodes {
+		if run != nil && node.Instruction == "" && strings.TrimSpace(node.Lines[0]) == "" {
+			blanks = append(blanks, node)
+			continue
+		}
+
+		if run != nil && d.mergeableRun(node) && d.appendableRun(run) {
+			Debug("Merging '%s' into the previous RUN", d.Args(node))
+			d.appendRun(run, node)
+			blanks = nil
+			continue
+		}
+
+		nodes = append(nodes, blanks...)
+		blanks = nil
+		nodes = append(nodes, node)
+
+		run = nil
+		if d.mergeableRun(node) {
+			run = node
+		}
+	}
+
+	d.Nodes = append(nodes, blanks...)
+	return []byte(d.String())
+}
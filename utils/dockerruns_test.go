@@ -0,0 +1,139 @@
+package utils
+
+import "testing"
+
+func TestMergeRuns(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "consecutive",
+			content: `FROM debian
+RUN apt-get update
+
+RUN apt-get install -y \
+    curl
+COPY . .
+RUN make
+`,
+			want: `FROM debian
+RUN apt-get update \
+    && apt-get install -y \
+    curl
+COPY . .
+RUN make
+`,
+		},
+		{
+			name: "ending with a separator",
+			content: `FROM debian
+RUN apt-get update;
+RUN sleep 10 &
+RUN make
+`,
+		},
+		{
+			name: "changing the directory",
+			content: `FROM debian
+RUN apt-get update
+RUN cd /tmp
+RUN make
+`,
+			want: `FROM debian
+RUN apt-get update \
+    && cd /tmp
+RUN make
+`,
+		},
+		{
+			name: "changing the environment in a list",
+			content: `FROM debian
+RUN true && export PATH=/opt/bin:$PATH
+RUN make
+RUN if true; then set -x; fi
+RUN make
+`,
+			want: `FROM debian
+RUN true && export PATH=/opt/bin:$PATH
+RUN make \
+    && if true; then set -x; fi
+RUN make
+`,
+		},
+		{
+			name: "directory as argument",
+			content: `FROM debian
+RUN make -C . install
+RUN echo cd
+`,
+			want: `FROM debian
+RUN make -C . install \
+    && echo cd
+`,
+		},
+		{
+			name: "ending with a comment",
+			content: `FROM debian
+RUN make # build
+RUN make install
+`,
+		},
+		{
+			name: "comment in a continuation",
+			content: `FROM debian
+RUN apt-get update \
+    # the tools
+    && apt-get install -y curl
+RUN make
+`,
+			want: `FROM debian
+RUN apt-get update \
+    # the tools
+    && apt-get install -y curl \
+    && make
+`,
+		},
+		{
+			name: "heredoc",
+			content: `FROM debian
+RUN apt-get update
+RUN <<EOF
+make
+EOF
+RUN make install
+`,
+		},
+		{
+			name: "exec form and flags",
+			content: `FROM debian
+RUN ["make"]
+RUN --mount=type=cache,target=/root/.cache make
+RUN make install
+`,
+		},
+		{
+			name: "shell",
+			content: `FROM debian
+SHELL ["/bin/bash", "-c"]
+RUN apt-get update
+RUN make
+`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Nothing is merged if no result is given
+			want := tc.want
+			if want == "" {
+				want = tc.content
+			}
+
+			if got := string(MergeRuns([]byte(tc.content))); got != want {
+				t.Errorf("MergeRuns(%q) = %q, want %q", tc.content, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// Returns the stage a reference (FROM, COPY --from or RUN --mount from)
+// points to or nil if it does not reference a stage of the Dockerfile.
+func referencedStage(ref string, stages []*DockerfileStage, before int) *DockerfileStage {
+	if idx, err := strconv.Atoi(ref); err == nil {
+		if idx >= 0 && idx < before {
+			return stages[idx]
+		}
+		return nil
+	}
+
+	for _, stage := range stages[:before] {
+		if stage.Name != "" && stage.Name == strings.ToLower(ref) {
+			return stage
+		}
+	}
+
+	return nil
+}
+
+// Returns whether a reference looks like an image rather than a stage.
+func isImageRef(ref string) bool {
+	return strings.ContainsAny(ref, ":/@") || strings.Contains(ref, "$")
+}
+
+// Returns the references of an instruction to other stages or images.
+func stageRefs(node *parser.Node) []string {
+	var refs []string
+
+	for _, flag := range node.Flags {
+		if from, ok := strings.CutPrefix(flag, "--from="); ok {
+			refs = append(refs, from)
+		}
+
+		mount, ok := strings.CutPrefix(flag, "--mount=")
+		if !ok || !strings.EqualFold(node.Value, "RUN") {
+			continue
+		}
+		for _, opt := range strings.Split(mount, ",") {
+			if from, ok := strings.CutPrefix(opt, "from="); ok {
+				refs = append(refs, from)
+			}
+		}
+	}
+
+	return refs
+}
+
+// Returns the build stages of the Dockerfile parsed by the BuildKit parser
+// and the instructions of each stage.
+func parseStages(content []byte) ([]*DockerfileStage, [][]*parser.Node, error) {
+	res, err := parser.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var stages []*DockerfileStage
+	var nodes [][]*parser.Node
+
+	for _, node := range res.AST.Children {
+		if strings.EqualFold(node.Value, "FROM") {
+			stage := &DockerfileStage{Index: len(stages)}
+
+			var operands []string
+			for next := node.Next; next != nil; next = next.Next {
+				operands = append(operands, next.Value)
+			}
+			if len(operands) > 0 {
+				stage.Base = operands[0]
+			}
+			if len(operands) > 2 && strings.EqualFold(operands[1], "AS") {
+				stage.Name = strings.ToLower(operands[2])
+			}
+
+			stages = append(stages, stage)
+			nodes = append(nodes, nil)
+			continue
+		}
+
+		if len(stages) > 0 {
+			nodes[len(nodes)-1] = append(nodes[len(nodes)-1], node)
+		}
+	}
+
+	return stages, nodes, nil
+}
+
+// Analyzes the stages of a Dockerfile with the BuildKit parser and returns
+// warnings about stages which are never used by a later stage (except the
+// last one) and about references to stages which are not defined.
+func AnalyzeStages(content []byte) []string {
+	stages, nodes, err := parseStages(content)
+	if err != nil {
+		return []string{fmt.Sprintf("the stages cannot be analyzed: %s", err)}
+	}
+
+	var warnings []string
+	used := make(map[*DockerfileStage]bool)
+
+	for idx, stage := range stages {
+		if ref := referencedStage(stage.Base, stages, idx); ref != nil {
+			used[ref] = true
+		}
+
+		for _, node := range nodes[idx] {
+			for _, ref := range stageRefs(node) {
+				if s := referencedStage(ref, stages, idx); s != nil {
+					used[s] = true
+					continue
+				}
+
+				if isImageRef(ref) {
+					continue
+				}
+
+				warnings = append(warnings, fmt.Sprintf(
+					"'%s' in %s of stage %s is not a stage defined before it "+
+						"and will be pulled as image%s",
+					ref, strings.ToUpper(node.Value), stageLabel(stage),
+					didYouMean(ref, stageNames(stages)),
+				))
+			}
+		}
+	}
+
+	for idx, stage := range stages {
+		if idx == len(stages)-1 || used[stage] {
+			continue
+		}
+
+		warnings = append(warnings, fmt.Sprintf(
+			"stage %s is never used by a later stage", stageLabel(stage),
+		))
+	}
+
+	return warnings
+}
+
+// Returns the names of the named stages.
+func stageNames(stages []*DockerfileStage) []string {
+	var names []string
+	for _, stage := range stages {
+		if stage.Name != "" {
+			names = append(names, stage.Name)
+		}
+	}
+	return names
+}
+
+// Returns a description of a stage for messages.
+func stageLabel(stage *DockerfileStage) string {
+	if stage.Name != "" {
+		return fmt.Sprintf("'%s'", stage.Name)
+	}
+	return fmt.Sprintf("#%d (%s)", stage.Index, stage.Base)
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeStages(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name: "used stages",
+			content: `FROM golang AS build
+FROM debian AS tools
+FROM build AS test
+RUN --mount=type=cache,from=tools,target=/tools make test
+FROM scratch
+COPY --from=0 /app /app
+COPY --from=test /report /report
+COPY --from=alpine:3 /bin/sh /bin/sh
+`,
+		},
+		{
+			name: "unused stage",
+			content: `FROM golang AS build
+FROM debian AS unused
+FROM build
+`,
+			want: []string{"stage 'unused' is never used by a later stage"},
+		},
+		{
+			name: "undefined reference",
+			content: `FROM golang AS build
+FROM scratch
+COPY --from=biuld /app /app
+`,
+			want: []string{
+				"'biuld' in COPY of stage #1 (scratch) is not a stage defined before it " +
+					"and will be pulled as image, did you mean 'build'?",
+				"stage 'build' is never used by a later stage",
+			},
+		},
+		{
+			name: "continuations and comments",
+			content: `FROM golang \
+    AS build
+FROM scratch
+COPY \
+    # the binary
+    --from=build \
+    /app /app
+`,
+		},
+		{
+			name:    "escape directive",
+			content: "# escape=`\nFROM golang `\n    AS build\nFROM scratch\nCOPY --from=build `\n    C:\\app C:\\app\n",
+		},
+		{
+			name: "heredoc",
+			content: `FROM golang AS build
+RUN <<EOF
+FROM debian AS heredoc
+COPY --from=undefined /a /a
+EOF
+FROM scratch
+COPY --from=build /app /app
+`,
+		},
+		{
+			name:    "no instructions",
+			content: "# empty\n",
+			want:    []string{"the stages cannot be analyzed: file with no instructions"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := AnalyzeStages([]byte(tc.content)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("AnalyzeStages(%q) = %#v, want %#v", tc.content, got, tc.want)
+			}
+		})
+	}
+}
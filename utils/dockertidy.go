@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"strings"
+)
+
+// Strips trailing whitespace and collapses consecutive blank lines into a
+// single one, blank lines at the start and the end are removed. Heredoc
+// bodies are left as they are since their whitespace may be significant.
+func TidyDockerfile(content []byte) []byte {
+	d := ParseDockerfile(string(content))
+
+	var lines []string
+	blank := false
+
+	for _, node := range d.Nodes {
+		heredocs := node.Heredocs()
+
+		for _, line := range node.Lines[:len(node.Lines)-len(heredocs)] {
+			line = strings.TrimRight(line, " \t\r")
+
+			if line == "" {
+				if blank || len(lines) == 0 {
+					continue
+				}
+				blank = true
+			} else {
+				blank = false
+			}
+
+			lines = append(lines, line)
+		}
+
+		if len(heredocs) > 0 {
+			lines = append(lines, heredocs...)
+			blank = false
+		}
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
@@ -0,0 +1,45 @@
+package utils
+
+import "testing"
+
+func TestTidyDockerfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "tidy",
+			content: "FROM debian\nRUN make\n",
+			want:    "FROM debian\nRUN make\n",
+		},
+		{
+			name:    "trailing whitespace",
+			content: "FROM debian  \nRUN make \\ \t\n    install\r\n",
+			want:    "FROM debian\nRUN make \\\n    install\n",
+		},
+		{
+			name:    "blank lines",
+			content: "\n\nFROM debian\n\n\n  \nRUN make\n\n\n",
+			want:    "FROM debian\n\nRUN make\n",
+		},
+		{
+			name:    "missing final newline",
+			content: "FROM debian",
+			want:    "FROM debian\n",
+		},
+		{
+			name:    "heredoc",
+			content: "FROM debian\nRUN <<EOF  \nmake  \n\n\nmake install\nEOF\n\n\nRUN true\n",
+			want:    "FROM debian\nRUN <<EOF\nmake  \n\n\nmake install\nEOF\n\nRUN true\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := string(TidyDockerfile([]byte(tc.content))); got != tc.want {
+				t.Errorf("TidyDockerfile(%q) = %q, want %q", tc.content, got, tc.want)
+			}
+		})
+	}
+}
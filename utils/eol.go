@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"bytes"
+	"runtime"
+)
+
+// Supported line endings of generated files.
+const (
+	EOLLF     = "lf"
+	EOLCRLF   = "crlf"
+	EOLNative = "native"
+)
+
+// Verifies that the line ending is supported and fails if not.
+func VerifyEOL(eol string) {
+	if eol != EOLLF && eol != EOLCRLF && eol != EOLNative {
+		Error(
+			"Invalid line ending '%s', must be one of '%s', '%s' or '%s'",
+			eol, EOLLF, EOLCRLF, EOLNative,
+		)
+	}
+}
+
+// Converts all line endings of the content to the given style, native
+// uses the line endings of the current platform.
+func ConvertLineEndings(content []byte, eol string) []byte {
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+
+	if eol == EOLNative && runtime.GOOS == "windows" {
+		eol = EOLCRLF
+	}
+
+	if eol == EOLCRLF {
+		content = bytes.ReplaceAll(content, []byte("\n"), []byte("\r\n"))
+	}
+
+	return content
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var (
+	// Whether the templates may execute commands.
+	execAllowed bool
+
+	errExecDisabled = errors.New(
+		"command execution is disabled, enable it with --allow.exec",
+	)
+)
+
+// Allows the exec template function to be used.
+func AllowExec() {
+	execAllowed = true
+}
+
+// Runs a command and returns its stdout, the command fails if it exits
+// with a non-zero status.
+func execCommand(name string, args ...string) (string, error) {
+	if !execAllowed {
+		return "", errExecDisabled
+	}
+
+	Debug("Executing '%s %s'", name, strings.Join(args, " "))
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf(
+			"command '%s' failed: %s: %s",
+			name, err, strings.TrimSpace(stderr.String()),
+		)
+	}
+
+	return stdout.String(), nil
+}
+
+// Runs a command with its output attached to the templater's output and
+// fails if it exits with a non-zero status.
+func RunCommand(name string, args ...string) {
+	Info("Running '%s %s'", name, strings.Join(args, " "))
+
+	suspendProgress()
+	defer resumeProgress()
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		Error(
+			"Command '%s %s' failed: %s",
+			name, strings.Join(args, " "), err,
+		)
+	}
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"strings"
+)
+
+// The delimiter of a front matter block.
+const frontMatterDelimiter = "---"
+
+// Splits a template into its yml front matter (a block delimited by lines
+// containing '---' at the very beginning) and its body. The front matter is
+// replaced by a template comment in the body, which keeps the line numbers
+// of the template intact. The front matter is empty if there is none.
+func SplitFrontMatter(content string) (string, string) {
+	lines := strings.Split(content, "\n")
+
+	if len(lines) == 0 || strings.TrimRight(lines[0], " \t\r") != frontMatterDelimiter {
+		return "", content
+	}
+
+	for idx := 1; idx < len(lines); idx++ {
+		if strings.TrimRight(lines[idx], " \t\r") != frontMatterDelimiter {
+			continue
+		}
+
+		frontMatter := strings.Join(lines[1:idx], "\n")
+		body := "{{- /*" + strings.Repeat("\n", idx) + "*/ -}}\n" +
+			strings.Join(lines[idx+1:], "\n")
+
+		return frontMatter, body
+	}
+
+	return "", content
+}
+
+// Returns the front matter of a template file.
+func FrontMatter(file string) string {
+	frontMatter, _ := SplitFrontMatter(string(readFile(file)))
+	return frontMatter
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Groups of template functions which can be disabled together.
+var funcGroups = map[string][]string{
+	"crypto": {
+		"sha1sum", "sha256sum", "adler32sum", "derivePassword",
+		"genPrivateKey", "buildCustomCert", "genCA", "genSelfSignedCert",
+		"genSignedCert", "encryptAES", "decryptAES",
+	},
+	"date": {
+		"now", "date", "date_in_zone", "dateInZone", "date_modify",
+		"dateModify", "htmlDate", "htmlDateInZone", "ago", "toDate",
+		"unixEpoch",
+	},
+	"network": {"getHostByName", "httpGet", "httpHead"},
+	"os":      {"env", "expandenv", "exec"},
+	"random": {
+		"randAlphaNum", "randAlpha", "randAscii", "randNumeric", "uuidv4",
+		"shuffle",
+	},
+}
+
+// The disabled functions mapped to the group they were disabled with.
+var disabledFuncs = make(map[string]string)
+
+// Returns the names of the function groups.
+func FuncGroups() []string {
+	groups := make([]string, 0, len(funcGroups))
+	for group := range funcGroups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// Disables the functions of the groups, they fail when called.
+// Fails if a group does not exist.
+func DisableFuncGroups(groups ...string) {
+	for _, group := range groups {
+		funcs, ok := funcGroups[group]
+		if !ok {
+			Error(
+				"Invalid function group '%s', must be one of '%s'",
+				group, strings.Join(FuncGroups(), "', '"),
+			)
+		}
+
+		Debug("Disabling the %s template functions", group)
+		for _, name := range funcs {
+			disabledFuncs[name] = group
+		}
+	}
+}
+
+// Returns functions replacing the disabled ones, they fail when called.
+func disabledFuncMap() map[string]interface{} {
+	funcs := make(map[string]interface{}, len(disabledFuncs))
+
+	for name, group := range disabledFuncs {
+		name, group := name, group
+		funcs[name] = func(...interface{}) (string, error) {
+			return "", fmt.Errorf(
+				"%s is disabled, the %s functions are not allowed", name, group,
+			)
+		}
+	}
+
+	return funcs
+}
@@ -0,0 +1,327 @@
+package utils
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// Directories the file functions are allowed to access.
+	sandboxRoots []string
+
+	// The strategy of the deepMerge function.
+	templateMergeStrategy = MergeReplaceSlice
+)
+
+// Sets the strategy the deepMerge template function merges with.
+func SetTemplateMergeStrategy(strategy string) {
+	templateMergeStrategy = strategy
+}
+
+// Returns all functions available in templates: sprig and the custom ones.
+// Disabled functions are replaced with ones which fail when called.
+func templateFuncs() template.FuncMap {
+	funcs := template.FuncMap(sprig.FuncMap())
+	for name, fn := range funcMap() {
+		funcs[name] = fn
+	}
+
+	if hermetic {
+		for name, fn := range hermeticFuncMap() {
+			funcs[name] = fn
+		}
+	}
+
+	for name, fn := range disabledFuncMap() {
+		funcs[name] = fn
+	}
+
+	return funcs
+}
+
+// Returns the custom functions available in all templates.
+func funcMap() template.FuncMap {
+	funcs := template.FuncMap{
+		"toYaml":       toYaml,
+		"readFile":     readSandboxedFile,
+		"glob":         globSandboxed,
+		"includeRaw":   includeRaw,
+		"sha256":       sha256String,
+		"sha256file":   sha256File,
+		"md5file":      md5File,
+		"exec":         execCommand,
+		"required":     Required(""),
+		"deepMerge":    deepMerge,
+		"variantImage": VariantImage(nil),
+	}
+
+	for _, fm := range []map[string]interface{}{
+		semverFuncMap(),
+		dateFuncMap(),
+		networkFuncMap(),
+		dockerFuncMap(),
+	} {
+		for name, fn := range fm {
+			funcs[name] = fn
+		}
+	}
+
+	return funcs
+}
+
+// Returns the functions which render other templates, they are bound to
+// the template set of tpl.
+func includeFuncMap(tpl *template.Template) template.FuncMap {
+	return template.FuncMap{
+		// Renders a named template, unlike the template action the result can
+		// be piped to other functions.
+		"include": func(name string, data interface{}) (string, error) {
+			if tpl.Lookup(name) == nil {
+				return "", undefinedTemplateError(name, tpl)
+			}
+
+			var buf strings.Builder
+			err := tpl.ExecuteTemplate(&buf, name, data)
+			Trace(
+				"include '%s' with %s produced %d bytes",
+				name, traceArg(data), buf.Len(),
+			)
+			return buf.String(), err
+		},
+		// Renders a string as template.
+		"tpl": func(text string, data interface{}) (string, error) {
+			clone, err := tpl.Clone()
+			if err != nil {
+				return "", err
+			}
+
+			t, err := clone.New("tpl").Parse(text)
+			if err != nil {
+				return "", err
+			}
+
+			var buf strings.Builder
+			err = t.Execute(&buf, data)
+			Trace(
+				"tpl %s with %s produced %d bytes",
+				traceArg(text), traceArg(data), buf.Len(),
+			)
+			return buf.String(), err
+		},
+	}
+}
+
+// Returns a short representation of a template function argument.
+func traceArg(arg interface{}) string {
+	const maxLen = 80
+
+	if p, ok := arg.(*map[string]interface{}); ok && p != nil {
+		arg = *p
+	}
+
+	s := fmt.Sprintf("%#v", arg)
+	if m, ok := arg.(map[string]interface{}); ok {
+		s = fmt.Sprintf("map with keys %v", sortedKeys(m))
+	}
+
+	if len(s) > maxLen {
+		s = s[:maxLen] + "..."
+	}
+
+	return s
+}
+
+// Adds directories the file functions (readFile, glob) are allowed to
+// access, paths outside of these directories are refused.
+func AddSandboxRoots(roots ...string) {
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			Error("%s", err)
+		}
+
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = resolved
+		}
+
+		Debug("Allowing template file access to '%s'", abs)
+		sandboxRoots = append(sandboxRoots, abs)
+	}
+}
+
+// Returns the absolute path of a file if it is inside one of the sandbox
+// roots, fails otherwise.
+func sandboxPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", err
+	}
+
+	for _, root := range sandboxRoots {
+		rel, err := filepath.Rel(root, resolved)
+		if err == nil && rel != ".." &&
+			!strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return abs, nil
+		}
+	}
+
+	return "", fmt.Errorf(
+		"access to '%s' denied, it is not inside the allowed directories", path,
+	)
+}
+
+// Returns a deep merge of the maps with the configured merge strategy,
+// values of later maps take precedence. The maps are not modified.
+func deepMerge(maps ...map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{})
+	for _, m := range maps {
+		res = MergeMaps(res, m, templateMergeStrategy)
+	}
+	return res
+}
+
+// Returns the variantImage template function which returns the image
+// reference of another variant, images maps the names of the variants to
+// their image reference. It fails without images (when streaming).
+func VariantImage(images map[string]string) func(string) (string, error) {
+	return func(name string) (string, error) {
+		if images == nil {
+			return "", fmt.Errorf("the images of other variants are not available when streaming")
+		}
+		if ref, ok := images[name]; ok {
+			return ref, nil
+		}
+
+		names := make([]string, 0, len(images))
+		for n := range images {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		return "", fmt.Errorf("unknown variant '%s'%s", name, didYouMean(name, names))
+	}
+}
+
+// https://github.com/technosophos/k8s-helm/commit/431cc46cad3ae5248e32df1f6c44f2f4ce5547ba
+func toYaml(v interface{}) string {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Returns the content of a file inside the sandbox.
+func readSandboxedFile(path string) (string, error) {
+	abs, err := sandboxPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(abs)
+	if err != nil {
+		return "", err
+	}
+
+	recordFile(abs)
+
+	return string(content), nil
+}
+
+// Returns the sorted paths matching the pattern, paths outside of the
+// sandbox are omitted. Patterns and paths use forward slashes on all
+// platforms.
+func globSandboxed(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.FromSlash(pattern))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if _, err := sandboxPath(match); err != nil {
+			Debug("Omitting glob match: %s", err)
+			continue
+		}
+		if abs, err := filepath.Abs(match); err == nil {
+			recordFile(abs)
+		}
+		res = append(res, filepath.ToSlash(match))
+	}
+
+	sort.Strings(res)
+	return res, nil
+}
+
+// Returns the content of a file without processing it as template.
+// Relative paths are looked up in all sandbox roots (working directory and
+// template directories), the first existing file is used.
+func includeRaw(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return readSandboxedFile(path)
+	}
+
+	for _, root := range sandboxRoots {
+		candidate := filepath.Join(root, filepath.FromSlash(path))
+		if _, err := os.Stat(candidate); err == nil {
+			return readSandboxedFile(candidate)
+		}
+	}
+
+	return "", fmt.Errorf(
+		"file '%s' not found in any of the template directories", path,
+	)
+}
+
+// Returns the hex encoded sha256 digest of a string.
+func sha256String(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns the hex encoded digest of a file inside the sandbox.
+func fileDigest(path string, h hash.Hash) (string, error) {
+	abs, err := sandboxPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(abs)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Returns the hex encoded sha256 digest of a file.
+func sha256File(path string) (string, error) {
+	return fileDigest(path, sha256.New())
+}
+
+// Returns the hex encoded md5 digest of a file.
+func md5File(path string) (string, error) {
+	return fileDigest(path, md5.New())
+}
@@ -2,33 +2,25 @@ package utils
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"text/template"
 
-	"github.com/Masterminds/sprig"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
 
-// https://github.com/technosophos/k8s-helm/commit/431cc46cad3ae5248e32df1f6c44f2f4ce5547ba
-func toYaml(v interface{}) string {
-	data, err := yaml.Marshal(v)
-	if err != nil {
-		return ""
-	}
-	return string(data)
-}
-
 // Parses a template defined in a file.
 func ParseTemplate(
 	file string,
 ) *template.Template {
-	tpl := template.New(filepath.Base(file)).Funcs(
-		template.FuncMap{
-			"toYaml": toYaml,
-		}).Funcs(sprig.FuncMap())
+	tpl := template.New(filepath.Base(file))
+	tpl.Funcs(templateFuncs()).Funcs(includeFuncMap(tpl))
 
 	var err error
 
@@ -49,6 +41,63 @@ func ParseTemplate(
 	return tpl
 }
 
+// Parses a Dockerfile template defined in a file, its front matter is
+// omitted.
+func ParseDockerfileTemplate(
+	file string,
+) *template.Template {
+	tpl := template.New(filepath.Base(file))
+	tpl.Funcs(templateFuncs()).Funcs(includeFuncMap(tpl))
+
+	ParseTemplateFiles(tpl, file)
+
+	return tpl
+}
+
+// Returns an empty template set with the template functions. Templates
+// parsed into it once can be added to multiple Dockerfile templates with
+// AddTemplates.
+func NewTemplateSet(name string) *template.Template {
+	tpl := template.New(name)
+	return tpl.Funcs(templateFuncs()).Funcs(includeFuncMap(tpl))
+}
+
+// Adds the templates of a set to the template, replacing templates with
+// the same name. The parse trees are shared and not copied.
+func AddTemplates(tpl *template.Template, set *template.Template) {
+	for _, t := range set.Templates() {
+		if t.Tree == nil || t.Name() == set.Name() {
+			continue
+		}
+		if _, err := tpl.AddParseTree(t.Name(), t.Tree); err != nil {
+			Error("Could not add template '%s': %s", t.Name(), err)
+		}
+	}
+}
+
+// Parses additional Dockerfile template files into an existing template,
+// their front matter is omitted.
+func ParseTemplateFiles(
+	tpl *template.Template,
+	files ...string,
+) {
+	for _, file := range files {
+		_, body := SplitFrontMatter(string(readFile(file)))
+
+		t := tpl
+		if name := filepath.Base(file); name != tpl.Name() {
+			t = tpl.New(name)
+		}
+
+		if _, err := t.Parse(body); err != nil {
+			Error(
+				"Could not parse template '%s': %s",
+				file, err,
+			)
+		}
+	}
+}
+
 // Executes a template with the provided data.
 func ExecuteTemplate(
 	tplData map[string]interface{},
@@ -93,28 +142,95 @@ func LoadYMLFromBytes(
 	}
 }
 
-// Loads yml data from a file.
-func LoadYMLFromFile(
-	filename string,
-	obj interface{},
+// Loads all documents of a (multi-document) yml byte array.
+// The function newObj is called for each document and must return the
+// object the document will be loaded into.
+func LoadYMLDocumentsFromBytes(
+	content []byte,
+	newObj func() interface{},
 ) {
-	Debug(
-		"Loading yaml content from '%s'", filename,
-	)
+	if viper.GetBool("debug") {
+		Debug(
+			fmt.Sprintf("Loading yaml documents: \n\n%s\n\n", string(content)),
+		)
+	}
+
+	DecodeYMLDocuments(bytes.NewReader(content), func(doc *yaml.Node) {
+		if err := doc.Decode(newObj()); err != nil {
+			Error(
+				"Failed to parse yaml document: %s", err,
+			)
+		}
+	})
+}
+
+// Decodes the yaml documents of the reader one at a time, only the current
+// document is held in memory. Empty documents are skipped.
+func DecodeYMLDocuments(
+	reader io.Reader,
+	handle func(doc *yaml.Node),
+) {
+	decoder := yaml.NewDecoder(reader)
+
+	for {
+		var doc yaml.Node
+
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			Error(
+				"Failed to parse yaml: %s", err,
+			)
+		}
 
+		if doc.IsZero() || len(doc.Content) == 0 {
+			continue
+		}
+
+		handle(&doc)
+	}
+}
+
+// Reads the content of a file.
+func readFile(filename string) []byte {
 	path, err := filepath.Abs(filename)
 	if err != nil {
 		Error("%s", err)
 	}
 
-	yml, err := os.ReadFile(path)
+	content, err := os.ReadFile(path)
 	if err != nil {
 		Error(
 			"Failed to load file '%s': %s", filename, err,
 		)
 	}
 
-	LoadYMLFromBytes(yml, obj)
+	return content
+}
+
+// Loads yml data from a file.
+func LoadYMLFromFile(
+	filename string,
+	obj interface{},
+) {
+	Debug(
+		"Loading yaml content from '%s'", filename,
+	)
+
+	LoadYMLFromBytes(readFile(filename), obj)
+}
+
+// Loads all documents of a (multi-document) yml file.
+func LoadYMLDocumentsFromFile(
+	filename string,
+	newObj func() interface{},
+) {
+	Debug(
+		"Loading yaml documents from '%s'", filename,
+	)
+
+	LoadYMLDocumentsFromBytes(readFile(filename), newObj)
 }
 
 // Returns the map specified by path
@@ -142,3 +258,337 @@ func UpdateAndGetMapElementByPath(
 
 	return nil
 }
+
+// Supported strategies for deep merges. Except for overwrite, which
+// replaces nested maps as a whole, they define how slices are merged.
+const (
+	MergeOverwrite    = "overwrite"
+	MergeReplaceSlice = "replace-slice"
+	MergeAppendSlice  = "append-slice"
+	MergeUniqueSlice  = "unique-slice"
+)
+
+// Returns the key path and the value of the first element on the key path
+// which is not a map or empty values if all elements are maps.
+func FindNonMapElementByPath(
+	structure map[string]interface{},
+	keyPath []string,
+) (string, interface{}) {
+	for idx, key := range keyPath {
+		val, ok := structure[key]
+		if !ok {
+			return "", nil
+		}
+
+		nestedMap, ok := val.(map[string]interface{})
+		if !ok {
+			return strings.Join(keyPath[:idx+1], "."), val
+		}
+
+		structure = nestedMap
+	}
+
+	return "", nil
+}
+
+// Removes the element referenced by path from the map.
+// Returns false if the path does not exist.
+func DeleteMapElementByPath(
+	structure map[string]interface{},
+	keyPath []string,
+) bool {
+	if len(keyPath) == 0 || structure == nil {
+		return false
+	}
+
+	val, ok := structure[keyPath[0]]
+	if !ok {
+		return false
+	}
+
+	if len(keyPath) == 1 {
+		delete(structure, keyPath[0])
+		return true
+	}
+
+	if nestedMap, ok := val.(map[string]interface{}); ok {
+		return DeleteMapElementByPath(nestedMap, keyPath[1:])
+	}
+
+	return false
+}
+
+// Verifies that the merge strategy is supported and fails if not.
+func VerifyMergeStrategy(strategy string) {
+	switch strategy {
+	case MergeOverwrite, MergeReplaceSlice, MergeAppendSlice, MergeUniqueSlice:
+	default:
+		Error(
+			"Invalid merge strategy '%s', must be one of '%s', '%s', '%s' or '%s'",
+			strategy, MergeOverwrite, MergeReplaceSlice, MergeAppendSlice, MergeUniqueSlice,
+		)
+	}
+}
+
+// Returns a deep copy of a map, nested maps and slices are copied as well.
+func CopyMap(structure map[string]interface{}) map[string]interface{} {
+	if structure == nil {
+		return nil
+	}
+
+	res := make(map[string]interface{}, len(structure))
+	for key, val := range structure {
+		res[key] = CopyValue(val)
+	}
+
+	return res
+}
+
+// Returns a deep copy of a yml value.
+func CopyValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		return CopyMap(v)
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, elem := range v {
+			res[i] = CopyValue(elem)
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+// Deep merges src into dst and returns dst.
+// Values in src take precedence over the ones in dst, nested maps are merged
+// recursively (replaced with overwrite) and slices are replaced, appended or
+// appended without duplicates according to the strategy.
+func MergeMaps(
+	dst map[string]interface{},
+	src map[string]interface{},
+	strategy string,
+) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+
+	for key, srcVal := range src {
+		dstVal, ok := dst[key]
+		if !ok || strategy == MergeOverwrite {
+			dst[key] = CopyValue(srcVal)
+			continue
+		}
+
+		switch s := srcVal.(type) {
+		case map[string]interface{}:
+			if d, ok := dstVal.(map[string]interface{}); ok {
+				dst[key] = MergeMaps(d, s, strategy)
+				continue
+			}
+		case []interface{}:
+			d, ok := dstVal.([]interface{})
+			if ok && strategy == MergeAppendSlice {
+				dst[key] = append(d, CopyValue(s).([]interface{})...)
+				continue
+			}
+			if ok && strategy == MergeUniqueSlice {
+				dst[key] = appendUnique(d, CopyValue(s).([]interface{}))
+				continue
+			}
+		}
+
+		dst[key] = CopyValue(srcVal)
+	}
+
+	return dst
+}
+
+// Appends the values to the slice which it does not contain yet.
+func appendUnique(slice []interface{}, values []interface{}) []interface{} {
+	for _, val := range values {
+		found := false
+		for _, elem := range slice {
+			if reflect.DeepEqual(elem, val) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			slice = append(slice, val)
+		}
+	}
+	return slice
+}
+
+// Converts a yml compatible structure into another one (e.g. a struct into
+// a map) by marshalling and unmarshalling it.
+func ConvertYML(
+	in interface{},
+	out interface{},
+) {
+	yml, err := yaml.Marshal(in)
+	if err != nil {
+		Error(
+			"Failed to convert yaml structure: %s", err,
+		)
+	}
+
+	if err := yaml.Unmarshal(yml, out); err != nil {
+		Error(
+			"Failed to convert yaml structure: %s", err,
+		)
+	}
+}
+
+// Returns the value node referenced by the key path within a yml mapping
+// node or nil if the path does not exist.
+func GetYMLNodeByPath(
+	node *yaml.Node,
+	keyPath []string,
+) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	if len(keyPath) == 0 {
+		return node
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == keyPath[0] {
+			return GetYMLNodeByPath(node.Content[i+1], keyPath[1:])
+		}
+	}
+
+	return nil
+}
+
+// The value which is interpreted as null by ParseYMLValue.
+const YMLNullMarker = "null"
+
+// Interprets a string as yml value, e.g. 'true' as bool, '42' as int or
+// '[a, b]' as list. Only the exact marker 'null' is interpreted as null.
+// Scalars are only converted if they are written the way they are encoded
+// (e.g. '042' and 'True' stay strings) and collections only in flow style.
+// Floats (which are most likely versions such as 1.20), values containing
+// comments and strings which are no valid yml are returned as they are,
+// the same applies to the elements of collections.
+func ParseYMLValue(value string) interface{} {
+	if value == YMLNullMarker {
+		return nil
+	}
+	if strings.TrimSpace(value) == "" {
+		return value
+	}
+
+	decoder := yaml.NewDecoder(strings.NewReader(value))
+
+	var doc yaml.Node
+	if err := decoder.Decode(&doc); err != nil || len(doc.Content) != 1 {
+		return value
+	}
+	// Multiple documents
+	var next yaml.Node
+	if err := decoder.Decode(&next); !errors.Is(err, io.EOF) {
+		return value
+	}
+
+	if hasYMLComments(&doc) {
+		return value
+	}
+
+	node := doc.Content[0]
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Value != value {
+			return value
+		}
+	case yaml.SequenceNode, yaml.MappingNode:
+		if node.Style&yaml.FlowStyle == 0 {
+			return value
+		}
+	default:
+		return value
+	}
+
+	res, ok := ymlNodeValue(node)
+	if !ok {
+		return value
+	}
+
+	return res
+}
+
+// Returns the value of the node for ParseYMLValue. Plain scalars are only
+// converted if they are booleans, integers or null written the way they are
+// encoded, all other scalars are kept as strings. Returns false if the node
+// cannot be converted (e.g. aliases or keys which are no scalars).
+func ymlNodeValue(node *yaml.Node) (interface{}, bool) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Style != 0 {
+			return node.Value, true
+		}
+		switch node.Tag {
+		case "!!bool", "!!int", "!!null":
+		default:
+			return node.Value, true
+		}
+
+		var res interface{}
+		if err := node.Decode(&res); err != nil {
+			return node.Value, true
+		}
+		encoded, err := yaml.Marshal(res)
+		if err != nil || strings.TrimSuffix(string(encoded), "\n") != node.Value {
+			return node.Value, true
+		}
+		return res, true
+
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(node.Content))
+		for _, child := range node.Content {
+			val, ok := ymlNodeValue(child)
+			if !ok {
+				return nil, false
+			}
+			list = append(list, val)
+		}
+		return list, true
+
+	case yaml.MappingNode:
+		m := make(map[string]interface{}, len(node.Content)/2)
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			key := node.Content[idx]
+			if key.Kind != yaml.ScalarNode {
+				return nil, false
+			}
+			val, ok := ymlNodeValue(node.Content[idx+1])
+			if !ok {
+				return nil, false
+			}
+			m[key.Value] = val
+		}
+		return m, true
+	}
+
+	return nil, false
+}
+
+// Returns whether the node or any of its children has a comment.
+func hasYMLComments(node *yaml.Node) bool {
+	if node.HeadComment != "" || node.LineComment != "" || node.FootComment != "" {
+		return true
+	}
+	for _, child := range node.Content {
+		if hasYMLComments(child) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYMLValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  interface{}
+	}{
+		{"", ""},
+		{" ", " "},
+		{"abc", "abc"},
+		{"true", true},
+		{"false", false},
+		{"True", "True"},
+		{"yes", "yes"},
+		{"42", 42},
+		{"-7", -7},
+		{"042", "042"},
+		{"0x1F", "0x1F"},
+		{"1.20", "1.20"},
+		{"1e3", "1e3"},
+		{"null", nil},
+		{"Null", "Null"},
+		{"~", "~"},
+		{"# x", "# x"},
+		{"a # b", "a # b"},
+		{"42 # b", "42 # b"},
+		{" 42", " 42"},
+		{"[a, b]", []interface{}{"a", "b"}},
+		{"[a, b] # c", "[a, b] # c"},
+		{"{a: 1}", map[string]interface{}{"a": 1}},
+		{"[1.20, 1.21]", []interface{}{"1.20", "1.21"}},
+		{"{go: 1.20}", map[string]interface{}{"go": "1.20"}},
+		{"[True, 042, 0x1F, 1e3, ~, Null]", []interface{}{"True", "042", "0x1F", "1e3", "~", "Null"}},
+		{"[true, 42, null, '7', \"x\"]", []interface{}{true, 42, nil, "7", "x"}},
+		{"{a: [1.20, {b: 3.10}], 1: x}", map[string]interface{}{
+			"a": []interface{}{"1.20", map[string]interface{}{"b": "3.10"}},
+			"1": "x",
+		}},
+		{"[&a x, *a]", "[&a x, *a]"},
+		{"{[a]: b}", "{[a]: b}"},
+		{"- a", "- a"},
+		{"a: b", "a: b"},
+		{`"quoted"`, `"quoted"`},
+		{"[a\n---\n[b]", "[a\n---\n[b]"},
+		{"[a]\n---\n[b]", "[a]\n---\n[b]"},
+		{"[unclosed", "[unclosed"},
+	}
+
+	for _, tc := range tests {
+		if got := ParseYMLValue(tc.value); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseYMLValue(%q) = %#v, want %#v", tc.value, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"fmt"
+)
+
+var (
+	// Whether renders may only depend on the declared inputs.
+	hermetic bool
+)
+
+// Enables the hermetic mode, the functions accessing the environment,
+// the network or executing commands are disabled.
+func SetHermetic() {
+	hermetic = true
+}
+
+// Returns whether the hermetic mode is enabled.
+func IsHermetic() bool {
+	return hermetic
+}
+
+// The functions which are disabled in hermetic mode, besides the network
+// and exec functions which cannot be allowed.
+var hermeticDisabledFuncs = []string{"env", "expandenv", "getHostByName"}
+
+// Returns functions replacing the ones disabled in hermetic mode, they
+// fail when called.
+func hermeticFuncMap() map[string]interface{} {
+	funcs := make(map[string]interface{}, len(hermeticDisabledFuncs))
+
+	for _, name := range hermeticDisabledFuncs {
+		name := name
+		funcs[name] = func(...interface{}) (string, error) {
+			return "", fmt.Errorf("%s is disabled in hermetic mode", name)
+		}
+	}
+
+	return funcs
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// Matches a tag with a patch version, e.g. 1.22.3, v1.2.3 or 3.19.1-slim.
+var patchTagRegex = regexp.MustCompile(`^(v?)(\d+)\.(\d+)\.(\d+)(.*)$`)
+
+// An update of an image reference.
+type ImageUpdate struct {
+	// The newer tag, empty if the tag is up to date.
+	Tag string
+	// The digest of the (newer) tag, empty if the reference is not pinned
+	// to a digest or the digest is up to date.
+	Digest string
+}
+
+// Returns the newest tag with the same major and minor version (and
+// prefix and suffix) and a higher patch version than tag, or an empty
+// string if there is none.
+func LatestPatchTag(tag string, tags []string) string {
+	current := patchTagRegex.FindStringSubmatch(tag)
+	if current == nil {
+		return ""
+	}
+
+	latest := ""
+	latestPatch, _ := strconv.Atoi(current[4])
+
+	for _, candidate := range tags {
+		match := patchTagRegex.FindStringSubmatch(candidate)
+		if match == nil || match[1] != current[1] || match[2] != current[2] ||
+			match[3] != current[3] || match[5] != current[5] {
+			continue
+		}
+
+		if patch, _ := strconv.Atoi(match[4]); patch > latestPatch {
+			latest, latestPatch = candidate, patch
+		}
+	}
+
+	return latest
+}
+
+// Checks the registry for a newer patch version of the tag of an image
+// and, if the reference is pinned to a digest, for a newer digest of the
+// (newer) tag. Returns nil if the reference is up to date.
+func CheckImageUpdate(ref string) (*ImageUpdate, error) {
+	image := ParseImageReference(ref)
+	if image.Tag == "" {
+		Debug("Skipping '%s' which is only pinned to a digest", ref)
+		return nil, nil
+	}
+
+	update := &ImageUpdate{}
+
+	if patchTagRegex.MatchString(image.Tag) {
+		tags, err := ListImageTags(image)
+		if err != nil {
+			return nil, err
+		}
+		if latest := LatestPatchTag(image.Tag, tags); latest != "" {
+			update.Tag = latest
+			image.Tag = latest
+		}
+	}
+
+	if image.Digest != "" {
+		digest, err := ImageDigest(image)
+		if err != nil {
+			return nil, err
+		}
+		if digest != image.Digest {
+			update.Digest = digest
+		}
+	}
+
+	if update.Tag == "" && update.Digest == "" {
+		return nil, nil
+	}
+
+	return update, nil
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Evaluates the jsonnet file with the jsonnet binary and returns the
+// resulting JSON. The values of the external variables are encoded as JSON
+// and passed as code, so std.extVar returns them with their type. The
+// environment variables are passed as external string variables of the
+// same name, jsonnet reads their values from the environment so they do
+// not show up in the arguments.
+func EvaluateJsonnet(file string, extVars map[string]interface{}, envVars []string) ([]byte, error) {
+	names := make([]string, 0, len(extVars))
+	for name := range extVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]string, 0, 2*(len(names)+len(envVars))+1)
+	for _, name := range envVars {
+		if _, ok := extVars[name]; !ok {
+			args = append(args, "--ext-str", name)
+		}
+	}
+	for _, name := range names {
+		code, err := json.Marshal(extVars[name])
+		if err != nil {
+			return nil, fmt.Errorf("could not encode the external variable '%s': %s", name, err)
+		}
+		args = append(args, "--ext-code", name+"="+string(code))
+	}
+	args = append(args, file)
+
+	Trace("Executing 'jsonnet %s'", strings.Join(args, " "))
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("jsonnet", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf(
+			"jsonnet failed: %s: %s", err, strings.TrimSpace(stderr.String()),
+		)
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -3,15 +3,48 @@ package utils
 import (
 	"fmt"
 	golog "log"
+	"os"
 )
 
 var (
 	verbose bool
+	trace   bool
+	noColor bool
+
+	// The functions run before exiting because of an error.
+	exitHooks []func()
+	// The message of the error the application exits with.
+	lastError string
 )
 
-// Logs an error and exits the application.
+// Logs an error and exits the application after running the exit hooks.
 func Error(message string, v ...any) {
+	lastError = fmt.Sprintf(message, v...)
 	log(levelError, message, v...)
+	runExitHooks()
+	os.Exit(1)
+}
+
+// Registers a function which is run before the application exits because
+// of an error, e.g. to release locks. Hooks run in reverse order.
+func AtExit(hook func()) {
+	exitHooks = append(exitHooks, hook)
+}
+
+// Returns the message of the error the application exits with, exit hooks
+// may use it to report the failure.
+func LastError() string {
+	return lastError
+}
+
+// Runs and removes the exit hooks, errors in hooks do not run them again.
+func runExitHooks() {
+	hooks := exitHooks
+	exitHooks = nil
+
+	for idx := len(hooks) - 1; idx >= 0; idx-- {
+		hooks[idx]()
+	}
 }
 
 // Logs a warning.
@@ -31,19 +64,51 @@ func Debug(message string, v ...any) {
 	}
 }
 
+// Logs trace messages.
+func Trace(message string, v ...any) {
+	if trace {
+		log(levelTrace, message, v...)
+	}
+}
+
+// Enables tracing of the template execution.
+func SetTrace() {
+	trace = true
+}
+
 // Enables verbose output (debug).
 func SetVerbose() {
 	verbose = true
 }
 
+// Disables the colored log levels.
+func DisableColor() {
+	noColor = true
+}
+
+// Returns whether the log levels are colored, which is the case on
+// terminals unless disabled by flag or the NO_COLOR environment variable.
+func colored() bool {
+	return !noColor && logFile == nil &&
+		os.Getenv("NO_COLOR") == "" && isTerminal(os.Stderr)
+}
+
 // Log a formatted string with the configured level.
 func log(
 	level logLevel,
 	message string,
 	v ...any,
 ) {
+	suspendProgress()
+	defer resumeProgress()
+
+	prefix := fmt.Sprintf("[%s]:", level)
+	if colored() {
+		prefix = levelColors[level] + prefix + colorReset
+	}
+
 	logs[level](
-		fmt.Sprintf("[%s]: %s", level, message),
+		fmt.Sprintf("%s %s", prefix, message),
 		v...,
 	)
 }
@@ -57,12 +122,25 @@ const (
 	levelInfo  logLevel = "INFO"
 	levelWarn  logLevel = "WARN"
 	levelDebug logLevel = "DEBUG"
+	levelTrace logLevel = "TRACE"
 )
 
 // Log level mappings to the real log function.
 var logs = map[logLevel]func(string, ...any){
-	levelError: golog.Fatalf,
+	levelError: golog.Printf,
 	levelWarn:  golog.Printf,
 	levelInfo:  golog.Printf,
 	levelDebug: golog.Printf,
+	levelTrace: golog.Printf,
+}
+
+// ANSI color codes of the log levels.
+const colorReset = "\033[0m"
+
+var levelColors = map[logLevel]string{
+	levelError: "\033[1;31m",
+	levelWarn:  "\033[1;33m",
+	levelInfo:  "\033[32m",
+	levelDebug: "\033[36m",
+	levelTrace: "\033[90m",
 }
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"fmt"
+	golog "log"
+	"os"
+	"sync"
+)
+
+var (
+	// The file the logs are written to instead of stderr.
+	logFile *rotatingFile
+)
+
+// A log file which is rotated when it exceeds its maximum size, the rotated
+// files are named <path>.1 (newest) to <path>.<keep> (oldest).
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	keep    int
+	file    *os.File
+	size    int64
+}
+
+// Opens the log file for appending.
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Moves the current log file to <path>.1 shifting the older ones and
+// removing the oldest, then opens a new one.
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	if f.keep > 0 {
+		_ = os.Remove(fmt.Sprintf("%s.%d", f.path, f.keep))
+		for idx := f.keep - 1; idx > 0; idx-- {
+			_ = os.Rename(
+				fmt.Sprintf("%s.%d", f.path, idx), fmt.Sprintf("%s.%d", f.path, idx+1),
+			)
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+
+	return f.open()
+}
+
+// Writes to the log file, rotating it first if the write would exceed the
+// maximum size.
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Writes the logs to a file instead of stderr. The file is rotated when it
+// exceeds maxSize bytes (never if 0), keeping the given number of rotated
+// files.
+func SetLogFile(path string, maxSize int64, keep int) {
+	f := &rotatingFile{path: path, maxSize: maxSize, keep: keep}
+	if err := f.open(); err != nil {
+		Error("Could not open log file '%s': %s", path, err)
+	}
+
+	logFile = f
+	golog.SetOutput(f)
+}
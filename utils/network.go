@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+var (
+	// Whether the templates may access the network.
+	networkAllowed bool
+
+	errNetworkDisabled = errors.New(
+		"network access is disabled, enable it with --allow.network",
+	)
+
+	httpClient = &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	// How often failed requests are retried and the delay before the first
+	// retry, which doubles with each attempt.
+	requestRetries = 3
+	requestBackoff = time.Second
+)
+
+// Sets the timeout of each attempt of a request.
+func SetRequestTimeout(timeout time.Duration) {
+	httpClient.Timeout = timeout
+}
+
+// Sets how often failed requests are retried and the delay before the
+// first retry.
+func SetRequestRetries(retries int, backoff time.Duration) {
+	requestRetries = retries
+	requestBackoff = backoff
+}
+
+// Returns whether a request should be retried after the response status.
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// Sends a request, retrying it with exponential backoff on network errors,
+// rate limits and server errors. The body is restored from GetBody for
+// retries, which requests created with a bytes or strings reader provide.
+func sendRequest(req *http.Request) (*http.Response, error) {
+	backoff := requestBackoff
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		res, err := httpClient.Do(req)
+		if attempt >= requestRetries || (err == nil && !isRetryableStatus(res.StatusCode)) {
+			return res, err
+		}
+
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = "status '" + res.Status + "'"
+			res.Body.Close()
+		}
+
+		Warn(
+			"%s request to '%s' failed (%s), retrying in %s (%d/%d)",
+			req.Method, req.URL.Redacted(), reason, backoff, attempt+1, requestRetries,
+		)
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Allows the network template functions (httpGet, httpHead) to be used.
+func AllowNetwork() {
+	networkAllowed = true
+}
+
+// Trusts the PEM encoded certificates of the file in addition to the
+// system certificates for all requests (network functions and registry
+// lookups), e.g. the certificate of a TLS intercepting proxy. Proxies are
+// configured with HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+func SetCAFile(file string) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		Warn("Could not load the system certificates: %s", err)
+		pool = x509.NewCertPool()
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		Error("Could not read CA file '%s': %s", file, err)
+	}
+	if !pool.AppendCertsFromPEM(content) {
+		Error("The CA file '%s' contains no PEM encoded certificates", file)
+	}
+
+	Debug("Trusting the certificates of '%s'", file)
+
+	// The clone keeps the proxy configuration of the default transport
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	httpClient.Transport = transport
+}
+
+// Returns the network template functions.
+func networkFuncMap() map[string]interface{} {
+	return map[string]interface{}{
+		"httpGet":  httpGet,
+		"httpHead": httpHead,
+	}
+}
+
+// Sends a request and fails if the response status is not successful.
+func doRequest(method string, url string) (*http.Response, error) {
+	if !networkAllowed {
+		return nil, errNetworkDisabled
+	}
+
+	Debug("Sending %s request to '%s'", method, url)
+
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := sendRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return nil, fmt.Errorf(
+			"%s request to '%s' failed with status '%s'", method, url, res.Status,
+		)
+	}
+
+	return res, nil
+}
+
+// Posts the payload encoded as JSON to the url, e.g. a webhook. The request
+// is sent regardless of whether the network functions are allowed.
+func PostJSON(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	Debug("Sending POST request to '%s'", url)
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := sendRequest(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("POST request to '%s' failed with status '%s'", url, res.Status)
+	}
+
+	return nil
+}
+
+// Returns the body of a GET request to the url.
+func httpGet(url string) (string, error) {
+	if replayed, ok, err := replayedResponse(http.MethodGet, url); ok {
+		return replayed.Body, err
+	}
+
+	res, err := doRequest(http.MethodGet, url)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	recordResponse(RecordedResponse{Method: http.MethodGet, URL: url, Body: string(body)})
+
+	return string(body), nil
+}
+
+// Returns the headers of a HEAD request to the url, the keys are the
+// canonical header names (e.g. Content-Length).
+func httpHead(url string) (map[string]string, error) {
+	if replayed, ok, err := replayedResponse(http.MethodHead, url); ok {
+		return replayed.Headers, err
+	}
+
+	res, err := doRequest(http.MethodHead, url)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+
+	headers := make(map[string]string, len(res.Header))
+	for key := range res.Header {
+		headers[key] = res.Header.Get(key)
+	}
+
+	recordResponse(RecordedResponse{Method: http.MethodHead, URL: url, Headers: headers})
+
+	return headers, nil
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Characters which are not allowed in file names on Windows.
+const invalidFileNameChars = `<>:"\|?*`
+
+// Device names which are reserved on Windows regardless of the extension.
+var reservedFileNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Verifies that a relative, slash separated file name is valid on all
+// platforms and does not leave the directory it is relative to.
+func ValidateFileName(name string) error {
+	if name == "" {
+		return fmt.Errorf("file name is empty")
+	}
+
+	if strings.HasPrefix(name, "/") {
+		return fmt.Errorf("file name '%s' must be relative", name)
+	}
+
+	for _, elem := range strings.Split(name, "/") {
+		if err := validateFileNameElement(elem); err != nil {
+			return fmt.Errorf("invalid file name '%s': %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Verifies a single element of a file name.
+func validateFileNameElement(elem string) error {
+	switch elem {
+	case "":
+		return fmt.Errorf("empty path element")
+	case ".", "..":
+		return fmt.Errorf("'%s' is not allowed", elem)
+	}
+
+	for _, r := range elem {
+		if r < 0x20 || strings.ContainsRune(invalidFileNameChars, r) {
+			return fmt.Errorf("'%s' contains the invalid character %q", elem, r)
+		}
+	}
+
+	if strings.HasSuffix(elem, ".") || strings.HasSuffix(elem, " ") {
+		return fmt.Errorf("'%s' must not end with a dot or space", elem)
+	}
+
+	base := strings.ToUpper(strings.SplitN(elem, ".", 2)[0])
+	if reservedFileNames[base] {
+		return fmt.Errorf("'%s' is a reserved name on Windows", elem)
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// An instruction of a Dockerfile as passed to the policies. The keys
+// follow the Dockerfile input of conftest, so its policies can be reused.
+type PolicyInstruction struct {
+	// The lower case instruction, e.g. from or user.
+	Cmd string `json:"Cmd"`
+	// The flags of the instruction, e.g. --from=build.
+	Flags []string `json:"Flags"`
+	// The arguments without flags split at whitespace.
+	Value []string `json:"Value"`
+	// The instruction as written in the Dockerfile.
+	Original string `json:"Original"`
+	// The index of the stage the instruction belongs to.
+	Stage int `json:"Stage"`
+}
+
+// A build stage of a Dockerfile as passed to the policies.
+type PolicyStage struct {
+	Name string `json:"name"`
+	Base string `json:"base"`
+}
+
+// The input of the policies for the rendered Dockerfile of a variant.
+type PolicyInput struct {
+	Variant      string              `json:"variant"`
+	Stages       []PolicyStage       `json:"stages"`
+	Instructions []PolicyInstruction `json:"instructions"`
+}
+
+// Parses a rendered Dockerfile into the input of the policies.
+func NewPolicyInput(variant string, content []byte) *PolicyInput {
+	d := ParseDockerfile(string(content))
+
+	input := &PolicyInput{
+		Variant:      variant,
+		Stages:       make([]PolicyStage, 0),
+		Instructions: make([]PolicyInstruction, 0),
+	}
+
+	stage := -1
+	for _, node := range d.Nodes {
+		if node.Instruction == "" {
+			continue
+		}
+
+		if node.Instruction == "FROM" {
+			stage++
+		}
+
+		fields := d.Fields(node)
+		operands := d.Operands(node)
+
+		input.Instructions = append(input.Instructions, PolicyInstruction{
+			Cmd:      strings.ToLower(node.Instruction),
+			Flags:    append(make([]string, 0), fields[:len(fields)-len(operands)]...),
+			Value:    append(make([]string, 0), operands...),
+			Original: strings.Join(node.Lines, "\n"),
+			Stage:    stage,
+		})
+	}
+
+	for _, s := range d.Stages() {
+		input.Stages = append(input.Stages, PolicyStage{Name: s.Name, Base: s.Base})
+	}
+
+	return input
+}
+
+// Evaluates the query (e.g. data.dockerfile.deny) against the input with
+// the policies (rego files or directories) using the opa binary. Returns
+// the violations, the query must evaluate to a set or array of messages.
+func EvaluatePolicies(policies []string, query string, input *PolicyInput) ([]string, error) {
+	stdin, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"eval", "--format", "json", "--stdin-input"}
+	for _, policy := range policies {
+		args = append(args, "--data", policy)
+	}
+	args = append(args, query)
+
+	Trace("Executing 'opa %s'", strings.Join(args, " "))
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("opa", args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf(
+			"opa failed: %s: %s", err, strings.TrimSpace(stderr.String()+stdout.String()),
+		)
+	}
+
+	var output struct {
+		Result []struct {
+			Expressions []struct {
+				Value interface{} `json:"value"`
+			} `json:"expressions"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return nil, fmt.Errorf("could not parse the output of opa: %s", err)
+	}
+
+	var violations []string
+	for _, result := range output.Result {
+		for _, expression := range result.Expressions {
+			values, ok := expression.Value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf(
+					"the query '%s' must evaluate to a set or array of messages", query,
+				)
+			}
+
+			for _, val := range values {
+				if msg, ok := val.(string); ok {
+					violations = append(violations, msg)
+					continue
+				}
+				encoded, _ := json.Marshal(val)
+				violations = append(violations, string(encoded))
+			}
+		}
+	}
+
+	return violations, nil
+}
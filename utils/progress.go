@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	// The progress currently displayed, logs are printed above it.
+	activeProgress *Progress
+)
+
+// Displays a live counter of the processed items on the last line of a
+// terminal, nothing is displayed if stderr is not a terminal.
+type Progress struct {
+	action  string
+	total   int
+	done    int
+	current string
+	start   time.Time
+	enabled bool
+}
+
+// Returns whether the file is a terminal.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Starts displaying the progress of an action over total items.
+func NewProgress(action string, total int) *Progress {
+	p := &Progress{
+		action:  action,
+		total:   total,
+		start:   time.Now(),
+		enabled: total > 1 && isTerminal(os.Stderr),
+	}
+
+	if p.enabled {
+		activeProgress = p
+	}
+
+	return p
+}
+
+// Marks the previous item as done and displays the item now processed.
+func (p *Progress) Step(item string) {
+	if p.current != "" {
+		p.done++
+	}
+	p.current = item
+
+	p.draw()
+}
+
+// Removes the progress from the terminal.
+func (p *Progress) Done() {
+	if !p.enabled {
+		return
+	}
+
+	p.clear()
+	p.enabled = false
+	activeProgress = nil
+}
+
+// Returns the estimated remaining time based on the processed items.
+func (p *Progress) eta() string {
+	if p.done == 0 {
+		return ""
+	}
+
+	perItem := time.Since(p.start) / time.Duration(p.done)
+	remaining := perItem * time.Duration(p.total-p.done)
+
+	return fmt.Sprintf(", ETA %s", remaining.Round(time.Second))
+}
+
+// Draws the progress on the last line.
+func (p *Progress) draw() {
+	if !p.enabled {
+		return
+	}
+
+	fmt.Fprintf(
+		os.Stderr, "\r\033[K[%d/%d] %s '%s'%s",
+		p.done+1, p.total, p.action, p.current, p.eta(),
+	)
+}
+
+// Clears the last line.
+func (p *Progress) clear() {
+	if p.enabled {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+}
+
+// Removes the active progress from the terminal until resumeProgress is
+// called, so other output does not mix with it.
+func suspendProgress() {
+	if activeProgress != nil {
+		activeProgress.clear()
+	}
+}
+
+// Redraws the active progress.
+func resumeProgress() {
+	if activeProgress != nil {
+		activeProgress.draw()
+	}
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	// Whether missing required values are prompted for.
+	interactive bool
+
+	// Reads the answers to prompts.
+	promptReader = bufio.NewReader(os.Stdin)
+
+	// The answers given per variant and prompt.
+	promptAnswers = make(map[string]interface{})
+)
+
+// Enables prompting for missing required values.
+func SetInteractive() {
+	interactive = true
+}
+
+// Returns whether a value is missing (nil or an empty string).
+func isMissing(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+	s, ok := val.(string)
+	return ok && s == ""
+}
+
+// Prompts for a value on stdin, the answer is interpreted as yml value.
+// Answers are remembered per variant and message.
+func Prompt(variant string, message string) (interface{}, error) {
+	key := variant + "\x00" + message
+	if answer, ok := promptAnswers[key]; ok {
+		return answer, nil
+	}
+
+	suspendProgress()
+	defer resumeProgress()
+
+	if variant != "" {
+		fmt.Fprintf(os.Stderr, "[%s] %s: ", variant, message)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: ", message)
+	}
+
+	line, err := promptReader.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return nil, fmt.Errorf("no value given for '%s': %s", message, err)
+	}
+
+	answer := ParseYMLValue(strings.TrimRight(line, "\r\n"))
+	if isMissing(answer) {
+		return nil, fmt.Errorf("no value given for '%s'", message)
+	}
+
+	promptAnswers[key] = answer
+	return answer, nil
+}
+
+// Returns the required template function of a variant, it fails with the
+// message if the value is missing or prompts for it in interactive mode.
+func Required(variant string) func(string, interface{}) (interface{}, error) {
+	return func(message string, val interface{}) (interface{}, error) {
+		if !isMissing(val) {
+			return val, nil
+		}
+
+		if interactive {
+			return Prompt(variant, message)
+		}
+
+		return nil, errors.New(message)
+	}
+}
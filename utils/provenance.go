@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Types of the in-toto statement and its SLSA provenance predicate.
+const (
+	inTotoStatementType  = "https://in-toto.io/Statement/v1"
+	slsaProvenanceType   = "https://slsa.dev/provenance/v1"
+	inTotoPayloadType    = "application/vnd.in-toto+json"
+	provenanceBuilderID  = "https://github.com/bossm8/dockerfile-templater"
+	provenanceBuildType  = provenanceBuilderID + "/templater@v1"
+	provenanceToolName   = "dockerfile-templater"
+	dssePAEPrefix        = "DSSEv1"
+	provenanceDigestAlgo = "sha256"
+)
+
+// A file and its digests.
+type ResourceDescriptor struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// An in-toto statement about the generated files.
+type ProvenanceStatement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     provenancePredicate  `json:"predicate"`
+}
+
+type provenancePredicate struct {
+	BuildDefinition struct {
+		BuildType            string                 `json:"buildType"`
+		ExternalParameters   map[string]interface{} `json:"externalParameters"`
+		ResolvedDependencies []ResourceDescriptor   `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID      string            `json:"id"`
+			Version map[string]string `json:"version"`
+		} `json:"builder"`
+		Metadata struct {
+			StartedOn string `json:"startedOn"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+// A DSSE envelope holding a signed statement.
+type dsseEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"`
+	Signatures  []dsseSignature `json:"signatures"`
+}
+
+type dsseSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// Returns the descriptor of a file, the name is the path relative to dir
+// with forward slashes.
+func FileDescriptor(dir string, file string) ResourceDescriptor {
+	name := file
+
+	absDir, errDir := filepath.Abs(dir)
+	absFile, errFile := filepath.Abs(file)
+	if errDir == nil && errFile == nil {
+		if rel, err := filepath.Rel(absDir, absFile); err == nil {
+			name = rel
+		}
+	}
+
+	sum := sha256.Sum256(readFile(file))
+
+	return ResourceDescriptor{
+		Name:   filepath.ToSlash(name),
+		Digest: map[string]string{provenanceDigestAlgo: hex.EncodeToString(sum[:])},
+	}
+}
+
+// Returns a SLSA provenance statement for the generated files (subjects)
+// which were produced from the input files (dependencies).
+func NewProvenanceStatement(
+	version string,
+	startedOn string,
+	parameters map[string]interface{},
+	dependencies []ResourceDescriptor,
+	subjects []ResourceDescriptor,
+) *ProvenanceStatement {
+	s := &ProvenanceStatement{
+		Type:          inTotoStatementType,
+		Subject:       subjects,
+		PredicateType: slsaProvenanceType,
+	}
+
+	def := &s.Predicate.BuildDefinition
+	def.BuildType = provenanceBuildType
+	def.ExternalParameters = parameters
+	def.ResolvedDependencies = dependencies
+
+	run := &s.Predicate.RunDetails
+	run.Builder.ID = provenanceBuilderID
+	run.Builder.Version = map[string]string{provenanceToolName: version}
+	run.Metadata.StartedOn = startedOn
+
+	return s
+}
+
+// Returns the DSSE pre-authentication encoding of a payload.
+func dssePAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf(
+		"%s %d %s %d %s",
+		dssePAEPrefix, len(payloadType), payloadType, len(payload), payload,
+	))
+}
+
+// Loads an ed25519 private key from a PEM encoded PKCS #8 file.
+func loadSigningKey(keyFile string) ed25519.PrivateKey {
+	block, _ := pem.Decode(readFile(keyFile))
+	if block == nil {
+		Error(
+			"Signing key '%s' is not PEM encoded", keyFile,
+		)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		Error(
+			"Could not parse signing key '%s': %s", keyFile, err,
+		)
+	}
+
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		Error(
+			"Signing key '%s' is not an ed25519 key", keyFile,
+		)
+	}
+
+	return edKey
+}
+
+// Returns the id of a public key (hex encoded sha256 of its DER encoding).
+func keyID(key ed25519.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		Error("Could not encode public key: %s", err)
+	}
+
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// Writes the statement to file, it is wrapped in a signed DSSE envelope if
+// a key file is given.
+func WriteProvenance(
+	statement *ProvenanceStatement,
+	file string,
+	keyFile string,
+) {
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		Error("Could not encode provenance statement: %s", err)
+	}
+
+	content := payload
+
+	if keyFile != "" {
+		key := loadSigningKey(keyFile)
+		sig := ed25519.Sign(key, dssePAE(inTotoPayloadType, payload))
+
+		content, err = json.Marshal(dsseEnvelope{
+			PayloadType: inTotoPayloadType,
+			Payload:     base64.StdEncoding.EncodeToString(payload),
+			Signatures: []dsseSignature{{
+				KeyID: keyID(key.Public().(ed25519.PublicKey)),
+				Sig:   base64.StdEncoding.EncodeToString(sig),
+			}},
+		})
+		if err != nil {
+			Error("Could not encode provenance envelope: %s", err)
+		}
+	}
+
+	Info(
+		"Writing provenance to '%s'", file,
+	)
+
+	if err := os.WriteFile(file, append(content, '\n'), 0o644); err != nil {
+		Error(
+			"Could not write provenance to '%s': %s", file, err,
+		)
+	}
+}
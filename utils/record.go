@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A response of a network function recorded for a bundle.
+type RecordedResponse struct {
+	Method  string            `yaml:"method"`
+	URL     string            `yaml:"url"`
+	Body    string            `yaml:"body,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+var (
+	// Whether the files and responses accessed by the templates are
+	// recorded.
+	recording bool
+	// The absolute paths of the files accessed by the templates.
+	recordedFiles = make(map[string]bool)
+	// The responses of the network functions by method and url.
+	recordedResponses = make(map[string]RecordedResponse)
+
+	// The responses the network functions are answered with instead of
+	// sending requests, nil if they are not replayed.
+	replayedResponses map[string]RecordedResponse
+)
+
+// Records the files the template functions read (readFile, glob, ...) and
+// the responses of the network functions.
+func RecordAccess() {
+	recording = true
+}
+
+// Returns the absolute paths of the files accessed by the templates sorted.
+func RecordedFiles() []string {
+	files := make([]string, 0, len(recordedFiles))
+	for file := range recordedFiles {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Returns the recorded responses of the network functions sorted by url.
+func RecordedResponses() []RecordedResponse {
+	responses := make([]RecordedResponse, 0, len(recordedResponses))
+	for _, res := range recordedResponses {
+		responses = append(responses, res)
+	}
+	sort.Slice(responses, func(i, j int) bool {
+		if responses[i].URL != responses[j].URL {
+			return responses[i].URL < responses[j].URL
+		}
+		return responses[i].Method < responses[j].Method
+	})
+	return responses
+}
+
+// Answers the network functions with the responses instead of sending
+// requests, requests which were not recorded fail.
+func ReplayResponses(responses []RecordedResponse) {
+	replayedResponses = make(map[string]RecordedResponse, len(responses))
+	for _, res := range responses {
+		replayedResponses[res.Method+" "+res.URL] = res
+	}
+}
+
+// Records a file accessed by the templates.
+func recordFile(abs string) {
+	if recording {
+		recordedFiles[abs] = true
+	}
+}
+
+// Records a response of a network function.
+func recordResponse(res RecordedResponse) {
+	if recording {
+		recordedResponses[res.Method+" "+res.URL] = res
+	}
+}
+
+// Returns the replayed response of the request, ok is false if the
+// responses are not replayed.
+func replayedResponse(method string, url string) (res RecordedResponse, ok bool, err error) {
+	if replayedResponses == nil {
+		return res, false, nil
+	}
+
+	res, found := replayedResponses[method+" "+url]
+	if !found {
+		return res, true, fmt.Errorf("the %s request to '%s' is not recorded in the bundle", method, url)
+	}
+
+	Debug("Replaying the recorded response of the %s request to '%s'", method, url)
+	return res, true, nil
+}
@@ -0,0 +1,316 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	dockerHubDomain   = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+	// The key of Docker Hub in the docker config.
+	dockerHubAuthKey = "https://index.docker.io/v1/"
+)
+
+// The media types of the manifests requested from registries.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// A parsed image reference.
+type ImageReference struct {
+	// The registry domain, docker.io for Docker Hub.
+	Domain string
+	// The repository path, e.g. library/alpine.
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// Parses an image reference, references without registry refer to Docker
+// Hub and references without tag and digest to the tag latest.
+func ParseImageReference(ref string) ImageReference {
+	var image ImageReference
+
+	name, digest, _ := strings.Cut(ref, "@")
+	image.Digest = digest
+
+	if idx := strings.LastIndex(name, ":"); idx > strings.LastIndex(name, "/") {
+		name, image.Tag = name[:idx], name[idx+1:]
+	}
+	if image.Tag == "" && image.Digest == "" {
+		image.Tag = "latest"
+	}
+
+	domain, path, ok := strings.Cut(name, "/")
+	if !ok || (!strings.ContainsAny(domain, ".:") && domain != "localhost") {
+		domain, path = dockerHubDomain, name
+	}
+	if domain == dockerHubDomain && !strings.Contains(path, "/") {
+		path = "library/" + path
+	}
+
+	image.Domain = domain
+	image.Repository = path
+
+	return image
+}
+
+// Returns the tag or digest of the reference, the digest takes precedence.
+func (i ImageReference) Reference() string {
+	if i.Digest != "" {
+		return i.Digest
+	}
+	return i.Tag
+}
+
+// Returns the base url of the registry (scheme and host).
+func (i ImageReference) registryURL() string {
+	host := i.Domain
+	if host == dockerHubDomain {
+		host = dockerHubRegistry
+	}
+
+	scheme := "https"
+	if hostname, _, _ := strings.Cut(host, ":"); hostname == "localhost" || hostname == "127.0.0.1" {
+		scheme = "http"
+	}
+
+	return scheme + "://" + host
+}
+
+// Parses the parameters of a WWW-Authenticate challenge, e.g.
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io".
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(header, " ")
+	params := make(map[string]string)
+
+	for _, part := range strings.Split(rest, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[strings.ToLower(key)] = strings.Trim(val, `"`)
+		}
+	}
+
+	return strings.ToLower(scheme), params
+}
+
+// Requests a bearer token from the realm of the challenge.
+func registryToken(params map[string]string, user string, password string) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid token realm '%s'", params["realm"])
+	}
+
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if user != "" {
+		req.SetBasicAuth(user, password)
+	}
+
+	res, err := sendRequest(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed with status '%s'", res.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// Sends a request to the registry api of the image (path relative to
+// /v2/<repository>/ or absolute), authenticating with the credentials of
+// the docker config if the registry requires it. The caller must close the
+// body.
+func registryRequest(
+	method string,
+	image ImageReference,
+	path string,
+	accept []string,
+) (*http.Response, error) {
+	endpoint := image.registryURL() + path
+	if !strings.HasPrefix(path, "/") {
+		endpoint = image.registryURL() + "/v2/" + image.Repository + "/" + path
+	}
+
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequest(method, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, mediaType := range accept {
+			req.Header.Add("Accept", mediaType)
+		}
+		return req, nil
+	}
+
+	Debug("Sending %s request to '%s'", method, endpoint)
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := sendRequest(req)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+	res.Body.Close()
+
+	user, password := registryCredentials(image.Domain)
+	scheme, params := parseChallenge(res.Header.Get("WWW-Authenticate"))
+
+	if req, err = newRequest(); err != nil {
+		return nil, err
+	}
+
+	switch scheme {
+	case "bearer":
+		if params["scope"] == "" {
+			params["scope"] = "repository:" + image.Repository + ":pull"
+		}
+		token, err := registryToken(params, user, password)
+		if err != nil {
+			return nil, fmt.Errorf("could not authenticate to '%s': %w", image.Domain, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case "basic":
+		if user == "" {
+			return nil, fmt.Errorf("no credentials for '%s' in the docker config", image.Domain)
+		}
+		req.SetBasicAuth(user, password)
+	default:
+		return nil, fmt.Errorf("unsupported authentication scheme of '%s'", image.Domain)
+	}
+
+	return sendRequest(req)
+}
+
+// Verifies that the manifest of an image exists in its registry.
+func VerifyImageExists(ref string) error {
+	image := ParseImageReference(ref)
+
+	res, err := registryRequest(
+		http.MethodHead, image, "manifests/"+image.Reference(), manifestMediaTypes,
+	)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusOK:
+		return nil
+	case res.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("'%s' does not exist", ref)
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("access to '%s' denied (%s)", ref, res.Status)
+	default:
+		return fmt.Errorf("could not verify '%s', the registry responded '%s'", ref, res.Status)
+	}
+}
+
+// Returns the digest of the manifest the tag of the image points to.
+func ImageDigest(image ImageReference) (string, error) {
+	res, err := registryRequest(
+		http.MethodHead, image, "manifests/"+image.Tag, manifestMediaTypes,
+	)
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf(
+			"could not get the digest of '%s:%s', the registry responded '%s'",
+			image.Repository, image.Tag, res.Status,
+		)
+	}
+
+	digest := res.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf(
+			"the registry returned no digest for '%s:%s'", image.Repository, image.Tag,
+		)
+	}
+
+	return digest, nil
+}
+
+// Matches the next page of a paginated registry response, e.g.
+// </v2/library/alpine/tags/list?last=3.19&n=1000>; rel="next".
+var nextLinkRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="?next"?`)
+
+// Returns all tags of the repository of the image.
+func ListImageTags(image ImageReference) ([]string, error) {
+	var tags []string
+
+	path := "tags/list?n=1000"
+	for path != "" {
+		res, err := registryRequest(http.MethodGet, image, path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return nil, fmt.Errorf(
+				"could not list the tags of '%s', the registry responded '%s'",
+				image.Repository, res.Status,
+			)
+		}
+
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, page.Tags...)
+
+		path = ""
+		if match := nextLinkRegex.FindStringSubmatch(res.Header.Get("Link")); match != nil {
+			next, err := url.Parse(match[1])
+			if err != nil {
+				return nil, err
+			}
+			path = next.RequestURI()
+		}
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// The severities of misconfigurations in ascending order.
+var severities = []string{"UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// Returns the rank of a severity (higher is more severe) or -1 if the
+// severity is not known.
+func SeverityRank(severity string) int {
+	for idx, s := range severities {
+		if strings.EqualFold(s, severity) {
+			return idx
+		}
+	}
+	return -1
+}
+
+// Verifies that the severity is known and fails if not.
+func VerifySeverity(severity string) {
+	if SeverityRank(severity) < 0 {
+		Error(
+			"Invalid severity '%s', must be one of %s",
+			severity, strings.Join(severities, ", "),
+		)
+	}
+}
+
+// A misconfiguration found by the config scanner.
+type ScanFinding struct {
+	ID       string `json:"id" yaml:"id"`
+	Severity string `json:"severity" yaml:"severity"`
+	Title    string `json:"title" yaml:"title"`
+	Message  string `json:"message" yaml:"message"`
+	Line     int    `json:"line,omitempty" yaml:"line,omitempty"`
+}
+
+// Scans a rendered Dockerfile for misconfigurations with 'trivy config'
+// and returns the failed checks.
+func ScanDockerfile(content []byte) ([]ScanFinding, error) {
+	dir, err := os.MkdirTemp("", "dtpl-scan-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), content, 0o644); err != nil {
+		return nil, err
+	}
+
+	args := []string{"config", "--quiet", "--format", "json", dir}
+	Trace("Executing 'trivy %s'", strings.Join(args, " "))
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("trivy", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf(
+			"trivy failed: %s: %s", err, strings.TrimSpace(stderr.String()),
+		)
+	}
+
+	var report struct {
+		Results []struct {
+			Misconfigurations []struct {
+				ID            string
+				Title         string
+				Message       string
+				Severity      string
+				Status        string
+				CauseMetadata struct {
+					StartLine int
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		return nil, fmt.Errorf("could not parse the output of trivy: %s", err)
+	}
+
+	var findings []ScanFinding
+	for _, result := range report.Results {
+		for _, m := range result.Misconfigurations {
+			if m.Status != "FAIL" {
+				continue
+			}
+			findings = append(findings, ScanFinding{
+				ID:       m.ID,
+				Severity: m.Severity,
+				Title:    m.Title,
+				Message:  m.Message,
+				Line:     m.CauseMetadata.StartLine,
+			})
+		}
+	}
+
+	return findings, nil
+}
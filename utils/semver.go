@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Masterminds/semver"
+)
+
+// Returns the semver template functions, they complement the semver and
+// semverCompare functions provided by sprig.
+func semverFuncMap() map[string]interface{} {
+	return map[string]interface{}{
+		"semverMajor":  semverMajor,
+		"semverMinor":  semverMinor,
+		"semverPatch":  semverPatch,
+		"semverSort":   semverSort,
+		"semverLatest": semverLatest,
+	}
+}
+
+// Returns the major version of a semantic version.
+func semverMajor(version string) (int64, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return 0, err
+	}
+	return v.Major(), nil
+}
+
+// Returns the minor version of a semantic version.
+func semverMinor(version string) (int64, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return 0, err
+	}
+	return v.Minor(), nil
+}
+
+// Returns the patch version of a semantic version.
+func semverPatch(version string) (int64, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return 0, err
+	}
+	return v.Patch(), nil
+}
+
+// Parses a list of semantic versions (the list may contain any values
+// which are formatted as strings).
+func parseVersions(versions []interface{}) ([]*semver.Version, error) {
+	res := make([]*semver.Version, 0, len(versions))
+
+	for _, version := range versions {
+		v, err := semver.NewVersion(fmt.Sprint(version))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+
+	return res, nil
+}
+
+// Returns the versions sorted in ascending order.
+func semverSort(versions []interface{}) ([]string, error) {
+	parsed, err := parseVersions(versions)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(semver.Collection(parsed))
+
+	res := make([]string, len(parsed))
+	for idx, v := range parsed {
+		res[idx] = v.Original()
+	}
+
+	return res, nil
+}
+
+// Returns the highest version satisfying the constraint
+// (e.g. '~1.22' or '>=1.20, <2') or an empty string if none does.
+func semverLatest(constraint string, versions []interface{}) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+
+	parsed, err := parseVersions(versions)
+	if err != nil {
+		return "", err
+	}
+
+	var latest *semver.Version
+	for _, v := range parsed {
+		if c.Check(v) && (latest == nil || v.GreaterThan(latest)) {
+			latest = v
+		}
+	}
+
+	if latest == nil {
+		return "", nil
+	}
+
+	return latest.Original(), nil
+}
+
+// Returns the highest of the tags satisfying the constraint if it is
+// higher than the current version, or an empty string if there is none.
+// The constraint defaults to the patch versions of the current version
+// (~current). Tags which are no semantic versions (e.g. latest or alpine)
+// are ignored.
+func BumpVersion(current string, constraint string, tags []string) (string, error) {
+	currentVersion, err := semver.NewVersion(current)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is no semantic version", current)
+	}
+
+	if constraint == "" {
+		constraint = "~" + current
+	}
+
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+
+	var latest *semver.Version
+	for _, tag := range tags {
+		v, err := semver.NewVersion(tag)
+		if err != nil {
+			continue
+		}
+		if c.Check(v) && v.GreaterThan(currentVersion) &&
+			(latest == nil || v.GreaterThan(latest)) {
+			latest = v
+		}
+	}
+
+	if latest == nil {
+		return "", nil
+	}
+
+	return latest.Original(), nil
+}
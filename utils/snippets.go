@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"embed"
+	"io/fs"
+	"path"
+	"text/template"
+)
+
+//go:embed snippets/*.tpl
+var snippets embed.FS
+
+// Returns the template definitions of the built-in snippet library.
+func SnippetDefinitions() []*TemplateDefinition {
+	var defs []*TemplateDefinition
+
+	files, err := fs.Glob(snippets, "snippets/*.tpl")
+	if err != nil {
+		Error("Could not load built-in snippets: %s", err)
+	}
+
+	for _, file := range files {
+		content, err := snippets.ReadFile(file)
+		if err != nil {
+			Error("Could not load built-in snippet '%s': %s", file, err)
+		}
+
+		defs = append(defs, parseDefinitions(
+			"dtpl/"+path.Base(file), "builtin:"+file, string(content),
+		)...)
+	}
+
+	return defs
+}
+
+// Adds the built-in snippet library (templates prefixed with dtpl/) to the
+// template. Snippets should be added before user defined templates to allow
+// overriding them.
+func ParseSnippets(tpl *template.Template) {
+	Debug("Including built-in snippets")
+
+	// The files themselves are named with the prefix too to prevent
+	// collisions with user defined template files.
+	for _, def := range SnippetDefinitions() {
+		if _, err := tpl.AddParseTree(def.Name, def.Tree); err != nil {
+			Error("Could not add built-in snippet '%s': %s", def.Source, err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+{{- /* version 1 */ -}}
+{{- /*
+Installs packages with apt-get using BuildKit cache mounts.
+Parameters (dict): packages (list)
+*/ -}}
+{{- define "dtpl/apt-cache" -}}
+RUN --mount=type=cache,target=/var/cache/apt,sharing=locked \
+    --mount=type=cache,target=/var/lib/apt,sharing=locked \
+    rm -f /etc/apt/apt.conf.d/docker-clean \
+    && apt-get update \
+    && apt-get install -y --no-install-recommends {{ .packages | uniq | sortAlpha | join " " }}
+{{- end -}}
+
+{{- /*
+Installs packages with apk using BuildKit cache mounts.
+Parameters (dict): packages (list)
+*/ -}}
+{{- define "dtpl/apk-cache" -}}
+RUN --mount=type=cache,target=/var/cache/apk,sharing=locked \
+    apk add --cache-dir /var/cache/apk {{ .packages | uniq | sortAlpha | join " " }}
+{{- end -}}
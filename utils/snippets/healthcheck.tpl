@@ -0,0 +1,10 @@
+{{- /* version 1 */ -}}
+{{- /*
+Adds a healthcheck.
+Parameters (dict): cmd (required), interval (30s), timeout (5s), start (0s), retries (3)
+*/ -}}
+{{- define "dtpl/healthcheck" -}}
+{{- if not .cmd }}{{ fail "dtpl/healthcheck requires the parameter cmd" }}{{ end -}}
+HEALTHCHECK --interval={{ .interval | default "30s" }} --timeout={{ .timeout | default "5s" }} --start-period={{ .start | default "0s" }} --retries={{ .retries | default 3 }} \
+    CMD {{ .cmd }}
+{{- end -}}
@@ -0,0 +1,11 @@
+{{- /* version 1 */ -}}
+{{- /*
+Installs tini (static binary for the target architecture) as entrypoint.
+Parameters (dict): version (v0.19.0)
+*/ -}}
+{{- define "dtpl/tini" -}}
+ARG TARGETARCH
+ADD https://github.com/krallin/tini/releases/download/{{ .version | default "v0.19.0" }}/tini-static-${TARGETARCH} /sbin/tini
+RUN chmod +x /sbin/tini
+ENTRYPOINT ["/sbin/tini", "--"]
+{{- end -}}
@@ -0,0 +1,26 @@
+{{- /* version 1 */ -}}
+{{- /*
+Creates a non-root user and switches to it.
+Parameters (dict): name (app), uid (1000), gid (uid), shell (/bin/sh)
+*/ -}}
+{{- define "dtpl/user" -}}
+{{- $name := .name | default "app" -}}
+{{- $uid := .uid | default 1000 -}}
+{{- $gid := .gid | default $uid -}}
+RUN groupadd --gid {{ $gid }} {{ $name }} \
+    && useradd --uid {{ $uid }} --gid {{ $gid }} --create-home --shell {{ .shell | default "/bin/sh" }} {{ $name }}
+USER {{ $name }}
+{{- end -}}
+
+{{- /*
+Creates a non-root user on alpine (busybox) and switches to it.
+Parameters (dict): name (app), uid (1000), gid (uid), shell (/bin/sh)
+*/ -}}
+{{- define "dtpl/user-alpine" -}}
+{{- $name := .name | default "app" -}}
+{{- $uid := .uid | default 1000 -}}
+{{- $gid := .gid | default $uid -}}
+RUN addgroup -g {{ $gid }} {{ $name }} \
+    && adduser -D -u {{ $uid }} -G {{ $name }} -s {{ .shell | default "/bin/sh" }} {{ $name }}
+USER {{ $name }}
+{{- end -}}
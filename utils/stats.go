@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"time"
+)
+
+var (
+	// Whether the durations of the phases are recorded.
+	stats bool
+
+	// The recorded phases in the order they were first run.
+	phases []string
+	// The accumulated durations of the phases.
+	phaseDurations = make(map[string]time.Duration)
+)
+
+// Enables recording the durations of the phases.
+func EnableStats() {
+	stats = true
+}
+
+// Starts measuring a phase, the returned function stops the measurement.
+// Durations of repeated measurements of a phase are summed up.
+func Measure(phase string) func() {
+	if !stats {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		if _, ok := phaseDurations[phase]; !ok {
+			phases = append(phases, phase)
+		}
+		phaseDurations[phase] += time.Since(start)
+	}
+}
+
+// Logs the durations of the recorded phases.
+func ReportStats() {
+	if !stats {
+		return
+	}
+
+	var total time.Duration
+	for _, phase := range phases {
+		total += phaseDurations[phase]
+		Info("%-16s %s", phase, phaseDurations[phase].Round(time.Microsecond))
+	}
+	Info("%-16s %s", "total", total.Round(time.Microsecond))
+}
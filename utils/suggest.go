@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// The maximum number of suggestions for a misspelled name.
+const maxSuggestions = 3
+
+// Returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// Returns the candidates which are close to name, closest first.
+func Suggestions(name string, candidates []string) []string {
+	maxDistance := len(name) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	type match struct {
+		name     string
+		distance int
+	}
+
+	var matches []match
+	for _, c := range candidates {
+		if d := levenshtein(name, c); d <= maxDistance {
+			matches = append(matches, match{c, d})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	res := make([]string, 0, maxSuggestions)
+	for idx := 0; idx < len(matches) && idx < maxSuggestions; idx++ {
+		res = append(res, matches[idx].name)
+	}
+
+	return res
+}
+
+// Returns a hint naming the candidates close to name or an empty string
+// if there are none.
+func didYouMean(name string, candidates []string) string {
+	suggestions := Suggestions(name, candidates)
+	if len(suggestions) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(
+		", did you mean '%s'?", strings.Join(suggestions, "', '"),
+	)
+}
+
+// Returns the names of all templates associated with tpl.
+func templateNames(tpl *template.Template) []string {
+	var names []string
+	for _, t := range tpl.Templates() {
+		names = append(names, t.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the error for a reference to a template which is not defined.
+func undefinedTemplateError(name string, tpl *template.Template) error {
+	return fmt.Errorf(
+		"template '%s' is not defined%s",
+		name, didYouMean(name, templateNames(tpl)),
+	)
+}
+
+// Warns about references to templates which are not defined in the template
+// set of tpl, they only fail once they are executed which may depend on
+// the variant.
+func VerifyTemplateReferences(tpl *template.Template) {
+	for _, name := range templateNames(tpl) {
+		t := tpl.Lookup(name)
+		if t.Tree == nil {
+			continue
+		}
+
+		for _, ref := range TemplateReferences(t.Tree) {
+			if tpl.Lookup(ref.Name) != nil {
+				continue
+			}
+
+			location, _ := t.Tree.ErrorContext(ref.Node)
+			Warn(
+				"%s: %s", location, undefinedTemplateError(ref.Name, tpl),
+			)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template/parse"
+
+	"github.com/Masterminds/semver"
+)
+
+var (
+	// A comment declaring the version of the templates of a file, e.g.
+	// {{/* version 2 */}}.
+	versionCommentRegex = regexp.MustCompile(`^version\s+(\S+)$`)
+	// A comment requiring a minimum version of a template, e.g.
+	// {{/* requires dtpl/apt-cache >= 2 */}}.
+	requiresCommentRegex = regexp.MustCompile(`^requires\s+(\S+)\s+(.+)$`)
+)
+
+// A requirement on the version of a template declared with a comment.
+type TemplateRequirement struct {
+	// The name of the required template.
+	Name string
+	// The semantic version constraint, e.g. '>= 2'.
+	Constraint string
+	// The file declaring the requirement.
+	Source string
+}
+
+// Returns the text of a comment without its delimiters.
+func commentText(node *parse.CommentNode) string {
+	text := strings.TrimPrefix(node.Text, "/*")
+	text = strings.TrimSuffix(text, "*/")
+	return strings.TrimSpace(text)
+}
+
+// Calls fn with the text of each comment of the definitions.
+func walkComments(defs []*TemplateDefinition, fn func(def *TemplateDefinition, text string)) {
+	for _, def := range defs {
+		if def.Tree == nil {
+			continue
+		}
+		WalkTemplateNodes(def.Tree.Root, func(node parse.Node) {
+			if comment, ok := node.(*parse.CommentNode); ok {
+				fn(def, commentText(comment))
+			}
+		})
+	}
+}
+
+// Returns the versions the files of the definitions declare by source. A
+// version applies to all templates defined in the file.
+func TemplateVersions(defs []*TemplateDefinition) (map[string]string, error) {
+	versions := make(map[string]string)
+
+	var err error
+	walkComments(defs, func(def *TemplateDefinition, text string) {
+		match := versionCommentRegex.FindStringSubmatch(text)
+		if match == nil || err != nil {
+			return
+		}
+		if _, verr := semver.NewVersion(match[1]); verr != nil {
+			err = fmt.Errorf("'%s' declares the invalid version '%s'", def.Source, match[1])
+			return
+		}
+		if declared, ok := versions[def.Source]; ok && declared != match[1] {
+			err = fmt.Errorf(
+				"'%s' declares the versions '%s' and '%s'", def.Source, declared, match[1],
+			)
+			return
+		}
+		versions[def.Source] = match[1]
+	})
+
+	return versions, err
+}
+
+// Returns the version requirements the definitions declare.
+func TemplateRequirements(defs []*TemplateDefinition) []TemplateRequirement {
+	var reqs []TemplateRequirement
+
+	walkComments(defs, func(def *TemplateDefinition, text string) {
+		if match := requiresCommentRegex.FindStringSubmatch(text); match != nil {
+			reqs = append(reqs, TemplateRequirement{
+				Name: match[1], Constraint: match[2], Source: def.Source,
+			})
+		}
+	})
+
+	return reqs
+}
+
+// Returns whether the version satisfies the constraint of the requirement.
+func (r TemplateRequirement) Check(version string) (bool, error) {
+	c, err := semver.NewConstraint(r.Constraint)
+	if err != nil {
+		return false, fmt.Errorf(
+			"'%s' requires '%s' with the invalid constraint '%s'", r.Source, r.Name, r.Constraint,
+		)
+	}
+
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false, err
+	}
+
+	return c.Check(v), nil
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Decodes all documents of a yml file into nodes which keep the positions
+// of the values in the content.
+func DecodeYMLNodes(content []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		doc := &yaml.Node{}
+		err := decoder.Decode(doc)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+// Returns the end offset of the quoted scalar starting at offset or -1 if
+// the content does not contain a quoted scalar there.
+func quotedScalarEnd(content []byte, offset int, quote byte) int {
+	if offset >= len(content) || content[offset] != quote {
+		return -1
+	}
+
+	for idx := offset + 1; idx < len(content); idx++ {
+		switch {
+		case quote == '"' && content[idx] == '\\':
+			idx++
+		case quote == '\'' && content[idx] == '\'' && idx+1 < len(content) && content[idx+1] == '\'':
+			idx++
+		case content[idx] == quote:
+			return idx + 1
+		}
+	}
+
+	return -1
+}
+
+// Encodes a scalar value in the style of the node it replaces. Plain values
+// which cannot be written as plain scalar or would not be read as string
+// (e.g. 1.10 which is read as float) are double quoted, unless the node
+// has an explicit tag.
+func encodeYMLScalar(value string, style yaml.Style) string {
+	encode := func(style yaml.Style) string {
+		out, err := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Value: value, Style: style})
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSuffix(string(out), "\n")
+	}
+	readAsString := func() bool {
+		return (&yaml.Node{Kind: yaml.ScalarNode, Value: value}).ShortTag() == "!!str"
+	}
+
+	switch {
+	case style&yaml.SingleQuotedStyle != 0 && !strings.Contains(value, "\n"):
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	case style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle) == 0 && value != "" &&
+		encode(0) == value && (style&yaml.TaggedStyle != 0 || readAsString()):
+		return value
+	default:
+		return encode(yaml.DoubleQuotedStyle)
+	}
+}
+
+// Replaces the values of the scalar nodes (decoded from content) with their
+// new value. The content is edited in place, so formatting and comments are
+// preserved, values are encoded in the style of the value they replace.
+// Block scalars and plain scalars spanning multiple lines are not replaced.
+// Returns the new content and the number of replaced values.
+func ReplaceYMLNodes(content []byte, values map[*yaml.Node]string) ([]byte, int) {
+	type edit struct {
+		start int
+		end   int
+		new   string
+	}
+
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	lineOffsets := make([]int, len(lines)+1)
+	for idx, line := range lines {
+		lineOffsets[idx+1] = lineOffsets[idx] + len(line)
+	}
+
+	var edits []edit
+	for node, value := range values {
+		if node.Kind != yaml.ScalarNode || node.Line < 1 || node.Line > len(lines) {
+			continue
+		}
+
+		// The column counts characters, not bytes
+		line := []rune(string(lines[node.Line-1]))
+		if node.Column < 1 || node.Column > len(line) {
+			continue
+		}
+		start := lineOffsets[node.Line-1] + len(string(line[:node.Column-1]))
+		end := -1
+
+		// The position of a tagged value is the one of its tag
+		if node.Style&yaml.TaggedStyle != 0 && bytes.HasPrefix(content[start:], []byte("!")) {
+			for start < len(content) && content[start] != ' ' && content[start] != '\n' {
+				start++
+			}
+			for start < len(content) && content[start] == ' ' {
+				start++
+			}
+		}
+
+		switch {
+		case node.Style&yaml.DoubleQuotedStyle != 0:
+			end = quotedScalarEnd(content, start, '"')
+		case node.Style&yaml.SingleQuotedStyle != 0:
+			end = quotedScalarEnd(content, start, '\'')
+		case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 &&
+			bytes.HasPrefix(content[start:], []byte(node.Value)):
+			end = start + len(node.Value)
+		}
+
+		if end < 0 {
+			Debug("Not replacing '%s' in line %d which cannot be edited in place", node.Value, node.Line)
+			continue
+		}
+
+		edits = append(edits, edit{start, end, encodeYMLScalar(value, node.Style)})
+	}
+
+	// Edit from the end so the offsets of the previous edits stay valid
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+
+	res := append([]byte{}, content...)
+	for _, e := range edits {
+		res = append(res[:e.start], append([]byte(e.new), res[e.end:]...)...)
+	}
+
+	return res, len(edits)
+}
+
+// Replaces the scalar values (not keys) of a yml document which are keys
+// of the replacements with their new value, see ReplaceYMLNodes. Returns
+// the new content and the number of replaced values.
+func ReplaceYMLScalars(
+	content []byte,
+	replacements map[string]string,
+) ([]byte, int, error) {
+	values := make(map[*yaml.Node]string)
+
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		switch node.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range node.Content {
+				walk(child)
+			}
+		case yaml.MappingNode:
+			for idx := 1; idx < len(node.Content); idx += 2 {
+				walk(node.Content[idx])
+			}
+		case yaml.ScalarNode:
+			if replacement, ok := replacements[node.Value]; ok {
+				values[node] = replacement
+			}
+		}
+	}
+
+	docs, err := DecodeYMLNodes(content)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, doc := range docs {
+		walk(doc)
+	}
+
+	res, count := ReplaceYMLNodes(content, values)
+	return res, count, nil
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestEncodeYMLScalar(t *testing.T) {
+	tests := []struct {
+		value string
+		style yaml.Style
+		want  string
+	}{
+		{"v2", 0, "v2"},
+		{"1.10", 0, `"1.10"`},
+		{"3.10", 0, `"3.10"`},
+		{"42", 0, `"42"`},
+		{"true", 0, `"true"`},
+		{"null", 0, `"null"`},
+		{"", 0, `""`},
+		{"a: b", 0, `"a: b"`},
+		{"1.10", yaml.TaggedStyle, "1.10"},
+		{"1.10", yaml.DoubleQuotedStyle, `"1.10"`},
+		{"it's", yaml.SingleQuotedStyle, "'it''s'"},
+	}
+
+	for _, tc := range tests {
+		if got := encodeYMLScalar(tc.value, tc.style); got != tc.want {
+			t.Errorf("encodeYMLScalar(%q, %d) = %s, want %s", tc.value, tc.style, got, tc.want)
+		}
+	}
+}
+
+func TestReplaceYMLScalars(t *testing.T) {
+	content := "tag: v1 # current\npython: '3.9'\nversion: 3.9\nforced: !!str 1.9\n"
+	replacements := map[string]string{"v1": "1.10", "3.9": "3.10", "1.9": "1.10"}
+
+	got, count, err := ReplaceYMLScalars([]byte(content), replacements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "tag: \"1.10\" # current\npython: '3.10'\nversion: \"3.10\"\nforced: !!str 1.10\n"
+	if string(got) != want || count != 4 {
+		t.Errorf("ReplaceYMLScalars() = %q, %d, want %q, %d", got, count, want, 4)
+	}
+
+	// The replaced values are read as strings
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(got, &values); err != nil {
+		t.Fatal(err)
+	}
+	for key, val := range values {
+		if val != "1.10" && val != "3.10" {
+			t.Errorf("%s = %#v, want the string value", key, val)
+		}
+	}
+}